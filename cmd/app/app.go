@@ -22,6 +22,7 @@ func NewApp() *app.App {
 		app.WithDescription(commandDesc), // Full descriptive documentation
 		app.WithOptions(opts),            // Application-specific options
 		app.WithDefaultValidArgs(),       // Command-line argument validation setup
+		app.WithErrorCatalog(),           // The `errors` command printing the error catalog
 		app.WithRunFunc(run(opts)),       // The run function for starting the app
 	)
 }
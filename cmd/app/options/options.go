@@ -12,11 +12,12 @@ import (
 
 // ServerOptions contains the configuration options for the server.
 type ServerOptions struct {
-	GRPCOptions  *genericoptions.GRPCOptions  `json:"grpc" mapstructure:"grpc"`
-	TLSOptions   *genericoptions.TLSOptions   `json:"tls" mapstructure:"tls"`
-	RedisOptions *genericoptions.RedisOptions `json:"redis" mapstructure:"redis"`
-	MySQLOptions *genericoptions.MySQLOptions `json:"mysql" mapstructure:"mysql"`
-	Logging      *log.Options                 `json:"log" mapstructure:"log"`
+	GRPCOptions    *genericoptions.GRPCOptions    `json:"grpc" mapstructure:"grpc"`
+	TLSOptions     *genericoptions.TLSOptions     `json:"tls" mapstructure:"tls"`
+	RedisOptions   *genericoptions.RedisOptions   `json:"redis" mapstructure:"redis"`
+	MySQLOptions   *genericoptions.MySQLOptions   `json:"mysql" mapstructure:"mysql"`
+	TracingOptions *genericoptions.TracingOptions `json:"tracing" mapstructure:"tracing"`
+	Logging        *log.Options                   `json:"log" mapstructure:"log"`
 }
 
 // Ensure ServerOptions implements the app.NamedFlagSetOptions interface.
@@ -25,12 +26,13 @@ var _ app.NamedFlagSetOptions = (*ServerOptions)(nil)
 // NewServerOptions creates a ServerOptions instance with default values.
 func NewServerOptions() *ServerOptions {
 	o := &ServerOptions{
-		DisableCache: false,
-		GRPCOptions:  genericoptions.NewGRPCOptions(),
-		TLSOptions:   genericoptions.NewTLSOptions(),
-		RedisOptions: genericoptions.NewRedisOptions(),
-		MySQLOptions: genericoptions.NewMySQLOptions(),
-		Logging:      log.NewOptions(),
+		DisableCache:   false,
+		GRPCOptions:    genericoptions.NewGRPCOptions(),
+		TLSOptions:     genericoptions.NewTLSOptions(),
+		RedisOptions:   genericoptions.NewRedisOptions(),
+		MySQLOptions:   genericoptions.NewMySQLOptions(),
+		TracingOptions: genericoptions.NewTracingOptions(),
+		Logging:        log.NewOptions(),
 	}
 
 	return o
@@ -43,6 +45,7 @@ func (o *ServerOptions) Flags() (fss cliflag.NamedFlagSets) {
 	o.TLSOptions.AddFlags(fss.FlagSet("tls"))
 	o.RedisOptions.AddFlags(fss.FlagSet("redis"))
 	o.MySQLOptions.AddFlags(fss.FlagSet("mysql"))
+	o.TracingOptions.AddFlags(fss.FlagSet("tracing"))
 	o.Logging.AddFlags(fss.FlagSet("log"))
 
 	// Add a miscellaneous flag for the cache control feature.
@@ -61,6 +64,7 @@ func (o *ServerOptions) Validate() error {
 	errs = append(errs, o.TLSOptions.Validate()...)
 	errs = append(errs, o.RedisOptions.Validate()...)
 	errs = append(errs, o.MySQLOptions.Validate()...)
+	errs = append(errs, o.TracingOptions.Validate()...)
 	errs = append(errs, o.Logging.Validate()...)
 
 	// Aggregate all validation errors into a single error object.
@@ -71,11 +75,11 @@ func (o *ServerOptions) Validate() error {
 func (o *ServerOptions) Config() (*cacheserver.Config, error) {
 	// Ensure the configuration includes all relevant fields from the options.
 	return &cacheserver.Config{
-		DisableCache:  o.DisableCache,
-		GRPCOptions:   o.GRPCOptions,
-		TLSOptions:    o.TLSOptions,
-		RedisOptions:  o.RedisOptions,
-		MySQLOptions:  o.MySQLOptions,
-		JaegerOptions: o.JaegerOptions,
+		DisableCache:   o.DisableCache,
+		GRPCOptions:    o.GRPCOptions,
+		TLSOptions:     o.TLSOptions,
+		RedisOptions:   o.RedisOptions,
+		MySQLOptions:   o.MySQLOptions,
+		TracingOptions: o.TracingOptions,
 	}, nil
 }
@@ -6,7 +6,6 @@ import (
 	"os"
 	"runtime"
 	"strings"
-	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
@@ -16,8 +15,11 @@ import (
 	cliflag "k8s.io/component-base/cli/flag"
 	"k8s.io/component-base/term"
 
+	"chunyu/pkg/config"
+	"chunyu/pkg/health"
 	"chunyu/pkg/log"
 	genericoptions "chunyu/pkg/options"
+	"chunyu/pkg/server"
 	"chunyu/pkg/version"
 )
 
@@ -34,9 +36,33 @@ type App struct {
 	// +optional
 	healthCheckFunc HealthCheckFunc
 
+	// +optional
+	healthServer *server.HealthServer
+
+	// +optional
+	tracing *genericoptions.TracingOptions
+
+	// +optional
+	lifecycle *Lifecycle
+
 	// +optional
 	options any
 
+	// +optional
+	commands []*Command
+
+	// +optional
+	reloader *Reloader
+
+	// +optional
+	configOptions []config.Option
+
+	// +optional
+	config *configLoader
+
+	// +optional
+	migrations *migrations
+
 	// +optional
 	silence bool
 
@@ -90,17 +116,40 @@ func WithHealthCheckFunc(fn HealthCheckFunc) Option {
 	}
 }
 
-// WithDefaultHealthCheckFunc set the default health check function.
-func WithDefaultHealthCheckFunc() Option {
-	fn := func() HealthCheckFunc {
-		return func() error {
-			go genericoptions.NewHealthOptions().ServeHealthCheck()
+// WithLifecycle sets the lifecycle used to start and stop application
+// components. When set, the application starts all registered hooks after the
+// run function returns, waits for SIGINT or SIGTERM, and then stops the hooks
+// in reverse order.
+func WithLifecycle(lc *Lifecycle) Option {
+	return func(app *App) {
+		app.lifecycle = lc
+	}
+}
 
-			return nil
-		}
+// WithHealthServer sets the server serving the liveness and readiness checks.
+// It is started before the run function and shut down when the application
+// exits, after the lifecycle hooks were stopped.
+func WithHealthServer(srv *server.HealthServer) Option {
+	return func(app *App) {
+		app.healthServer = srv
 	}
+}
 
-	return WithHealthCheckFunc(fn())
+// WithTracing installs the global tracer provider configured by opts before
+// the run function, so that the spans of the HTTP and gRPC servers, GORM and
+// redis are exported. The provider is shut down when the application exits,
+// after the lifecycle hooks were stopped, to flush the pending spans. opts is
+// usually a field of the application options, it is read once they are loaded.
+func WithTracing(opts *genericoptions.TracingOptions) Option {
+	return func(app *App) {
+		app.tracing = opts
+	}
+}
+
+// WithDefaultHealthCheckFunc serves the checks registered in
+// health.DefaultRegistry with the default health options.
+func WithDefaultHealthCheckFunc() Option {
+	return WithHealthServer(server.NewHealthServer(genericoptions.NewHealthOptions(), health.DefaultRegistry))
 }
 
 // WithSilence sets the application to silent mode, in which the program startup
@@ -133,13 +182,31 @@ func WithDefaultValidArgs() Option {
 	}
 }
 
-// WithWatchConfig watching and re-reading config files.
+// WithWatchConfig watching and re-reading config files. On change the options
+// are reloaded, see Reloader.
 func WithWatchConfig() Option {
 	return func(app *App) {
 		app.watch = true
 	}
 }
 
+// WithConfigOptions customizes how the configuration is loaded, e.g. to add
+// remote sources with config.WithSources.
+func WithConfigOptions(opts ...config.Option) Option {
+	return func(app *App) {
+		app.configOptions = append(app.configOptions, opts...)
+	}
+}
+
+// WithReloader sets the reloader which reloads the options when the config
+// file changes. It implies WithWatchConfig.
+func WithReloader(r *Reloader) Option {
+	return func(app *App) {
+		app.watch = true
+		app.reloader = r
+	}
+}
+
 func WithLoggerContextExtractor(contextExtractors map[string]func(context.Context) string) Option {
 	return func(app *App) {
 		app.contextExtractors = contextExtractors
@@ -172,6 +239,9 @@ func (app *App) buildCommand() {
 		Long:  app.description,
 		RunE:  app.runCommand,
 		PersistentPreRunE: func(*cobra.Command, []string) error {
+			if app.config != nil {
+				return app.config.load()
+			}
 			return nil
 		},
 		Args: app.args,
@@ -204,35 +274,61 @@ func (app *App) buildCommand() {
 	cmd.SetErr(os.Stderr)
 	cmd.Flags().SortFlags = true
 
-	var fs *pflag.FlagSet
-	// 方法2：使用type switch
+	// Global flags are persistent so that every sub command inherits them.
+	global := pflag.NewFlagSet("global", pflag.ContinueOnError)
 	switch typed := app.options.(type) {
 	case NamedFlagSetOptions:
-		var fss cliflag.NamedFlagSets
-		fs = fss.FlagSet("global")
-
-		if app.options != nil {
-			fss = typed.Flags()
-		}
-
+		fss := typed.Flags()
+		global = fss.FlagSet("global")
 		for _, f := range fss.FlagSets {
-			cmd.Flags().AddFlagSet(f)
+			if f != global {
+				cmd.Flags().AddFlagSet(f)
+			}
 		}
 
 		cols, _, _ := term.TerminalSize(cmd.OutOrStdout())
 		cliflag.SetUsageAndHelpFunc(cmd, fss, cols)
 	case FlagSetOptions:
-		fs = cmd.PersistentFlags()
-		if app.options != nil {
-			typed.AddFlags(fs)
-		}
+		typed.AddFlags(cmd.PersistentFlags())
 	default:
 	}
 
-	version.AddFlags(fs)
+	version.AddFlags(global)
 
 	if !app.noConfig {
-		AddConfigFlag(fs, app.name, app.watch)
+		AddConfigFlag(global, app.name)
+		app.config = newConfigLoader(app.name, app.watch, app.configOptions...)
+	}
+
+	if app.watch {
+		if app.reloader == nil {
+			app.reloader = NewReloader()
+		}
+		if app.config != nil {
+			app.config.onChange(func() {
+				_ = app.reloader.Reload()
+			})
+		}
+	}
+
+	addLogFlags(global, cmd)
+	addHelpFlag(app.name, global)
+	cmd.PersistentFlags().AddFlagSet(global)
+
+	if len(app.commands) > 0 || app.migrations != nil || (app.options != nil && !app.noConfig) {
+		cmd.SetHelpCommand(helpCommand(formatBaseName(app.name)))
+	}
+
+	commands := app.commands
+	if app.options != nil && !app.noConfig {
+		commands = append(commands, app.configCommand())
+	}
+	for _, c := range commands {
+		cmd.AddCommand(c.cobraCommand(app, global))
+	}
+
+	if app.migrations != nil {
+		cmd.AddCommand(app.migrateCommand())
 	}
 
 	app.cmd = cmd
@@ -244,51 +340,117 @@ func (app *App) Run() {
 }
 
 func (app *App) runCommand(cmd *cobra.Command, args []string) error {
-	// display application version information
-	version.PrintAndExitIfRequested()
-
-	if err := viper.BindPFlags(cmd.Flags()); err != nil {
+	if err := app.prepareCommand(cmd, app.options); err != nil {
 		return err
 	}
 
-	if app.options != nil {
-		if err := viper.Unmarshal(app.options); err != nil {
+	if app.tracing != nil {
+		tp, err := app.tracing.NewTracerProvider(cmd.Context(), version.Get().GitVersion)
+		if err != nil {
 			return err
 		}
-
-		if complete, ok := app.options.(interface{ Complete() error }); ok {
-			if err := complete.Complete(); err != nil {
-				return err
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), defaultHookTimeout)
+			defer cancel()
+			if err := tp.Shutdown(ctx); err != nil {
+				slog.Error("Failed to shut down tracer provider", "err", err)
 			}
+		}()
+	}
+
+	if app.healthCheckFunc != nil {
+		if err := app.healthCheckFunc(); err != nil {
+			return err
 		}
+	}
 
-		if validate, ok := app.options.(interface{ Validate() error }); ok {
-			if err := validate.Validate(); err != nil {
-				return err
-			}
+	if app.healthServer != nil {
+		if err := app.healthServer.Start(cmd.Context()); err != nil {
+			return err
 		}
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), defaultHookTimeout)
+			defer cancel()
+			if err := app.healthServer.Stop(ctx); err != nil {
+				slog.Error("Failed to stop health check server", "err", err)
+			}
+		}()
+	}
+
+	// run application
+	if err := app.run(); err != nil {
+		return err
+	}
+
+	if app.lifecycle == nil {
+		return nil
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	return app.lifecycle.Run(ctx)
+}
+
+// prepareCommand runs the steps shared by the application and its sub
+// commands before their run function: it handles --version, loads opts,
+// initializes the logger and prints the configuration.
+func (app *App) prepareCommand(cmd *cobra.Command, opts any) error {
+	// display application version information
+	version.PrintAndExitIfRequested()
+
+	if err := loadOptions(cmd, opts); err != nil {
+		return err
 	}
 
 	app.initializeLogger()
 
-	if !app.silence {
+	if app.reloader != nil {
+		app.reloader.bind(opts)
+	}
+
+	if app.silence {
+		return nil
+	}
+
+	if cmd == app.cmd {
 		slog.Info("Starting application", "name", app.name, "version", version.Get().ToJSON())
 		slog.Info("Golang settings", "GOGC", os.Getenv("GOGC"), "GOMAXPROCS", os.Getenv("GOMAXPROCS"), "GOTRACEBACK", os.Getenv("GOTRACEBACK"))
-		if !app.noConfig {
-			PrintConfig()
-		} else if app.options != nil {
-			cliflag.PrintFlags(cmd.Flags())
-		}
+	}
+	if !app.noConfig && opts != nil {
+		PrintOptions(opts, cmd.Flags())
+	} else if !app.noConfig {
+		PrintConfig()
+	} else if opts != nil {
+		PrintFlags(cmd.Flags())
 	}
 
-	if app.healthCheckFunc != nil {
-		if err := app.healthCheckFunc(); err != nil {
-			return err
-		}
+	return nil
+}
+
+// loadOptions binds the flags of cmd into viper, unmarshals the configuration
+// into opts, and completes and validates them.
+func loadOptions(cmd *cobra.Command, opts any) error {
+	if err := viper.BindPFlags(cmd.Flags()); err != nil {
+		return err
 	}
 
-	// run application
-	return app.run()
+	if opts == nil {
+		return nil
+	}
+
+	return loadInto(opts)
+}
+
+// Lifecycle returns the lifecycle of the application, or nil if none was set.
+func (app *App) Lifecycle() *Lifecycle {
+	return app.lifecycle
+}
+
+// Reloader returns the reloader of the application, or nil if the config file
+// is not watched.
+func (app *App) Reloader() *Reloader {
+	return app.reloader
 }
 
 // Command returns cobra command instance inside the application.
@@ -307,6 +469,18 @@ func formatBaseName(name string) string {
 	return name
 }
 
+// addLogFlags adds the log flags read by initializeLogger to fs, skipping the
+// ones already registered on fs or cmd by the application options.
+func addLogFlags(fs *pflag.FlagSet, cmd *cobra.Command) {
+	logfs := pflag.NewFlagSet("log", pflag.ContinueOnError)
+	log.NewOptions().AddFlags(logfs)
+	logfs.VisitAll(func(f *pflag.Flag) {
+		if fs.Lookup(f.Name) == nil && cmd.Flags().Lookup(f.Name) == nil && cmd.PersistentFlags().Lookup(f.Name) == nil {
+			fs.AddFlag(f)
+		}
+	})
+}
+
 // initializeLogger sets up the logging system based on the configuration.
 func (app *App) initializeLogger() {
 	logOptions := log.NewOptions()
@@ -328,10 +502,10 @@ func (app *App) initializeLogger() {
 		logOptions.Debug = viper.GetBool("log.debug")
 	}
 	if viper.IsSet("log.max_age") {
-		logOptions.MaxAge = time.Duration(viper.GetInt("log.max_age"))
+		logOptions.MaxAge = viper.GetDuration("log.max_age")
 	}
 	if viper.IsSet("log.rotation_time") {
-		logOptions.RotationTime = time.Duration(viper.GetInt64("log.rotation_time"))
+		logOptions.RotationTime = viper.GetDuration("log.rotation_time")
 	}
 	if viper.IsSet("log.rotation_size") {
 		logOptions.RotationSize = viper.GetInt64("log.rotation_size")
@@ -0,0 +1,43 @@
+package app
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+
+	genericoptions "chunyu/pkg/options"
+	"chunyu/pkg/tracing"
+)
+
+func TestApp_Tracing(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	provider := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(provider) })
+
+	dir := t.TempDir()
+	opts := genericoptions.NewTracingOptions()
+	opts.Exporter = tracing.ExporterFile
+	opts.File = filepath.Join(dir, "spans.json")
+
+	app := NewApp("chunyu-test", "test", WithSilence(), WithNoConfig(), WithTracing(opts),
+		WithRunFunc(func() error {
+			_, span := tracing.Tracer().Start(context.Background(), "run")
+			span.End()
+			return nil
+		}),
+	)
+	cmd := app.Command()
+	cmd.SetArgs([]string{"--log.dir", dir})
+	require.NoError(t, cmd.Execute())
+
+	// The provider was installed before the run function and flushed on exit.
+	data, err := os.ReadFile(opts.File)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"name":"run"`)
+}
@@ -0,0 +1,168 @@
+package app
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	cliflag "k8s.io/component-base/cli/flag"
+	"k8s.io/component-base/term"
+)
+
+// Command is a sub command of an application. It is recommended that a
+// command be created with the app.NewCommand() function.
+type Command struct {
+	usage       string
+	shortDesc   string
+	description string
+	run         RunCommandFunc
+	args        cobra.PositionalArgs
+	commands    []*Command
+	cmd         *cobra.Command
+
+	// +optional
+	options any
+
+	// +optional
+	flags func(fs *pflag.FlagSet)
+}
+
+// RunCommandFunc defines the callback function of a sub command. args are the
+// non-flag arguments left after parsing.
+type RunCommandFunc func(args []string) error
+
+// CommandOption defines optional parameters for initializing the command
+// structure.
+type CommandOption func(*Command)
+
+// WithCommandOptions sets the options of the command. Like the application
+// options, they are read from the command line and the configuration file,
+// then completed and validated before the run function is called.
+func WithCommandOptions(opts any) CommandOption {
+	return func(c *Command) {
+		c.options = opts
+	}
+}
+
+// WithCommandRunFunc is used to set the command startup callback function option.
+func WithCommandRunFunc(run RunCommandFunc) CommandOption {
+	return func(c *Command) {
+		c.run = run
+	}
+}
+
+// WithCommandDescription is used to set the description of the command.
+func WithCommandDescription(desc string) CommandOption {
+	return func(c *Command) {
+		c.description = desc
+	}
+}
+
+// WithCommandFlags adds flags which are not backed by the command options.
+func WithCommandFlags(fn func(fs *pflag.FlagSet)) CommandOption {
+	return func(c *Command) {
+		c.flags = fn
+	}
+}
+
+// WithCommandValidArgs set the validation function to valid non-flag arguments.
+func WithCommandValidArgs(args cobra.PositionalArgs) CommandOption {
+	return func(c *Command) {
+		c.args = args
+	}
+}
+
+// WithSubCommands adds sub commands to the command.
+func WithSubCommands(cmds ...*Command) CommandOption {
+	return func(c *Command) {
+		c.commands = append(c.commands, cmds...)
+	}
+}
+
+// WithCommands adds sub commands to the application. They inherit the global
+// flags of the application, such as --config, --version and the log flags.
+func WithCommands(cmds ...*Command) Option {
+	return func(app *App) {
+		app.commands = append(app.commands, cmds...)
+	}
+}
+
+// NewCommand creates a new sub command instance based on the given usage line,
+// short description and other options. The first word of usage is the name of
+// the command.
+func NewCommand(usage string, shortDesc string, opts ...CommandOption) *Command {
+	c := &Command{
+		usage:     usage,
+		shortDesc: shortDesc,
+	}
+
+	for _, o := range opts {
+		o(c)
+	}
+
+	return c
+}
+
+// AddCommands adds sub commands to the command.
+func (c *Command) AddCommands(cmds ...*Command) {
+	c.commands = append(c.commands, cmds...)
+}
+
+// Command returns the cobra command of c. It is nil until c has been added to
+// an application.
+func (c *Command) Command() *cobra.Command {
+	return c.cmd
+}
+
+// cobraCommand builds the cobra command of c. global is the flag set
+// inherited from the application; it is only used to render the usage.
+func (c *Command) cobraCommand(app *App, global *pflag.FlagSet) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   c.usage,
+		Short: c.shortDesc,
+		Long:  c.description,
+		Args:  c.args,
+	}
+	cmd.Flags().SortFlags = true
+	c.cmd = cmd
+
+	switch typed := c.options.(type) {
+	case NamedFlagSetOptions:
+		fss := typed.Flags()
+		if c.flags != nil {
+			c.flags(fss.FlagSet("misc"))
+		}
+		addHelpCommandFlag(c.usage, fss.FlagSet("global"))
+		for _, f := range fss.FlagSets {
+			cmd.Flags().AddFlagSet(f)
+		}
+		// The inherited flags are parsed through the parent, they are only
+		// added here to be listed in the global section of the usage.
+		fss.FlagSet("global").AddFlagSet(global)
+
+		cols, _, _ := term.TerminalSize(cmd.OutOrStdout())
+		cliflag.SetUsageAndHelpFunc(cmd, fss, cols)
+	default:
+		if typed, ok := typed.(FlagSetOptions); ok {
+			typed.AddFlags(cmd.Flags())
+		}
+		if c.flags != nil {
+			c.flags(cmd.Flags())
+		}
+		addHelpCommandFlag(c.usage, cmd.Flags())
+	}
+
+	for _, sub := range c.commands {
+		cmd.AddCommand(sub.cobraCommand(app, global))
+	}
+
+	if c.run != nil {
+		cmd.RunE = func(cmd *cobra.Command, args []string) error {
+			if err := app.prepareCommand(cmd, c.options); err != nil {
+				return err
+			}
+
+			return c.run(args)
+		}
+	}
+
+	return cmd
+}
@@ -0,0 +1,79 @@
+package app
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type greetOptions struct {
+	Name      string `mapstructure:"name"`
+	completed bool
+}
+
+func (o *greetOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&o.Name, "name", o.Name, "Name to greet.")
+}
+
+func (o *greetOptions) Complete() error {
+	o.completed = true
+	return nil
+}
+
+func (o *greetOptions) Validate() error {
+	if o.Name == "" {
+		return errors.New("name is required")
+	}
+	return nil
+}
+
+func TestCommand_Run(t *testing.T) {
+	opts := &greetOptions{}
+	var gotArgs []string
+	greet := NewCommand("greet [WHO...]", "Greet someone",
+		WithCommandOptions(opts),
+		WithCommandRunFunc(func(args []string) error {
+			gotArgs = args
+			return nil
+		}),
+	)
+
+	app := NewApp("chunyu-test", "test", WithSilence(), WithNoConfig(),
+		WithCommands(NewCommand("hello", "Parent command", WithSubCommands(greet))))
+
+	cmd := app.Command()
+	cmd.SetArgs([]string{"hello", "greet", "--name=alice", "--log.dir", t.TempDir(), "bob"})
+	require.NoError(t, cmd.Execute())
+
+	assert.Equal(t, "alice", opts.Name)
+	assert.True(t, opts.completed)
+	assert.Equal(t, []string{"bob"}, gotArgs)
+
+	sub, _, err := cmd.Find([]string{"hello", "greet"})
+	require.NoError(t, err)
+	for _, name := range []string{"version", "log.level", flagHelp} {
+		assert.NotNil(t, sub.Flags().Lookup(name), name)
+	}
+	assert.Contains(t, sub.Flags().Lookup(flagHelp).Usage, "greet")
+}
+
+func TestCommand_ValidateError(t *testing.T) {
+	called := false
+	app := NewApp("chunyu-test", "test", WithSilence(), WithNoConfig(), WithCommands(
+		NewCommand("greet", "Greet someone",
+			WithCommandOptions(&greetOptions{}),
+			WithCommandRunFunc(func([]string) error {
+				called = true
+				return nil
+			}),
+		),
+	))
+
+	cmd := app.Command()
+	cmd.SetArgs([]string{"greet", "--log.dir", t.TempDir()})
+	assert.EqualError(t, cmd.Execute(), "name is required")
+	assert.False(t, called)
+}
@@ -1,26 +1,36 @@
 package app
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
+	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 
-	"github.com/fsnotify/fsnotify"
-	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 	"k8s.io/client-go/util/homedir"
+
+	"chunyu/pkg/config"
 )
 
 const configFlagName = "config"
 
 var cfgFile string
 
+var (
+	// envPrefix is the prefix of the environment variables read by viper.
+	envPrefix string
+	// envKeyReplacer maps configuration keys to environment variable names.
+	envKeyReplacer = strings.NewReplacer(".", "_", "-", "_")
+)
+
 // AddConfigFlag adds flags for a specific server to the specified FlagSet object.
-// It also sets a passed functions to read values from configuration file into viper
-// when each cobra command's Execute method is called.
-func AddConfigFlag(fs *pflag.FlagSet, name string, watch bool) {
+// It also makes viper read the environment variables prefixed with the upper
+// case name, e.g. CHUNYU_MYSQL_ADDR for mysql.addr.
+func AddConfigFlag(fs *pflag.FlagSet, name string) {
 	fs.AddFlag(pflag.Lookup(configFlagName))
 
 	// Enable viper's automatic environment variable parsing. This means
@@ -30,42 +40,99 @@ func AddConfigFlag(fs *pflag.FlagSet, name string, watch bool) {
 	// Set the environment variable prefix. Use the strings.ReplaceAll function
 	// to replace hyphens with underscores in the name, and use strings.ToUpper
 	// to convert the name to uppercase, then set it as the prefix for environment variables.
-	viper.SetEnvPrefix(strings.ReplaceAll(strings.ToUpper(name), "-", "_"))
+	envPrefix = strings.ReplaceAll(strings.ToUpper(name), "-", "_")
+	viper.SetEnvPrefix(envPrefix)
 	// Set the replacement rules for environment variable keys. Use the
 	// strings.NewReplacer function to specify replacing periods and hyphens with underscores.
-	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_", "-", "_"))
+	viper.SetEnvKeyReplacer(envKeyReplacer)
+}
 
-	cobra.OnInitialize(func() {
-		if cfgFile != "" {
-			viper.SetConfigFile(cfgFile)
-		} else {
-			viper.AddConfigPath(".")
+// configLoader reads the configuration of an application into viper before
+// its commands run.
+//
+// The configuration is layered, see package config: <name>.<ext> is searched
+// in the current directory, then in ~/.<prefix> and /etc/<prefix> for names of
+// the form <prefix>-<suffix>, unless --config is given. The environment
+// overlay is selected with the <NAME>_ENV environment variable. opts can add
+// remote sources or override these settings.
+type configLoader struct {
+	name  string
+	watch bool
+	opts  []config.Option
 
-			if names := strings.Split(name, "-"); len(names) > 1 {
-				viper.AddConfigPath(filepath.Join(homedir.HomeDir(), "."+names[0]))
-				viper.AddConfigPath(filepath.Join("/etc", names[0]))
-			}
+	watchOnce sync.Once
+	// hooks are called after the watched configuration has been re-read.
+	hooks []func()
+}
 
-			viper.SetConfigName(name)
-		}
+func newConfigLoader(name string, watch bool, opts ...config.Option) *configLoader {
+	return &configLoader{name: name, watch: watch, opts: opts}
+}
 
-		if err := viper.ReadInConfig(); err != nil {
-			slog.Debug("Failed to read configuration file", "file", cfgFile, "err", err)
-		}
-		slog.Debug("Success to read configuration file", "file", viper.ConfigFileUsed())
+// onChange registers fn to be called when the watched configuration changes.
+func (c *configLoader) onChange(fn func()) {
+	c.hooks = append(c.hooks, fn)
+}
+
+// load reads the configuration into viper. The first call also starts
+// watching it if watch is set. An error, e.g. an unreadable file given with
+// --config or an unreachable remote source, fails the command rather than
+// running it with the default options.
+func (c *configLoader) load() error {
+	paths := []string{"."}
+	if names := strings.Split(c.name, "-"); len(names) > 1 {
+		paths = append(paths, filepath.Join(homedir.HomeDir(), "."+names[0]), filepath.Join("/etc", names[0]))
+	}
+
+	loader := config.NewLoader(c.name, append([]config.Option{
+		config.WithConfigFile(cfgFile),
+		config.WithSearchPaths(paths...),
+		config.WithEnvironment(os.Getenv(envPrefix + "_ENV")),
+	}, c.opts...)...)
+
+	ctx := context.Background()
+	if err := loader.Load(ctx); err != nil {
+		return fmt.Errorf("failed to read configuration: %w", err)
+	}
+	slog.Debug("Success to read configuration", "files", loader.Files())
 
-		if watch {
-			viper.WatchConfig()
-			viper.OnConfigChange(func(e fsnotify.Event) {
-				slog.Debug("Config file changed", "name", e.Name)
-			})
+	if !c.watch {
+		return nil
+	}
+	c.watchOnce.Do(func() {
+		err := loader.Watch(ctx, func(err error) {
+			if err != nil {
+				slog.Warn("Failed to reload configuration", "err", err)
+				return
+			}
+			slog.Debug("Configuration changed", "files", loader.Files())
+			for _, fn := range c.hooks {
+				fn()
+			}
+		})
+		if err != nil {
+			slog.Warn("Failed to watch configuration", "err", err)
 		}
 	})
+
+	return nil
 }
 
+// PrintOptions logs the typed options with the source of every value, masking
+// sensitive values.
+func PrintOptions(opts any, fs *pflag.FlagSet) {
+	slog.Debug("Loaded configuration", "config", configTree(ConfigFields(opts, fs), true))
+}
+
+// PrintConfig logs the configuration, redacting the values resolved from
+// secret references.
 func PrintConfig() {
 	for _, key := range viper.AllKeys() {
-		slog.Debug(fmt.Sprintf("CFG: %s=%v", key, viper.Get(key)))
+		var value any = redacted
+		if !isSecret(key) {
+			value = viper.Get(key)
+		}
+		slog.Debug(fmt.Sprintf("CFG: %s=%v", key, value))
 	}
 }
 
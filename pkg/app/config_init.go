@@ -0,0 +1,183 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// bareKey matches the keys which do not need to be quoted in toml.
+var bareKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// configInitCommand builds the `config init` sub command.
+func (app *App) configInitCommand() *Command {
+	output, force := "yaml", false
+
+	c := NewCommand("init [FILE]", "Generate a configuration file with the default values",
+		WithCommandDescription(`Generate a configuration file holding the default value of every option,
+each preceded by its description. Sensitive options are left empty.
+The file is written to stdout when FILE is not given.`),
+		WithCommandValidArgs(cobra.MaximumNArgs(1)),
+		WithCommandFlags(func(fs *pflag.FlagSet) {
+			fs.StringVarP(&output, "output", "o", output, ""+
+				"Output format. One of: yaml, toml, json. Defaults to the extension of FILE.")
+			fs.BoolVar(&force, "force", force, "Overwrite FILE if it exists.")
+		}),
+	)
+	c.run = func(args []string) error {
+		// The options of the application are not loaded by this command, so
+		// they still hold their defaults.
+		fields := ConfigFields(app.options, app.optionFlags())
+		for i := range fields {
+			if fields[i].Sensitive {
+				fields[i].Value = ""
+			}
+		}
+
+		if len(args) == 0 {
+			return RenderConfig(c.Command().OutOrStdout(), fields, output, RenderOptions{ShowDescription: true})
+		}
+
+		file := args[0]
+		if !c.Command().Flags().Changed("output") {
+			if ext := strings.TrimPrefix(filepath.Ext(file), "."); ext != "" {
+				output = ext
+			}
+		}
+
+		var buf bytes.Buffer
+		if err := RenderConfig(&buf, fields, output, RenderOptions{ShowDescription: true}); err != nil {
+			return err
+		}
+
+		if _, err := os.Stat(file); err == nil && !force {
+			return fmt.Errorf("%s already exists, use --force to overwrite it", file)
+		}
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(file, buf.Bytes(), 0o644); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(c.Command().OutOrStdout(), "Configuration written to %s\n", file)
+		return nil
+	}
+
+	return c
+}
+
+// optionFlags returns the flags of the root command, which describe the
+// application options.
+func (app *App) optionFlags() *pflag.FlagSet {
+	fs := pflag.NewFlagSet(app.name, pflag.ContinueOnError)
+	fs.AddFlagSet(app.cmd.Flags())
+	fs.AddFlagSet(app.cmd.PersistentFlags())
+	return fs
+}
+
+// renderTOML writes fields as a toml document. Keys of a table must precede
+// its sub tables, so fields are grouped by table in order of first appearance,
+// starting with the root table.
+func renderTOML(w io.Writer, fields []ConfigField, comments bool) error {
+	tables := []string{""}
+	byTable := map[string][]ConfigField{}
+	for _, f := range fields {
+		table, name := "", f.Key
+		if i := strings.LastIndex(f.Key, "."); i >= 0 {
+			table, name = f.Key[:i], f.Key[i+1:]
+		}
+		if !slices.Contains(tables, table) {
+			tables = append(tables, table)
+		}
+
+		f.Key = name
+		byTable[table] = append(byTable[table], f)
+	}
+
+	var buf bytes.Buffer
+	for _, table := range tables {
+		if len(byTable[table]) == 0 {
+			continue
+		}
+		if table != "" {
+			if buf.Len() > 0 {
+				buf.WriteString("\n")
+			}
+			segments := strings.Split(table, ".")
+			for i, s := range segments {
+				segments[i] = tomlKey(s)
+			}
+			fmt.Fprintf(&buf, "[%s]\n", strings.Join(segments, "."))
+		}
+
+		for _, f := range byTable[table] {
+			if comments && f.Description != "" {
+				for _, line := range strings.Split(f.Description, "\n") {
+					fmt.Fprintf(&buf, "# %s\n", line)
+				}
+			}
+			// toml has no null, leave unset values commented out.
+			if f.Value == nil {
+				fmt.Fprintf(&buf, "# %s =\n", tomlKey(f.Key))
+				continue
+			}
+			fmt.Fprintf(&buf, "%s = %s\n", tomlKey(f.Key), tomlValue(reflect.ValueOf(f.Value)))
+		}
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func tomlKey(key string) string {
+	if bareKey.MatchString(key) {
+		return key
+	}
+	return strconv.Quote(key)
+}
+
+func tomlValue(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return `""`
+		}
+		return tomlValue(v.Elem())
+	case reflect.String:
+		return strconv.Quote(v.String())
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 64)
+	case reflect.Slice, reflect.Array:
+		items := make([]string, v.Len())
+		for i := range v.Len() {
+			items[i] = tomlValue(v.Index(i))
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case reflect.Map:
+		items := make([]string, 0, v.Len())
+		for iter := v.MapRange(); iter.Next(); {
+			items = append(items, tomlKey(fmt.Sprint(iter.Key().Interface()))+" = "+tomlValue(iter.Value()))
+		}
+		slices.Sort(items)
+		return "{ " + strings.Join(items, ", ") + " }"
+	default:
+		return strconv.Quote(fmt.Sprint(v.Interface()))
+	}
+}
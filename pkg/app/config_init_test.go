@@ -0,0 +1,105 @@
+package app
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type initOptions struct {
+	Name  string            `json:"name" mapstructure:"name"`
+	Pool  *poolOptions      `json:"pool" mapstructure:"pool"`
+	Tags  []string          `json:"tags" mapstructure:"tags" comment:"Tags of the instance."`
+	Extra map[string]string `json:"extra" mapstructure:"extra"`
+	Token string            `json:"-" mapstructure:"token"`
+}
+
+func (o *initOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&o.Name, "name", o.Name, "Name of the instance.")
+	fs.IntVar(&o.Pool.MaxOpen, "pool.max-open-connections", o.Pool.MaxOpen, "Maximum open connections.")
+}
+
+func (o *initOptions) Complete() error { return nil }
+func (o *initOptions) Validate() error { return nil }
+
+func TestRenderTOML(t *testing.T) {
+	fields := []ConfigField{
+		{Key: "name", Value: "chunyu", Description: "Name of the instance."},
+		{Key: "pool.max-open-connections", Value: 10},
+		{Key: "pool.addr", Value: nil},
+		{Key: "tags", Value: []string{"a", "b"}},
+		{Key: "extra", Value: map[string]string{"b": "2", "a.b": "1"}},
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, RenderConfig(&out, fields, "toml", RenderOptions{ShowDescription: true}))
+	assert.Equal(t, `# Name of the instance.
+name = "chunyu"
+tags = ["a", "b"]
+extra = { "a.b" = "1", b = "2" }
+
+[pool]
+max-open-connections = 10
+# addr =
+`, out.String())
+}
+
+func TestConfigInitCommand(t *testing.T) {
+	t.Cleanup(viper.Reset)
+
+	opts := &initOptions{Name: "chunyu", Pool: &poolOptions{MaxOpen: 10}, Tags: []string{"a"}, Token: "secret"}
+	app := NewApp("chunyu-test", "test", WithSilence(), WithOptions(opts))
+
+	file := filepath.Join(t.TempDir(), "conf", "chunyu.yaml")
+	cmd := app.Command()
+	cmd.SetArgs([]string{"config", "init", file, "--log.dir", t.TempDir()})
+	require.NoError(t, cmd.Execute())
+
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, `# Name of the instance.
+name: chunyu
+pool:
+  # Maximum open connections.
+  max-open-connections: 10
+  addr: ""
+# Tags of the instance.
+tags:
+  - a
+extra: {}
+token: ""
+`, string(data))
+
+	cmd.SetArgs([]string{"config", "init", file, "--log.dir", t.TempDir()})
+	assert.ErrorContains(t, cmd.Execute(), "already exists")
+}
+
+func TestConfigCommands_Output(t *testing.T) {
+	t.Cleanup(viper.Reset)
+
+	opts := &initOptions{Name: "chunyu", Pool: &poolOptions{MaxOpen: 10}}
+	app := NewApp("chunyu-test", "test", WithSilence(), WithOptions(opts))
+	dir := t.TempDir()
+
+	run := func(args ...string) string {
+		t.Helper()
+		var out bytes.Buffer
+		cmd := app.Command()
+		cmd.SetOut(&out)
+		cmd.SetArgs(append(args, "--log.dir", dir))
+		require.NoError(t, cmd.Execute())
+		return out.String()
+	}
+
+	// Every config command writes to the output of the command.
+	assert.Contains(t, run("config", "init"), "name: chunyu\n")
+	file := filepath.Join(dir, "chunyu.json")
+	assert.Equal(t, "Configuration written to "+file+"\n", run("config", "init", file))
+	assert.Contains(t, run("config", "view", "-o", "toml", "--show-source=false"), `name = "chunyu"`)
+}
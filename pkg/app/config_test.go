@@ -0,0 +1,64 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"chunyu/pkg/config"
+)
+
+func TestApp_ConfigChangeHooks(t *testing.T) {
+	t.Cleanup(viper.Reset)
+
+	// Every application keeps its own hooks, building another application
+	// does not register them twice.
+	first := NewApp("chunyu-test", "test", WithSilence(), WithWatchConfig())
+	second := NewApp("chunyu-test", "test", WithSilence(), WithWatchConfig())
+	assert.Len(t, first.config.hooks, 1)
+	assert.Len(t, second.config.hooks, 1)
+	assert.NotSame(t, first.Reloader(), second.Reloader())
+}
+
+// failingSource is a config.Source which cannot be reached.
+type failingSource struct{}
+
+func (failingSource) Load(context.Context) (map[string]any, error) {
+	return nil, errors.New("connection refused")
+}
+func (failingSource) Watch(context.Context, func()) error { return errors.New("connection refused") }
+func (failingSource) String() string                      { return "failing" }
+
+func TestApp_ConfigLoadError(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	t.Cleanup(func() { cfgFile = "" })
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "chunyu.yaml")
+	require.NoError(t, os.WriteFile(file, []byte("name: chunyu\n"), 0o600))
+
+	run := func(app *App, args ...string) error {
+		cmd := app.Command()
+		cmd.SetArgs(append(args, "--log.dir", dir))
+		return cmd.Execute()
+	}
+
+	// A config file given explicitly must exist.
+	app := NewApp("chunyu-test", "test", WithSilence())
+	assert.ErrorContains(t, run(app, "--config", filepath.Join(dir, "missing.yaml")), "failed to read configuration")
+
+	// The application does not start on the defaults when a source fails,
+	// even though the local file could be read.
+	app = NewApp("chunyu-test", "test", WithSilence(), WithConfigOptions(config.WithSources(failingSource{})))
+	assert.ErrorContains(t, run(app, "--config", file), "connection refused")
+
+	app = NewApp("chunyu-test", "test", WithSilence())
+	require.NoError(t, run(app, "--config", file))
+	assert.Equal(t, "chunyu", viper.GetString("name"))
+}
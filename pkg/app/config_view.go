@@ -0,0 +1,279 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// Sources of configuration values, in increasing order of precedence.
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+)
+
+// ConfigField is a single option of the application options.
+type ConfigField struct {
+	// Key is the configuration key of the option, e.g. mysql.addr.
+	Key string
+	// Value is the effective value, masked if the option is sensitive.
+	Value any
+	// Source is where the value comes from: default, file, env or flag.
+	Source string
+	// Sensitive is true for options tagged `sensitive:"true"` or `json:"-"`,
+	// and for options resolved from a secret reference.
+	Sensitive bool
+	// Description is taken from the comment tag of the field, or from the
+	// usage of the flag named after Key.
+	Description string
+}
+
+// RenderOptions controls the output of RenderConfig.
+type RenderOptions struct {
+	// ShowSource adds the source of every value as a yaml line comment, or in
+	// json replaces every value with an object holding the value and its
+	// source. It is ignored for toml.
+	ShowSource bool
+	// ShowDescription adds the description of every value as a yaml or toml
+	// comment above it. It is ignored for json.
+	ShowDescription bool
+}
+
+// ConfigFields walks the typed options and returns every option with its
+// effective value and source. Struct fields are named after their
+// mapstructure tag, falling back to the json tag. fs is used to detect values
+// set on the command line and to describe the options; it may be nil.
+func ConfigFields(opts any, fs *pflag.FlagSet) []ConfigField {
+	var fields []ConfigField
+	walkOptions("", reflect.ValueOf(opts), "", false, func(key string, v reflect.Value, tag reflect.StructTag, sensitive bool) {
+		sensitive = sensitive || isSecret(key)
+
+		var value any
+		switch {
+		case !v.IsValid():
+		case sensitive && !v.IsZero():
+			value = redacted
+		case v.Type() == reflect.TypeOf(time.Duration(0)):
+			value = v.Interface().(time.Duration).String()
+		default:
+			value = v.Interface()
+		}
+
+		description := tag.Get("comment")
+		if description == "" && fs != nil {
+			if f := fs.Lookup(key); f != nil {
+				description = f.Usage
+			}
+		}
+
+		fields = append(fields, ConfigField{
+			Key:         key,
+			Value:       value,
+			Source:      valueSource(key, fs),
+			Sensitive:   sensitive,
+			Description: description,
+		})
+	})
+
+	return fields
+}
+
+// RenderConfig writes fields as a yaml, toml or json document.
+func RenderConfig(w io.Writer, fields []ConfigField, output string, opts RenderOptions) error {
+	switch output {
+	case "yaml", "yml":
+		root := &yaml.Node{Kind: yaml.MappingNode}
+		for _, f := range fields {
+			parent, name := root, f.Key
+			for {
+				head, rest, ok := strings.Cut(name, ".")
+				if !ok {
+					break
+				}
+				parent, name = yamlChild(parent, head), rest
+			}
+
+			key := &yaml.Node{Kind: yaml.ScalarNode, Value: name}
+			value := &yaml.Node{}
+			if err := value.Encode(f.Value); err != nil {
+				return fmt.Errorf("failed to encode %s: %w", f.Key, err)
+			}
+			if opts.ShowSource {
+				value.LineComment = f.Source
+			}
+			if opts.ShowDescription {
+				key.HeadComment = f.Description
+			}
+			parent.Content = append(parent.Content, key, value)
+		}
+
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(root); err != nil {
+			return err
+		}
+		return enc.Close()
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(configTree(fields, opts.ShowSource))
+	case "toml":
+		return renderTOML(w, fields, opts.ShowDescription)
+	default:
+		return fmt.Errorf("unsupported output format %q, must be yaml, toml or json", output)
+	}
+}
+
+// configTree nests fields into maps by the dot separated segments of their keys.
+func configTree(fields []ConfigField, showSource bool) map[string]any {
+	root := map[string]any{}
+	for _, f := range fields {
+		parent, name := root, f.Key
+		for {
+			head, rest, ok := strings.Cut(name, ".")
+			if !ok {
+				break
+			}
+			child, ok := parent[head].(map[string]any)
+			if !ok {
+				child = map[string]any{}
+				parent[head] = child
+			}
+			parent, name = child, rest
+		}
+
+		if showSource {
+			parent[name] = map[string]any{"value": f.Value, "source": f.Source}
+		} else {
+			parent[name] = f.Value
+		}
+	}
+
+	return root
+}
+
+// yamlChild returns the mapping node under name in parent, creating it if
+// needed.
+func yamlChild(parent *yaml.Node, name string) *yaml.Node {
+	for i := 0; i+1 < len(parent.Content); i += 2 {
+		if parent.Content[i].Value == name && parent.Content[i+1].Kind == yaml.MappingNode {
+			return parent.Content[i+1]
+		}
+	}
+
+	child := &yaml.Node{Kind: yaml.MappingNode}
+	parent.Content = append(parent.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: name}, child)
+	return child
+}
+
+// walkOptions calls fn for every leaf option below v. Nested structs whose
+// fields are all exported are walked, other values are leaves.
+func walkOptions(path string, v reflect.Value, tag reflect.StructTag, sensitive bool, fn func(key string, v reflect.Value, tag reflect.StructTag, sensitive bool)) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			if path != "" {
+				fn(path, reflect.Value{}, tag, sensitive)
+			}
+			return
+		}
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Struct || !exportedOnly(v.Type()) {
+		if path != "" {
+			fn(path, v, tag, sensitive)
+		}
+		return
+	}
+
+	for i := range v.NumField() {
+		field := v.Type().Field(i)
+		name, squash, ok := optionKey(field)
+		if !ok {
+			continue
+		}
+
+		sub := path
+		if !squash {
+			sub = strings.TrimPrefix(path+"."+name, ".")
+		}
+		walkOptions(sub, v.Field(i), field.Tag, sensitive || isSensitive(field), fn)
+	}
+}
+
+// optionKey returns the configuration key segment of a struct field, whether
+// the field is squashed into its parent, and false if the field is skipped.
+func optionKey(field reflect.StructField) (string, bool, bool) {
+	name, opts, _ := strings.Cut(field.Tag.Get("mapstructure"), ",")
+	if name == "-" {
+		return "", false, false
+	}
+	if name == "" && !strings.Contains(opts, "squash") {
+		name, _, _ = strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			name = strings.ToLower(field.Name)
+		}
+	}
+
+	return name, strings.Contains(opts, "squash"), true
+}
+
+// isSensitive reports whether the value of field must be masked when printed.
+func isSensitive(field reflect.StructField) bool {
+	return field.Tag.Get("sensitive") == "true" || field.Tag.Get("json") == "-"
+}
+
+// valueSource returns where viper takes the value of key from.
+func valueSource(key string, fs *pflag.FlagSet) string {
+	if fs != nil {
+		if f := fs.Lookup(key); f != nil && f.Changed {
+			return SourceFlag
+		}
+	}
+
+	if envPrefix != "" {
+		env := strings.ToUpper(envPrefix + "_" + envKeyReplacer.Replace(key))
+		if _, ok := os.LookupEnv(env); ok {
+			return SourceEnv
+		}
+	}
+
+	if viper.InConfig(key) {
+		return SourceFile
+	}
+
+	return SourceDefault
+}
+
+// configCommand builds the `config view` and `config init` sub commands.
+func (app *App) configCommand() *Command {
+	output, showSource := "yaml", true
+
+	view := NewCommand("view", "Print the effective configuration",
+		WithCommandDescription(`Print the effective configuration after merging defaults, the config file,
+environment variables and flags. Sensitive values are masked.`),
+		WithCommandOptions(app.options),
+		WithCommandValidArgs(cobra.NoArgs),
+		WithCommandFlags(func(fs *pflag.FlagSet) {
+			fs.StringVarP(&output, "output", "o", output, "Output format. One of: yaml, toml, json.")
+			fs.BoolVar(&showSource, "show-source", showSource, "Show where every value comes from: default, file, env or flag.")
+		}),
+	)
+	view.run = func([]string) error {
+		fields := ConfigFields(app.options, view.Command().Flags())
+		return RenderConfig(view.Command().OutOrStdout(), fields, output, RenderOptions{ShowSource: showSource})
+	}
+
+	return NewCommand("config", "Inspect the application configuration", WithSubCommands(view, app.configInitCommand()))
+}
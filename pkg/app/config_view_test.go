@@ -0,0 +1,65 @@
+package app
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type viewOptions struct {
+	MySQL struct {
+		Addr     string        `json:"addr" mapstructure:"addr"`
+		Password string        `json:"-" mapstructure:"password"`
+		Timeout  time.Duration `json:"timeout" mapstructure:"timeout"`
+	} `json:"mysql" mapstructure:"mysql"`
+	Token  string `json:"token" mapstructure:"token" sensitive:"true"`
+	Empty  string `json:"empty" sensitive:"true"`
+	Hidden string `mapstructure:"-"`
+}
+
+func TestConfigFields(t *testing.T) {
+	t.Cleanup(viper.Reset)
+
+	viper.SetConfigType("yaml")
+	require.NoError(t, viper.ReadConfig(bytes.NewBufferString("mysql:\n  addr: db:3306\n")))
+
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	fs.String("token", "", "")
+	require.NoError(t, fs.Parse([]string{"--token=abc"}))
+
+	opts := &viewOptions{}
+	opts.MySQL.Addr = "db:3306"
+	opts.MySQL.Password = "secret"
+	opts.MySQL.Timeout = 3 * time.Second
+	opts.Token = "abc"
+
+	fields := ConfigFields(opts, fs)
+	assert.Equal(t, []ConfigField{
+		{Key: "mysql.addr", Value: "db:3306", Source: SourceFile},
+		{Key: "mysql.password", Value: redacted, Source: SourceDefault, Sensitive: true},
+		{Key: "mysql.timeout", Value: "3s", Source: SourceDefault},
+		{Key: "token", Value: redacted, Source: SourceFlag, Sensitive: true},
+		{Key: "empty", Value: "", Source: SourceDefault, Sensitive: true},
+	}, fields)
+
+	var out bytes.Buffer
+	require.NoError(t, RenderConfig(&out, fields, "yaml", RenderOptions{ShowSource: true}))
+	assert.Equal(t, `mysql:
+  addr: db:3306 # file
+  password: '******' # default
+  timeout: 3s # default
+token: '******' # flag
+empty: "" # default
+`, out.String())
+
+	out.Reset()
+	require.NoError(t, RenderConfig(&out, fields[:1], "json", RenderOptions{}))
+	assert.JSONEq(t, `{"mysql": {"addr": "db:3306"}}`, out.String())
+
+	assert.Error(t, RenderConfig(&out, fields, "ini", RenderOptions{}))
+}
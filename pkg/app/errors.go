@@ -0,0 +1,34 @@
+package app
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"chunyu/pkg/errorsx"
+)
+
+// WithErrorCatalog adds the `errors` sub command, which prints the errors
+// declared in errorsx.DefaultRegistry as a Markdown table, a JSON document or
+// the components section of an OpenAPI document.
+func WithErrorCatalog() Option {
+	return WithCommands(errorsCommand(errorsx.DefaultRegistry))
+}
+
+// errorsCommand builds the `errors` sub command printing the catalog of reg.
+func errorsCommand(reg *errorsx.Registry) *Command {
+	output := errorsx.CatalogMarkdown
+
+	c := NewCommand("errors", "Print the catalog of the declared errors",
+		WithCommandDescription(`Print every error declared by the application with its reason, HTTP code,
+gRPC code, message template and description, so that clients can handle them.`),
+		WithCommandValidArgs(cobra.NoArgs),
+		WithCommandFlags(func(fs *pflag.FlagSet) {
+			fs.StringVarP(&output, "output", "o", output, "Output format. One of: markdown, json, openapi.")
+		}),
+	)
+	c.run = func([]string) error {
+		return errorsx.WriteCatalog(c.Command().OutOrStdout(), reg.Definitions(), output)
+	}
+
+	return c
+}
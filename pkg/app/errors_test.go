@@ -0,0 +1,55 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"chunyu/pkg/errorsx"
+)
+
+func TestErrorsCommand(t *testing.T) {
+	t.Cleanup(viper.Reset)
+
+	reg := errorsx.NewRegistry()
+	reg.MustRegister(errorsx.Definition{Code: http.StatusNotFound, Reason: "NotFound.User", Message: "User %s not found.", Doc: "The user does not exist."})
+
+	app := NewApp("chunyu-test", "test", WithSilence(), WithNoConfig(), WithCommands(errorsCommand(reg)))
+	cmd := app.Command()
+
+	run := func(args ...string) (string, error) {
+		var out bytes.Buffer
+		cmd.SetOut(&out)
+		cmd.SetArgs(append([]string{"errors", "--log.dir", t.TempDir()}, args...))
+		err := cmd.Execute()
+		return out.String(), err
+	}
+
+	out, err := run()
+	require.NoError(t, err)
+	assert.Contains(t, out, "| `NotFound.User` | 404 | NotFound | User %s not found. | The user does not exist. |\n")
+
+	out, err = run("-o", "json")
+	require.NoError(t, err)
+	var entries []errorsx.CatalogEntry
+	require.NoError(t, json.Unmarshal([]byte(out), &entries))
+	assert.Equal(t, []errorsx.CatalogEntry{{Reason: "NotFound.User", Code: 404, GRPCCode: "NotFound", Message: "User %s not found.", Doc: "The user does not exist."}}, entries)
+
+	out, err = run("--output", "openapi")
+	require.NoError(t, err)
+	var doc struct {
+		Components struct {
+			Responses map[string]any `json:"responses"`
+		} `json:"components"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(out), &doc))
+	assert.Contains(t, doc.Components.Responses, "NotFound.User")
+
+	_, err = run("-o", "xml")
+	assert.ErrorContains(t, err, `unsupported catalog format "xml"`)
+}
@@ -0,0 +1,202 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+const (
+	// defaultHookTimeout is used for hooks which do not set their own timeout.
+	defaultHookTimeout = 15 * time.Second
+	// defaultShutdownTimeout bounds the total time spent running stop hooks.
+	defaultShutdownTimeout = 30 * time.Second
+)
+
+// Hook is a pair of start and stop callbacks for a component managed by
+// the Lifecycle. Either callback may be nil.
+type Hook struct {
+	// Name identifies the hook in logs and errors.
+	Name string
+
+	// OnStart is called in registration order. It must not block: long running
+	// work such as serving requests should be started in a goroutine.
+	OnStart func(ctx context.Context) error
+
+	// OnStop is called in reverse registration order, and only if OnStart
+	// succeeded (or was nil).
+	OnStop func(ctx context.Context) error
+
+	// Timeout bounds each of OnStart and OnStop. Zero means the lifecycle
+	// default hook timeout.
+	Timeout time.Duration
+}
+
+// Lifecycle coordinates ordered start up and reverse-ordered shut down of
+// application components.
+// It is recommended that a lifecycle be created with the NewLifecycle() function.
+type Lifecycle struct {
+	mu      sync.Mutex
+	hooks   []Hook
+	started int
+
+	hookTimeout     time.Duration
+	shutdownTimeout time.Duration
+}
+
+// LifecycleOption defines optional parameters for initializing the lifecycle.
+type LifecycleOption func(*Lifecycle)
+
+// WithHookTimeout sets the default timeout applied to hooks which do not set
+// their own.
+func WithHookTimeout(d time.Duration) LifecycleOption {
+	return func(lc *Lifecycle) {
+		lc.hookTimeout = d
+	}
+}
+
+// WithShutdownTimeout sets the global deadline for running all stop hooks.
+func WithShutdownTimeout(d time.Duration) LifecycleOption {
+	return func(lc *Lifecycle) {
+		lc.shutdownTimeout = d
+	}
+}
+
+// NewLifecycle creates a new lifecycle with the given options.
+func NewLifecycle(opts ...LifecycleOption) *Lifecycle {
+	lc := &Lifecycle{
+		hookTimeout:     defaultHookTimeout,
+		shutdownTimeout: defaultShutdownTimeout,
+	}
+
+	for _, o := range opts {
+		o(lc)
+	}
+
+	return lc
+}
+
+// Append registers hooks. Hooks are started in the order they are appended
+// and stopped in reverse order.
+func (lc *Lifecycle) Append(hooks ...Hook) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	lc.hooks = append(lc.hooks, hooks...)
+}
+
+// Start runs all OnStart hooks in order. If a hook fails, the hooks that were
+// already started are stopped in reverse order and the aggregated error is
+// returned.
+func (lc *Lifecycle) Start(ctx context.Context) error {
+	lc.mu.Lock()
+	hooks := lc.hooks
+	lc.mu.Unlock()
+
+	for _, hook := range hooks {
+		if err := ctx.Err(); err != nil {
+			return lc.rollback(fmt.Errorf("start aborted: %w", err))
+		}
+
+		if hook.OnStart != nil {
+			slog.Debug("Starting component", "hook", hook.Name)
+			if err := lc.runHook(ctx, hook, hook.OnStart); err != nil {
+				return lc.rollback(fmt.Errorf("start hook %q: %w", hook.Name, err))
+			}
+		}
+
+		lc.mu.Lock()
+		lc.started++
+		lc.mu.Unlock()
+	}
+
+	return nil
+}
+
+// Stop runs the OnStop hooks of all started components in reverse order.
+// Every hook is called even if a previous one failed; all errors are
+// aggregated. The whole shutdown is bounded by the shutdown timeout.
+func (lc *Lifecycle) Stop(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, lc.shutdownTimeout)
+	defer cancel()
+
+	lc.mu.Lock()
+	hooks := lc.hooks[:lc.started]
+	lc.started = 0
+	lc.mu.Unlock()
+
+	var errs []error
+	for i := len(hooks) - 1; i >= 0; i-- {
+		hook := hooks[i]
+		if hook.OnStop == nil {
+			continue
+		}
+
+		slog.Debug("Stopping component", "hook", hook.Name)
+		if err := lc.runHook(ctx, hook, hook.OnStop); err != nil {
+			errs = append(errs, fmt.Errorf("stop hook %q: %w", hook.Name, err))
+		}
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
+
+// Run starts all hooks, blocks until ctx is done, then stops them.
+func (lc *Lifecycle) Run(ctx context.Context) error {
+	if err := lc.Start(ctx); err != nil {
+		return err
+	}
+
+	<-ctx.Done()
+	slog.Info("Shutting down application", "timeout", lc.shutdownTimeout)
+
+	// Use a fresh context: ctx is already cancelled at this point.
+	return lc.Stop(context.Background())
+}
+
+// rollback stops already started hooks after a failed start and returns the
+// start error combined with any stop errors.
+func (lc *Lifecycle) rollback(err error) error {
+	if stopErr := lc.Stop(context.Background()); stopErr != nil {
+		return utilerrors.NewAggregate([]error{err, stopErr})
+	}
+
+	return err
+}
+
+// runHook calls fn with a context bounded by the hook timeout. fn is run in its
+// own goroutine so that a hook which ignores its context can not block the
+// lifecycle past the deadline.
+func (lc *Lifecycle) runHook(ctx context.Context, hook Hook, fn func(context.Context) error) error {
+	timeout := hook.Timeout
+	if timeout <= 0 {
+		timeout = lc.hookTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- fn(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// signalContext returns a context which is cancelled on SIGINT or SIGTERM.
+func signalContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
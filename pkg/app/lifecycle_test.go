@@ -0,0 +1,92 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func recordHook(name string, calls *[]string, startErr error) Hook {
+	return Hook{
+		Name: name,
+		OnStart: func(context.Context) error {
+			*calls = append(*calls, "start:"+name)
+			return startErr
+		},
+		OnStop: func(context.Context) error {
+			*calls = append(*calls, "stop:"+name)
+			return nil
+		},
+	}
+}
+
+func TestLifecycle_StartStopOrder(t *testing.T) {
+	var calls []string
+	lc := NewLifecycle()
+	lc.Append(recordHook("a", &calls, nil), recordHook("b", &calls, nil), recordHook("c", &calls, nil))
+
+	assert.NoError(t, lc.Start(context.Background()))
+	assert.NoError(t, lc.Stop(context.Background()))
+
+	assert.Equal(t, []string{"start:a", "start:b", "start:c", "stop:c", "stop:b", "stop:a"}, calls)
+}
+
+func TestLifecycle_StartFailureRollsBack(t *testing.T) {
+	var calls []string
+	lc := NewLifecycle()
+	lc.Append(recordHook("a", &calls, nil), recordHook("b", &calls, errors.New("boom")), recordHook("c", &calls, nil))
+
+	err := lc.Start(context.Background())
+	assert.ErrorContains(t, err, `start hook "b": boom`)
+
+	// Only the hooks started before the failure are stopped.
+	assert.Equal(t, []string{"start:a", "start:b", "stop:a"}, calls)
+}
+
+func TestLifecycle_StopAggregatesErrors(t *testing.T) {
+	lc := NewLifecycle()
+	lc.Append(
+		Hook{Name: "a", OnStop: func(context.Context) error { return errors.New("a failed") }},
+		Hook{Name: "b", OnStop: func(context.Context) error { return errors.New("b failed") }},
+	)
+
+	assert.NoError(t, lc.Start(context.Background()))
+
+	err := lc.Stop(context.Background())
+	assert.ErrorContains(t, err, `stop hook "b": b failed`)
+	assert.ErrorContains(t, err, `stop hook "a": a failed`)
+}
+
+func TestLifecycle_HookTimeout(t *testing.T) {
+	lc := NewLifecycle(WithHookTimeout(10 * time.Millisecond))
+	lc.Append(Hook{
+		Name: "stuck",
+		OnStop: func(context.Context) error {
+			// Ignore the context on purpose.
+			time.Sleep(time.Second)
+			return nil
+		},
+	})
+
+	assert.NoError(t, lc.Start(context.Background()))
+
+	start := time.Now()
+	err := lc.Stop(context.Background())
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+}
+
+func TestLifecycle_Run(t *testing.T) {
+	var calls []string
+	lc := NewLifecycle()
+	lc.Append(recordHook("a", &calls, nil))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	assert.NoError(t, lc.Run(ctx))
+	assert.Equal(t, []string{"start:a", "stop:a"}, calls)
+}
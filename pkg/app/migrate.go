@@ -0,0 +1,182 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/gosuri/uitable"
+	"github.com/spf13/cobra"
+	"gorm.io/gorm"
+
+	"chunyu/pkg/db"
+)
+
+// migrations holds the configuration of the migrate subcommand.
+type migrations struct {
+	dir   string
+	newDB func() (*gorm.DB, error)
+}
+
+// WithMigrations adds the `migrate up|down|status|create` subcommands to the
+// application. Migration files are read from dir, which can be overridden
+// with the --migrations-dir flag. newDB is called after the options have been
+// loaded, completed and validated and the logger initialized, so it can build
+// the connection from them.
+func WithMigrations(dir string, newDB func() (*gorm.DB, error)) Option {
+	return func(app *App) {
+		app.migrations = &migrations{dir: dir, newDB: newDB}
+	}
+}
+
+// migrateCommand builds the migrate subcommand.
+func (app *App) migrateCommand() *cobra.Command {
+	dir := app.migrations.dir
+
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Manage database schema migrations",
+		Long: `Apply, revert and inspect versioned SQL migrations.
+
+Migration files are named <version>_<name>.up.sql and <version>_<name>.down.sql.
+Applied versions and their checksums are recorded in the schema_migrations table.`,
+	}
+	cmd.PersistentFlags().StringVar(&dir, "migrations-dir", dir, "Directory containing the migration files.")
+
+	// The root command only adds named flag sets to its local flags, add them
+	// here so that the database can be configured on the command line.
+	if typed, ok := app.options.(NamedFlagSetOptions); ok {
+		fss := typed.Flags()
+		for _, name := range fss.Order {
+			if name != "global" {
+				cmd.PersistentFlags().AddFlagSet(fss.FlagSets[name])
+			}
+		}
+	}
+
+	newMigrator := func(cmd *cobra.Command) (*db.Migrator, error) {
+		if err := app.prepareCommand(cmd, app.options); err != nil {
+			return nil, err
+		}
+
+		gdb, err := app.migrations.newDB()
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to database: %w", err)
+		}
+
+		return db.NewMigrator(gdb, os.DirFS(dir)), nil
+	}
+
+	cmd.AddCommand(
+		&cobra.Command{
+			Use:   "up [N]",
+			Short: "Apply all or N pending migrations",
+			Args:  cobra.MaximumNArgs(1),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				n, err := countArg(args)
+				if err != nil {
+					return err
+				}
+				m, err := newMigrator(cmd)
+				if err != nil {
+					return err
+				}
+
+				done, err := m.Up(context.Background(), n)
+				printMigrations(cmd, "Applied", done)
+				return err
+			},
+		},
+		&cobra.Command{
+			Use:   "down [N]",
+			Short: "Revert the last or N last applied migrations",
+			Args:  cobra.MaximumNArgs(1),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				n, err := countArg(args)
+				if err != nil {
+					return err
+				}
+				m, err := newMigrator(cmd)
+				if err != nil {
+					return err
+				}
+
+				done, err := m.Down(context.Background(), n)
+				printMigrations(cmd, "Reverted", done)
+				return err
+			},
+		},
+		&cobra.Command{
+			Use:   "status",
+			Short: "Show the state of every migration",
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				m, err := newMigrator(cmd)
+				if err != nil {
+					return err
+				}
+
+				statuses, err := m.Status(context.Background())
+				if err != nil {
+					return err
+				}
+
+				table := uitable.New()
+				table.AddRow("VERSION", "NAME", "STATUS", "APPLIED AT")
+				for _, st := range statuses {
+					state, appliedAt := "pending", ""
+					if st.Applied {
+						state, appliedAt = "applied", st.AppliedAt.Format("2006-01-02 15:04:05")
+					}
+					if st.Modified {
+						state += " (modified)"
+					}
+					if st.Missing {
+						state += " (missing)"
+					}
+					table.AddRow(st.Version, st.Name, state, appliedAt)
+				}
+				fmt.Fprintln(cmd.OutOrStdout(), table)
+				return nil
+			},
+		},
+		&cobra.Command{
+			Use:   "create NAME",
+			Short: "Create empty up and down scripts for a new migration",
+			Args:  cobra.ExactArgs(1),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				files, err := db.CreateMigration(dir, args[0])
+				for _, file := range files {
+					fmt.Fprintf(cmd.OutOrStdout(), "Created %s\n", file)
+				}
+				return err
+			},
+		},
+	)
+
+	return cmd
+}
+
+// countArg parses the optional migration count argument.
+func countArg(args []string) (int, error) {
+	if len(args) == 0 {
+		return 0, nil
+	}
+
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid migration count %q, must be a positive integer", args[0])
+	}
+
+	return n, nil
+}
+
+func printMigrations(cmd *cobra.Command, verb string, migrations []*db.Migration) {
+	for _, m := range migrations {
+		fmt.Fprintf(cmd.OutOrStdout(), "%s %d_%s\n", verb, m.Version, m.Name)
+	}
+	if len(migrations) == 0 {
+		fmt.Fprintln(cmd.OutOrStdout(), "No migrations to run")
+	}
+}
@@ -0,0 +1,70 @@
+package app
+
+import (
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	cliflag "k8s.io/component-base/cli/flag"
+)
+
+type migrateOptions struct {
+	MySQL struct {
+		Host string `mapstructure:"host"`
+	} `mapstructure:"mysql"`
+}
+
+func (o *migrateOptions) Flags() (fss cliflag.NamedFlagSets) {
+	fss.FlagSet("mysql").StringVar(&o.MySQL.Host, "mysql.host", o.MySQL.Host, "MySQL host.")
+	return fss
+}
+
+func (o *migrateOptions) Complete() error { return nil }
+func (o *migrateOptions) Validate() error { return nil }
+
+func TestMigrateCommand_OptionFlags(t *testing.T) {
+	t.Cleanup(viper.Reset)
+
+	opts := &migrateOptions{}
+	var host string
+	errNoDB := errors.New("no database")
+	app := NewApp("chunyu-test", "test", WithSilence(), WithNoConfig(), WithOptions(opts),
+		WithMigrations(t.TempDir(), func() (*gorm.DB, error) {
+			host = opts.MySQL.Host
+			return nil, errNoDB
+		}),
+	)
+
+	for _, sub := range []string{"up", "down", "status"} {
+		host = ""
+		cmd := app.Command()
+		cmd.SetArgs([]string{"migrate", sub, "--mysql.host=db.local", "--log.dir", t.TempDir()})
+		require.ErrorIs(t, cmd.Execute(), errNoDB, sub)
+		assert.Equal(t, "db.local", host, sub)
+	}
+}
+
+func TestMigrateCommand_PrepareCommand(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	logger := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(logger) })
+
+	called := false
+	app := NewApp("chunyu-test", "test", WithSilence(), WithNoConfig(), WithOptions(&migrateOptions{}),
+		WithMigrations(t.TempDir(), func() (*gorm.DB, error) {
+			called = true
+			// The logger is set up before connecting to the database.
+			assert.NotSame(t, logger, slog.Default())
+			return nil, errors.New("no database")
+		}),
+	)
+
+	cmd := app.Command()
+	cmd.SetArgs([]string{"migrate", "status", "--log.dir", t.TempDir()})
+	require.Error(t, cmd.Execute())
+	assert.True(t, called)
+}
@@ -0,0 +1,301 @@
+package app
+
+import (
+	"fmt"
+	"log/slog"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/spf13/viper"
+
+	"chunyu/pkg/log"
+)
+
+// FieldChange describes a single option which changed on reload.
+type FieldChange struct {
+	// Path is the configuration key of the option, e.g. mysql.max-open-connections.
+	Path string
+	Old  any
+	New  any
+}
+
+// ConfigChange is published to the subscribers of a Reloader after the
+// options have been reloaded.
+type ConfigChange struct {
+	// Old and New are copies of the options before and after the reload.
+	// They have the same type as the options given to the application.
+	Old any
+	New any
+
+	Changes []FieldChange
+}
+
+// Changed reports whether the option at path, or any option below it,
+// changed. For example Changed("mysql") is true when mysql.max-open-connections
+// changed.
+func (c ConfigChange) Changed(path string) bool {
+	for _, fc := range c.Changes {
+		if fc.Path == path || strings.HasPrefix(fc.Path, path+".") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Reloader re-reads the application options when the configuration file
+// changes. An update is unmarshalled into a copy of the options, completed and
+// validated; invalid updates are rejected. Valid updates are published as a
+// new snapshot, see Options, and to the subscribers. The options given to the
+// application are never modified, so every snapshot can be read concurrently
+// without locking.
+type Reloader struct {
+	mu          sync.Mutex
+	logLevel    string
+	subscribers []func(ConfigChange)
+
+	// current holds the latest valid options.
+	current atomic.Pointer[any]
+}
+
+// NewReloader creates a reloader. Pass it to the application with
+// WithReloader and to the components which should react to reloads.
+func NewReloader() *Reloader {
+	return &Reloader{}
+}
+
+// Subscribe registers fn to be called after every successful reload which
+// changed at least one option. Subscribers are called in registration order
+// once the new options are published, without holding any lock of r, so fn
+// may call Subscribe or Reload.
+func (r *Reloader) Subscribe(fn func(ConfigChange)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.subscribers = append(r.subscribers, fn)
+}
+
+// OnReload registers a typed subscriber on r. T must be the type of the
+// options given to the application, usually a pointer to a struct.
+func OnReload[T any](r *Reloader, fn func(old, new T, change ConfigChange)) {
+	r.Subscribe(func(change ConfigChange) {
+		old, ok1 := change.Old.(T)
+		updated, ok2 := change.New.(T)
+		if ok1 && ok2 {
+			fn(old, updated, change)
+		}
+	})
+}
+
+// Options returns the latest valid options, or nil before the application
+// started. They have the type of the options given to the application. A
+// reload replaces the snapshot instead of modifying it, so the returned
+// options must not be modified and stay consistent while they are used.
+func (r *Reloader) Options() any {
+	if current := r.current.Load(); current != nil {
+		return *current
+	}
+
+	return nil
+}
+
+// Current returns the latest valid options of r, see Reloader.Options. T must
+// be the type of the options given to the application; the zero value is
+// returned before the application started.
+func Current[T any](r *Reloader) T {
+	current, _ := r.Options().(T)
+	return current
+}
+
+// bind sets the options reloaded by r. opts must be a pointer.
+func (r *Reloader) bind(opts any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.current.Store(&opts)
+	r.logLevel = viper.GetString("log.level")
+}
+
+// Reload unmarshals the current viper configuration into a copy of the
+// options and publishes it. It is called when the watched configuration file
+// changes, and can be called directly, e.g. on SIGHUP.
+func (r *Reloader) Reload() error {
+	change, subscribers, err := r.reload()
+	if err != nil || change == nil {
+		return err
+	}
+
+	for _, fn := range subscribers {
+		fn(*change)
+	}
+
+	return nil
+}
+
+// reload publishes the reloaded options. It returns the change to publish to
+// subscribers, or nil if no option changed.
+func (r *Reloader) reload() (*ConfigChange, []func(ConfigChange), error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current := r.Options()
+	if current == nil {
+		r.applyLogLevel()
+		return nil, nil, nil
+	}
+
+	live := reflect.ValueOf(current)
+	if live.Kind() != reflect.Pointer || live.IsNil() {
+		return nil, nil, fmt.Errorf("options must be a non-nil pointer, got %T", current)
+	}
+
+	candidate := cloneValue(live)
+	if err := loadInto(candidate.Interface()); err != nil {
+		slog.Warn("Rejected invalid configuration update", "err", err)
+		return nil, nil, err
+	}
+	// The log level is part of the update, only apply it once the update is
+	// known to be valid.
+	r.applyLogLevel()
+
+	var changes []FieldChange
+	diffValue("", live, candidate, &changes)
+	if len(changes) == 0 {
+		return nil, nil, nil
+	}
+
+	updated := candidate.Interface()
+	r.current.Store(&updated)
+
+	for _, fc := range changes {
+		slog.Info("Configuration changed", "key", fc.Path)
+	}
+
+	return &ConfigChange{
+		Old:     cloneValue(live).Interface(),
+		New:     cloneValue(candidate).Interface(),
+		Changes: changes,
+	}, slices.Clone(r.subscribers), nil
+}
+
+// applyLogLevel sets the level of the logger to the log.level of viper if it
+// changed. r.mu must be held.
+func (r *Reloader) applyLogLevel() {
+	if level := viper.GetString("log.level"); level != r.logLevel {
+		log.SetLevel(level)
+		slog.Info("Log level changed", "old", r.logLevel, "new", level)
+		r.logLevel = level
+	}
+}
+
+// loadInto unmarshals viper into opts, with secret references resolved, then
+// completes and validates it.
+func loadInto(opts any) error {
+	v, err := resolveSecrets()
+	if err != nil {
+		return err
+	}
+
+	if err := v.Unmarshal(opts); err != nil {
+		return err
+	}
+
+	if complete, ok := opts.(interface{ Complete() error }); ok {
+		if err := complete.Complete(); err != nil {
+			return err
+		}
+	}
+
+	if validate, ok := opts.(interface{ Validate() error }); ok {
+		if err := validate.Validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// cloneValue returns a deep copy of v. Unexported fields are copied shallowly.
+func cloneValue(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type().Elem())
+		c.Elem().Set(cloneValue(v.Elem()))
+		return c
+	case reflect.Struct:
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		for i := range v.NumField() {
+			if c.Field(i).CanSet() {
+				c.Field(i).Set(cloneValue(v.Field(i)))
+			}
+		}
+		return c
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := range v.Len() {
+			c.Index(i).Set(cloneValue(v.Index(i)))
+		}
+		return c
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeMapWithSize(v.Type(), v.Len())
+		for iter := v.MapRange(); iter.Next(); {
+			c.SetMapIndex(iter.Key(), cloneValue(iter.Value()))
+		}
+		return c
+	default:
+		return v
+	}
+}
+
+// diffValue appends the options which differ between old and new to changes.
+// Paths are built from the mapstructure tags, like viper keys.
+func diffValue(path string, old, new reflect.Value, changes *[]FieldChange) {
+	if old.Kind() == reflect.Pointer && !old.IsNil() && !new.IsNil() {
+		diffValue(path, old.Elem(), new.Elem(), changes)
+		return
+	}
+
+	if old.Kind() != reflect.Struct || !exportedOnly(old.Type()) {
+		if !reflect.DeepEqual(old.Interface(), new.Interface()) {
+			*changes = append(*changes, FieldChange{Path: path, Old: old.Interface(), New: new.Interface()})
+		}
+		return
+	}
+
+	for i := range old.NumField() {
+		name, squash, ok := optionKey(old.Type().Field(i))
+		if !ok {
+			continue
+		}
+
+		sub := path
+		if !squash {
+			sub = strings.TrimPrefix(path+"."+name, ".")
+		}
+		diffValue(sub, old.Field(i), new.Field(i), changes)
+	}
+}
+
+// exportedOnly reports whether every field of the struct type t is exported.
+func exportedOnly(t reflect.Type) bool {
+	for i := range t.NumField() {
+		if !t.Field(i).IsExported() {
+			return false
+		}
+	}
+
+	return true
+}
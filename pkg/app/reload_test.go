@@ -0,0 +1,146 @@
+package app
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"chunyu/pkg/log"
+)
+
+type poolOptions struct {
+	MaxOpen int    `mapstructure:"max-open-connections"`
+	Addr    string `mapstructure:"addr"`
+}
+
+type reloadOptions struct {
+	Name  string       `mapstructure:"name"`
+	MySQL *poolOptions `mapstructure:"mysql"`
+}
+
+func (o *reloadOptions) Complete() error { return nil }
+
+func (o *reloadOptions) Validate() error {
+	if o.MySQL.MaxOpen <= 0 {
+		return errors.New("mysql.max-open-connections must be positive")
+	}
+	return nil
+}
+
+func TestReloader_Reload(t *testing.T) {
+	t.Cleanup(viper.Reset)
+
+	opts := &reloadOptions{Name: "a", MySQL: &poolOptions{MaxOpen: 10, Addr: "db:3306"}}
+	mysql := opts.MySQL
+
+	viper.Set("name", "a")
+	viper.Set("mysql.max-open-connections", 10)
+	viper.Set("mysql.addr", "db:3306")
+
+	r := NewReloader()
+	r.bind(opts)
+
+	var got []ConfigChange
+	OnReload(r, func(old, new *reloadOptions, change ConfigChange) {
+		assert.Equal(t, 10, old.MySQL.MaxOpen)
+		got = append(got, change)
+	})
+
+	// Nothing changed.
+	require.NoError(t, r.Reload())
+	assert.Empty(t, got)
+
+	// Invalid updates are rejected.
+	viper.Set("mysql.max-open-connections", 0)
+	require.Error(t, r.Reload())
+	assert.Equal(t, 10, opts.MySQL.MaxOpen)
+	assert.Empty(t, got)
+
+	viper.Set("mysql.max-open-connections", 20)
+	require.NoError(t, r.Reload())
+	require.Len(t, got, 1)
+	assert.Equal(t, []FieldChange{{Path: "mysql.max-open-connections", Old: 10, New: 20}}, got[0].Changes)
+	assert.True(t, got[0].Changed("mysql"))
+	assert.False(t, got[0].Changed("name"))
+
+	// The new options are published as a snapshot, the options given to the
+	// application are not modified.
+	assert.Same(t, mysql, opts.MySQL)
+	assert.Equal(t, 10, mysql.MaxOpen)
+	assert.Equal(t, 20, Current[*reloadOptions](r).MySQL.MaxOpen)
+	assert.Equal(t, "a", Current[*reloadOptions](r).Name)
+}
+
+func TestReloader_SubscriberReentrancy(t *testing.T) {
+	t.Cleanup(viper.Reset)
+
+	viper.Set("mysql.max-open-connections", 10)
+	r := NewReloader()
+	r.bind(&reloadOptions{MySQL: &poolOptions{MaxOpen: 10}})
+
+	// Subscribers run without the lock of the reloader held.
+	var reloaded, subscribed bool
+	r.Subscribe(func(ConfigChange) {
+		reloaded = r.Reload() == nil
+		r.Subscribe(func(ConfigChange) { subscribed = true })
+	})
+
+	viper.Set("mysql.max-open-connections", 20)
+	require.NoError(t, r.Reload())
+	assert.True(t, reloaded)
+
+	viper.Set("mysql.max-open-connections", 30)
+	require.NoError(t, r.Reload())
+	assert.True(t, subscribed)
+}
+
+func TestReloader_LogLevel(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	level := log.Level.Level()
+	t.Cleanup(func() { log.Level.SetLevel(level) })
+
+	opts := &reloadOptions{MySQL: &poolOptions{MaxOpen: 10}}
+	viper.Set("mysql.max-open-connections", 10)
+	viper.Set("log.level", "info")
+	log.SetLevel("info")
+
+	r := NewReloader()
+	r.bind(opts)
+
+	// The log level of a rejected update is not applied either.
+	viper.Set("log.level", "debug")
+	viper.Set("mysql.max-open-connections", 0)
+	require.Error(t, r.Reload())
+	assert.Equal(t, zap.InfoLevel, log.Level.Level())
+
+	// It is applied with the next valid update, even without other changes.
+	viper.Set("mysql.max-open-connections", 10)
+	require.NoError(t, r.Reload())
+	assert.Equal(t, zap.DebugLevel, log.Level.Level())
+}
+
+func TestReloader_ConcurrentReads(t *testing.T) {
+	t.Cleanup(viper.Reset)
+
+	viper.Set("mysql.max-open-connections", 10)
+	r := NewReloader()
+	r.bind(&reloadOptions{MySQL: &poolOptions{MaxOpen: 10}})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range 100 {
+			assert.Positive(t, Current[*reloadOptions](r).MySQL.MaxOpen)
+		}
+	}()
+
+	for i := range 100 {
+		viper.Set("mysql.max-open-connections", i+1)
+		require.NoError(t, r.Reload())
+	}
+	<-done
+}
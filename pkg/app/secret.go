@@ -0,0 +1,204 @@
+package app
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// redacted replaces the value of resolved secrets in printed configuration.
+const redacted = "******"
+
+// SecretProvider resolves a secret reference such as file:///run/secrets/db
+// to the secret value. ref is the part after "<scheme>://".
+type SecretProvider interface {
+	Resolve(ref string) (string, error)
+}
+
+// SecretProviderFunc adapts a function to a SecretProvider.
+type SecretProviderFunc func(ref string) (string, error)
+
+// Resolve calls f(ref).
+func (f SecretProviderFunc) Resolve(ref string) (string, error) {
+	return f(ref)
+}
+
+var (
+	secretMu        sync.RWMutex
+	secretProviders = map[string]SecretProvider{
+		"file": SecretProviderFunc(resolveFileSecret),
+		"env":  SecretProviderFunc(resolveEnvSecret),
+	}
+	// secretKeys holds the configuration keys whose value is a resolved secret.
+	secretKeys = map[string]bool{}
+)
+
+// RegisterSecretProvider makes a secret provider available for references of
+// the form <scheme>://<ref>, e.g. vault://secret/data/db#password. The file and
+// env schemes are registered by default; registering a scheme again replaces
+// its provider.
+func RegisterSecretProvider(scheme string, p SecretProvider) {
+	secretMu.Lock()
+	defer secretMu.Unlock()
+
+	secretProviders[scheme] = p
+}
+
+// resolveFileSecret reads the secret from a file, e.g. file:///run/secrets/db.
+// A single trailing newline is removed.
+func resolveFileSecret(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	value := strings.TrimSuffix(string(data), "\n")
+	return strings.TrimSuffix(value, "\r"), nil
+}
+
+// resolveEnvSecret reads the secret from an environment variable, e.g.
+// env://DB_PASSWORD.
+func resolveEnvSecret(name string) (string, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	}
+
+	return value, nil
+}
+
+// resolveSecrets returns a viper instance holding the settings of the global
+// viper with every secret reference replaced by its value. The keys of the
+// resolved values are remembered so that they can be redacted when printed.
+func resolveSecrets() (*viper.Viper, error) {
+	secretMu.Lock()
+	defer secretMu.Unlock()
+
+	keys := map[string]bool{}
+	settings := viper.AllSettings()
+	if err := resolveSettings("", settings, keys); err != nil {
+		return nil, err
+	}
+	secretKeys = keys
+
+	v := viper.New()
+	if err := v.MergeConfigMap(settings); err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+func resolveSettings(prefix string, settings map[string]any, keys map[string]bool) error {
+	for name, value := range settings {
+		key := strings.TrimPrefix(prefix+"."+name, ".")
+
+		switch typed := value.(type) {
+		case map[string]any:
+			if err := resolveSettings(key, typed, keys); err != nil {
+				return err
+			}
+		case string:
+			secret, ok, err := resolveSecret(typed)
+			if err != nil {
+				return fmt.Errorf("failed to resolve secret for %s: %w", key, err)
+			}
+			if ok {
+				settings[name] = secret
+				keys[key] = true
+			}
+		case []string:
+			values := make([]any, len(typed))
+			for i, v := range typed {
+				values[i] = v
+			}
+			if err := resolveList(key, settings, name, values, keys); err != nil {
+				return err
+			}
+		case []any:
+			if err := resolveList(key, settings, name, typed, keys); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// resolveList resolves the secret references among the string elements of
+// values, the list settings[name]. The list is replaced by a resolved copy,
+// since viper shares its lists with the settings.
+func resolveList(key string, settings map[string]any, name string, values []any, keys map[string]bool) error {
+	var resolved []any
+	for i, value := range values {
+		s, ok := value.(string)
+		if !ok {
+			continue
+		}
+
+		secret, ok, err := resolveSecret(s)
+		if err != nil {
+			return fmt.Errorf("failed to resolve secret for %s[%d]: %w", key, i, err)
+		}
+		if !ok {
+			continue
+		}
+		if resolved == nil {
+			resolved = slices.Clone(values)
+		}
+		resolved[i] = secret
+	}
+
+	if resolved != nil {
+		settings[name] = resolved
+		keys[key] = true
+	}
+
+	return nil
+}
+
+// resolveSecret resolves value if it is a reference to a registered provider.
+func resolveSecret(value string) (string, bool, error) {
+	scheme, ref, ok := strings.Cut(value, "://")
+	if !ok {
+		return "", false, nil
+	}
+
+	provider, ok := secretProviders[scheme]
+	if !ok {
+		return "", false, nil
+	}
+
+	secret, err := provider.Resolve(ref)
+	if err != nil {
+		return "", false, err
+	}
+
+	return secret, true, nil
+}
+
+// isSecret reports whether the value of the configuration key was resolved
+// from a secret reference.
+func isSecret(key string) bool {
+	secretMu.RLock()
+	defer secretMu.RUnlock()
+
+	return secretKeys[strings.ToLower(key)]
+}
+
+// PrintFlags logs the flags in the flag set, redacting resolved secrets.
+func PrintFlags(fs *pflag.FlagSet) {
+	fs.VisitAll(func(flag *pflag.Flag) {
+		value := flag.Value.String()
+		if isSecret(flag.Name) {
+			value = redacted
+		}
+		slog.Debug(fmt.Sprintf("FLAG: --%s=%q", flag.Name, value))
+	})
+}
@@ -0,0 +1,64 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type secretOptions struct {
+	MySQL struct {
+		Addr     string `mapstructure:"addr"`
+		Password string `mapstructure:"password"`
+	} `mapstructure:"mysql"`
+	Redis struct {
+		Password string `mapstructure:"password"`
+	} `mapstructure:"redis"`
+	Token    string   `mapstructure:"token"`
+	Replicas []string `mapstructure:"replicas"`
+}
+
+func TestLoadInto_Secrets(t *testing.T) {
+	t.Cleanup(viper.Reset)
+
+	file := filepath.Join(t.TempDir(), "db")
+	require.NoError(t, os.WriteFile(file, []byte("s3cret\n"), 0o600))
+	t.Setenv("CHUNYU_TEST_REDIS_PASSWORD", "r3dis")
+	RegisterSecretProvider("upper", SecretProviderFunc(func(ref string) (string, error) {
+		return strings.ToUpper(ref), nil
+	}))
+
+	viper.Set("mysql.addr", "tcp://127.0.0.1:3306")
+	viper.Set("mysql.password", "file://"+file)
+	viper.Set("redis.password", "env://CHUNYU_TEST_REDIS_PASSWORD")
+	viper.Set("token", "upper://abc")
+	viper.Set("replicas", []string{"tcp://127.0.0.1:3307", "file://" + file})
+
+	var opts secretOptions
+	require.NoError(t, loadInto(&opts))
+	assert.Equal(t, "s3cret", opts.MySQL.Password)
+	assert.Equal(t, "r3dis", opts.Redis.Password)
+	assert.Equal(t, "ABC", opts.Token)
+	// Unknown schemes are not secret references.
+	assert.Equal(t, "tcp://127.0.0.1:3306", opts.MySQL.Addr)
+	assert.Equal(t, []string{"tcp://127.0.0.1:3307", "s3cret"}, opts.Replicas)
+	// The resolved list does not leak back into viper.
+	assert.Equal(t, []string{"tcp://127.0.0.1:3307", "file://" + file}, viper.GetStringSlice("replicas"))
+
+	assert.True(t, isSecret("mysql.password"))
+	assert.True(t, isSecret("token"))
+	assert.True(t, isSecret("replicas"))
+	assert.False(t, isSecret("mysql.addr"))
+
+	viper.Set("replicas", []any{"env://CHUNYU_TEST_UNSET"})
+	assert.ErrorContains(t, loadInto(&opts), "replicas[0]")
+	viper.Set("replicas", nil)
+
+	viper.Set("redis.password", "env://CHUNYU_TEST_UNSET")
+	assert.ErrorContains(t, loadInto(&opts), "redis.password")
+}
@@ -0,0 +1,376 @@
+// Package config loads the application configuration from several layers and
+// merges them into viper.
+//
+// Layers are merged in the following order, later layers overriding earlier
+// ones key by key:
+//
+//  1. defaults registered with viper, including flag defaults
+//  2. the base file, <name>.<ext>, or the file given with WithConfigFile
+//  3. the environment overlay, <name>.<environment>.<ext>, next to the base file
+//  4. every file of the conf.d directory next to the base file, in lexical order
+//  5. the remote sources, in the order they were given
+//  6. environment variables, when viper.AutomaticEnv is enabled
+//  7. flags which were set on the command line
+//
+// Layers 1, 6 and 7 are resolved by viper itself, the loader replaces the
+// configuration of viper with the merge of layers 2 to 5.
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+)
+
+// confDir is the directory of drop-in files next to the base file.
+const confDir = "conf.d"
+
+// retryInterval is the delay before a failed remote watch is restarted.
+var retryInterval = 5 * time.Second
+
+// Loader loads layered configuration into viper.
+type Loader struct {
+	name        string
+	file        string
+	paths       []string
+	environment string
+	sources     []Source
+	v           *viper.Viper
+
+	mu    sync.Mutex
+	files []string
+	// targets are the real paths of files, with symbolic links resolved.
+	targets []string
+
+	// reloadMu serializes the reloads triggered by the watches.
+	reloadMu sync.Mutex
+}
+
+// Option configures a Loader.
+type Option func(*Loader)
+
+// WithConfigFile sets the base file explicitly instead of searching for it.
+func WithConfigFile(file string) Option {
+	return func(l *Loader) {
+		l.file = file
+	}
+}
+
+// WithSearchPaths sets the directories searched for the base file, in order.
+// The first directory holding a <name>.<ext> file wins.
+func WithSearchPaths(paths ...string) Option {
+	return func(l *Loader) {
+		l.paths = append(l.paths, paths...)
+	}
+}
+
+// WithEnvironment sets the environment, e.g. production, whose overlay file
+// <name>.<environment>.<ext> is merged over the base file.
+func WithEnvironment(environment string) Option {
+	return func(l *Loader) {
+		l.environment = environment
+	}
+}
+
+// WithSources adds remote sources merged over the files.
+func WithSources(sources ...Source) Option {
+	return func(l *Loader) {
+		l.sources = append(l.sources, sources...)
+	}
+}
+
+// WithViper sets the viper instance the configuration is loaded into. The
+// global instance is used by default.
+func WithViper(v *viper.Viper) Option {
+	return func(l *Loader) {
+		l.v = v
+	}
+}
+
+// NewLoader creates a loader for the configuration files named name.
+func NewLoader(name string, opts ...Option) *Loader {
+	l := &Loader{name: name, v: viper.GetViper()}
+	for _, o := range opts {
+		o(l)
+	}
+
+	return l
+}
+
+// Files returns the configuration files merged by the last Load, in merge
+// order.
+func (l *Loader) Files() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return slices.Clone(l.files)
+}
+
+// Load reads every layer and replaces the configuration of viper with their
+// merge. A missing base file is not an error. If a file or a source cannot be
+// read, the configuration of viper is left untouched.
+func (l *Loader) Load(ctx context.Context) error {
+	files, err := l.discover()
+	if err != nil {
+		return err
+	}
+
+	settings := map[string]any{}
+	for _, file := range files {
+		v := viper.New()
+		v.SetConfigFile(file)
+		if err := v.ReadInConfig(); err != nil {
+			return fmt.Errorf("failed to read config file %s: %w", file, err)
+		}
+		merge(settings, v.AllSettings())
+	}
+
+	for _, src := range l.sources {
+		remote, err := src.Load(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to load config from %s: %w", src, err)
+		}
+		merge(settings, lowerKeys(remote))
+	}
+
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return err
+	}
+
+	l.v.SetConfigType("json")
+	if err := l.v.ReadConfig(bytes.NewReader(data)); err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	l.files = files
+	l.targets = realPaths(files)
+	l.mu.Unlock()
+
+	return nil
+}
+
+// Watch reloads the configuration when a file of a watched directory or a
+// remote source changes, then calls onChange with the result of the reload.
+// Like viper, files are also reloaded when a symbolic link they resolve through
+// is replaced, as happens when Kubernetes updates a mounted ConfigMap or Secret
+// by swapping its ..data link.
+// Reloads and calls to onChange are serialized. Watch returns once the
+// watches are set up; they stop when ctx is done.
+func (l *Loader) Watch(ctx context.Context, onChange func(error)) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	for _, dir := range l.watchDirs() {
+		if err := watcher.Add(dir); err != nil && !os.IsNotExist(err) {
+			_ = watcher.Close()
+			return fmt.Errorf("failed to watch %s: %w", dir, err)
+		}
+	}
+
+	reload := func() {
+		l.reloadMu.Lock()
+		defer l.reloadMu.Unlock()
+
+		onChange(l.Load(ctx))
+	}
+
+	go func() {
+		defer watcher.Close()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				// A conf.d directory created after the watch started.
+				if event.Has(fsnotify.Create) && filepath.Base(event.Name) == confDir {
+					if err := watcher.Add(event.Name); err == nil {
+						reload()
+					}
+					continue
+				}
+				if event.Has(fsnotify.Write|fsnotify.Create|fsnotify.Remove|fsnotify.Rename) &&
+					(l.relevant(event.Name) || l.retargeted()) {
+					reload()
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				slog.Warn("Config watcher error", "err", err)
+			}
+		}
+	}()
+
+	for _, src := range l.sources {
+		go func() {
+			for {
+				err := src.Watch(ctx, reload)
+				if ctx.Err() != nil {
+					return
+				}
+				slog.Warn("Config source watch stopped, retrying", "source", src.String(), "err", err)
+
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(retryInterval):
+				}
+			}
+		}()
+	}
+
+	return nil
+}
+
+// discover returns the files to merge: the base file, its environment overlay
+// and the conf.d files.
+func (l *Loader) discover() ([]string, error) {
+	base := l.file
+	if base == "" {
+		base = l.search()
+	}
+	if base == "" {
+		return nil, nil
+	}
+	if _, err := os.Stat(base); err != nil {
+		return nil, err
+	}
+
+	files := []string{base}
+	if l.environment != "" {
+		ext := filepath.Ext(base)
+		overlay := strings.TrimSuffix(base, ext) + "." + l.environment + ext
+		if _, err := os.Stat(overlay); err == nil {
+			files = append(files, overlay)
+		}
+	}
+
+	entries, err := os.ReadDir(filepath.Join(filepath.Dir(base), confDir))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() && supported(entry.Name()) {
+			files = append(files, filepath.Join(filepath.Dir(base), confDir, entry.Name()))
+		}
+	}
+
+	return files, nil
+}
+
+// search returns the first <name>.<ext> file found in the search paths.
+func (l *Loader) search() string {
+	for _, dir := range l.paths {
+		for _, ext := range viper.SupportedExts {
+			file := filepath.Join(dir, l.name+"."+ext)
+			if info, err := os.Stat(file); err == nil && !info.IsDir() {
+				return file
+			}
+		}
+	}
+
+	return ""
+}
+
+// watchDirs returns the directories whose changes may affect the files.
+func (l *Loader) watchDirs() []string {
+	var dirs []string
+	if l.file != "" {
+		dirs = append(dirs, filepath.Dir(l.file))
+	} else {
+		dirs = append(dirs, l.paths...)
+	}
+
+	for _, dir := range slices.Clone(dirs) {
+		dirs = append(dirs, filepath.Join(dir, confDir))
+	}
+
+	return dirs
+}
+
+// relevant reports whether a change of file may change the configuration.
+func (l *Loader) relevant(file string) bool {
+	if !supported(file) {
+		return false
+	}
+	if filepath.Base(filepath.Dir(file)) == confDir {
+		return true
+	}
+
+	name := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	if l.file != "" {
+		return filepath.Clean(file) == filepath.Clean(l.file) ||
+			name == strings.TrimSuffix(filepath.Base(l.file), filepath.Ext(l.file))+"."+l.environment
+	}
+
+	return name == l.name || name == l.name+"."+l.environment
+}
+
+// retargeted reports whether a file merged by the last Load now resolves to
+// another real path, e.g. because a symbolic link was swapped.
+func (l *Loader) retargeted() bool {
+	l.mu.Lock()
+	files, targets := l.files, l.targets
+	l.mu.Unlock()
+
+	return !slices.Equal(realPaths(files), targets)
+}
+
+// realPaths returns the paths of files with symbolic links resolved. Files
+// which cannot be resolved are returned empty.
+func realPaths(files []string) []string {
+	targets := make([]string, len(files))
+	for i, file := range files {
+		targets[i], _ = filepath.EvalSymlinks(file)
+	}
+
+	return targets
+}
+
+func supported(file string) bool {
+	return slices.Contains(viper.SupportedExts, strings.TrimPrefix(filepath.Ext(file), "."))
+}
+
+// merge merges src into dst recursively, values of src winning.
+func merge(dst, src map[string]any) {
+	for key, value := range src {
+		if srcMap, ok := value.(map[string]any); ok {
+			if dstMap, ok := dst[key].(map[string]any); ok {
+				merge(dstMap, srcMap)
+				continue
+			}
+		}
+		dst[key] = value
+	}
+}
+
+// lowerKeys returns a copy of settings with lower case keys, like viper.
+func lowerKeys(settings map[string]any) map[string]any {
+	out := make(map[string]any, len(settings))
+	for key, value := range settings {
+		if m, ok := value.(map[string]any); ok {
+			value = lowerKeys(m)
+		}
+		out[strings.ToLower(key)] = value
+	}
+
+	return out
+}
@@ -0,0 +1,214 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryKV is an in-memory KV with etcd-like revisions.
+type memoryKV struct {
+	mu       sync.Mutex
+	data     map[string][]byte
+	revision uint64
+	changed  chan struct{}
+}
+
+func newMemoryKV() *memoryKV {
+	return &memoryKV{data: map[string][]byte{}, changed: make(chan struct{})}
+}
+
+func (m *memoryKV) Put(key, value string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.data[key] = []byte(value)
+	m.revision++
+	close(m.changed)
+	m.changed = make(chan struct{})
+}
+
+func (m *memoryKV) List(_ context.Context, prefix string) (map[string][]byte, uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	kvs := map[string][]byte{}
+	for key, value := range m.data {
+		if strings.HasPrefix(key, prefix) {
+			kvs[key] = value
+		}
+	}
+	return kvs, m.revision, nil
+}
+
+func (m *memoryKV) Watch(ctx context.Context, _ string, revision uint64) (uint64, error) {
+	for {
+		m.mu.Lock()
+		current, changed := m.revision, m.changed
+		m.mu.Unlock()
+
+		if current > revision {
+			return current, nil
+		}
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+func writeFile(t *testing.T, path, data string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(t, os.WriteFile(path, []byte(data), 0o600))
+}
+
+func TestLoader_Layers(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "app.yaml"), "mysql:\n  addr: base:3306\n  database: base\nname: base\nport: 1\n")
+	writeFile(t, filepath.Join(dir, "app.production.yaml"), "mysql:\n  addr: prod:3306\nport: 2\n")
+	writeFile(t, filepath.Join(dir, "conf.d", "10-db.json"), `{"mysql": {"database": "dropin"}, "port": 3}`)
+	writeFile(t, filepath.Join(dir, "conf.d", "20-port.toml"), "port = 4\n")
+	writeFile(t, filepath.Join(dir, "conf.d", "README"), "ignored")
+
+	kv := newMemoryKV()
+	kv.Put("/app/port", "5")
+	kv.Put("/app/mysql/pool/max-open", "20")
+	kv.Put("/other/name", "ignored")
+
+	v := viper.New()
+	v.SetDefault("name", "default")
+	v.SetDefault("timeout", "1s")
+	l := NewLoader("app",
+		WithViper(v),
+		WithSearchPaths(filepath.Join(dir, "missing"), dir),
+		WithEnvironment("production"),
+		WithSources(NewKVSource(kv, "/app")),
+	)
+	require.NoError(t, l.Load(context.Background()))
+
+	assert.Equal(t, []string{
+		filepath.Join(dir, "app.yaml"),
+		filepath.Join(dir, "app.production.yaml"),
+		filepath.Join(dir, "conf.d", "10-db.json"),
+		filepath.Join(dir, "conf.d", "20-port.toml"),
+	}, l.Files())
+	assert.Equal(t, "prod:3306", v.GetString("mysql.addr"))
+	assert.Equal(t, "dropin", v.GetString("mysql.database"))
+	assert.Equal(t, "base", v.GetString("name"))
+	assert.Equal(t, "1s", v.GetString("timeout"))
+	assert.Equal(t, 5, v.GetInt("port"))
+	assert.Equal(t, 20, v.GetInt("mysql.pool.max-open"))
+
+	// Flags and environment variables still take precedence.
+	t.Setenv("APP_PORT", "6")
+	v.SetEnvPrefix("app")
+	v.AutomaticEnv()
+	assert.Equal(t, 6, v.GetInt("port"))
+}
+
+func TestLoader_NoFile(t *testing.T) {
+	v := viper.New()
+	l := NewLoader("app", WithViper(v), WithSearchPaths(t.TempDir()))
+	require.NoError(t, l.Load(context.Background()))
+	assert.Empty(t, l.Files())
+
+	l = NewLoader("app", WithViper(v), WithConfigFile(filepath.Join(t.TempDir(), "missing.yaml")))
+	assert.Error(t, l.Load(context.Background()))
+}
+
+func TestLoader_Watch(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "app.yaml")
+	writeFile(t, file, "port: 1\n")
+
+	kv := newMemoryKV()
+	v := viper.New()
+	l := NewLoader("app", WithViper(v), WithConfigFile(file), WithSources(NewKVSource(kv, "/app")))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, l.Load(ctx))
+
+	// viper is not safe for concurrent use, read it from the reload goroutine.
+	ports := make(chan int, 16)
+	require.NoError(t, l.Watch(ctx, func(err error) {
+		assert.NoError(t, err)
+		ports <- v.GetInt("port")
+	}))
+
+	waitFor := func(want int) {
+		t.Helper()
+		deadline := time.After(5 * time.Second)
+		for {
+			select {
+			case port := <-ports:
+				if port == want {
+					return
+				}
+			case <-deadline:
+				t.Fatalf("port did not change to %d", want)
+			}
+		}
+	}
+
+	writeFile(t, file, "port: 2\n")
+	waitFor(2)
+
+	writeFile(t, filepath.Join(dir, "conf.d", "port.yaml"), "port: 3\n")
+	waitFor(3)
+
+	kv.Put("/app/port", "4")
+	waitFor(4)
+}
+
+func TestLoader_WatchSymlinkSwap(t *testing.T) {
+	// The layout of a Kubernetes ConfigMap volume: app.yaml links to
+	// ..data/app.yaml and ..data links to a timestamped directory.
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "..2024_01", "app.yaml"), "port: 1\n")
+	require.NoError(t, os.Symlink("..2024_01", filepath.Join(dir, "..data")))
+	require.NoError(t, os.Symlink(filepath.Join("..data", "app.yaml"), filepath.Join(dir, "app.yaml")))
+
+	v := viper.New()
+	l := NewLoader("app", WithViper(v), WithConfigFile(filepath.Join(dir, "app.yaml")))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, l.Load(ctx))
+	assert.Equal(t, 1, v.GetInt("port"))
+
+	ports := make(chan int, 16)
+	require.NoError(t, l.Watch(ctx, func(err error) {
+		assert.NoError(t, err)
+		ports <- v.GetInt("port")
+	}))
+
+	// Kubernetes writes the new version, then atomically renames a new link
+	// over ..data. No event names app.yaml.
+	writeFile(t, filepath.Join(dir, "..2024_02", "app.yaml"), "port: 2\n")
+	require.NoError(t, os.Symlink("..2024_02", filepath.Join(dir, "..data_tmp")))
+	require.NoError(t, os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")))
+	require.NoError(t, os.RemoveAll(filepath.Join(dir, "..2024_01")))
+
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case port := <-ports:
+			if port == 2 {
+				return
+			}
+		case <-deadline:
+			t.Fatal("port did not change to 2")
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Source is a remote configuration source.
+type Source interface {
+	// Load returns the configuration held by the source as nested maps.
+	Load(ctx context.Context) (map[string]any, error)
+	// Watch blocks until ctx is done, calling onChange every time the
+	// configuration held by the source changes. It returns a non-nil error
+	// if watching failed.
+	Watch(ctx context.Context, onChange func()) error
+	// String identifies the source in logs.
+	String() string
+}
+
+// KV is the subset of a key/value store such as etcd or Consul needed by
+// KVSource.
+type KV interface {
+	// List returns the values of every key under prefix and the revision of
+	// the store they were read at.
+	List(ctx context.Context, prefix string) (map[string][]byte, uint64, error)
+	// Watch blocks until a key under prefix changes after revision, and
+	// returns the new revision.
+	Watch(ctx context.Context, prefix string, revision uint64) (uint64, error)
+}
+
+// KVSource reads the configuration from the keys under a prefix of a key/value
+// store. The key <prefix>/mysql/max-open-connections holds the value of the
+// configuration key mysql.max-open-connections. Values are parsed as yaml, so
+// numbers, booleans, lists and maps keep their type.
+type KVSource struct {
+	kv     KV
+	prefix string
+
+	mu       sync.Mutex
+	revision uint64
+}
+
+var _ Source = (*KVSource)(nil)
+
+// NewKVSource creates a source reading the keys under prefix from kv.
+func NewKVSource(kv KV, prefix string) *KVSource {
+	return &KVSource{kv: kv, prefix: strings.TrimSuffix(prefix, "/") + "/"}
+}
+
+// Load implements Source.
+func (s *KVSource) Load(ctx context.Context) (map[string]any, error) {
+	kvs, revision, err := s.kv.List(ctx, s.prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	settings := map[string]any{}
+	for key, raw := range kvs {
+		path := strings.Split(strings.Trim(strings.TrimPrefix(key, s.prefix), "/"), "/")
+		if len(path) == 0 || path[0] == "" {
+			continue
+		}
+
+		var value any
+		if err := yaml.Unmarshal(raw, &value); err != nil || value == nil {
+			value = string(raw)
+		}
+
+		parent := settings
+		for _, segment := range path[:len(path)-1] {
+			child, ok := parent[segment].(map[string]any)
+			if !ok {
+				child = map[string]any{}
+				parent[segment] = child
+			}
+			parent = child
+		}
+		parent[path[len(path)-1]] = value
+	}
+
+	s.mu.Lock()
+	s.revision = revision
+	s.mu.Unlock()
+
+	return settings, nil
+}
+
+// Watch implements Source.
+func (s *KVSource) Watch(ctx context.Context, onChange func()) error {
+	for {
+		s.mu.Lock()
+		revision := s.revision
+		s.mu.Unlock()
+
+		next, err := s.kv.Watch(ctx, s.prefix, revision)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		s.mu.Lock()
+		s.revision = next
+		s.mu.Unlock()
+
+		onChange()
+	}
+}
+
+// String implements Source.
+func (s *KVSource) String() string {
+	return fmt.Sprintf("kv:%s", s.prefix)
+}
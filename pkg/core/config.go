@@ -1,28 +1,23 @@
 package core
 
 import (
+	"context"
+	"log/slog"
 	"strings"
 
 	"github.com/spf13/viper"
+
+	"chunyu/pkg/config"
 )
 
 // OnInitialize 设置需要读取的配置文件名、环境变量，并将其内容读取到 viper 中.
-func OnInitialize(configFile *string, envPrefix string, loadDirs []string, defaultConfigName string) func() {
+// 配置按 config 包定义的分层规则加载：基础文件、环境覆盖文件、conf.d 目录、远程配置源、环境变量和命令行选项.
+func OnInitialize(configFile *string, envPrefix string, loadDirs []string, defaultConfigName string, opts ...config.Option) func() {
 	return func() {
+		loaderOpts := []config.Option{config.WithSearchPaths(loadDirs...)}
 		if configFile != nil {
 			// 从命令行选项指定的配置文件中读取
-			viper.SetConfigFile(*configFile)
-		} else {
-			for _, dir := range loadDirs {
-				// 将 dir 目录加入到配置文件的搜索路径
-				viper.AddConfigPath(dir)
-			}
-
-			// 设置配置文件格式为 YAML
-			viper.SetConfigType("yaml")
-
-			// 配置文件名称（没有文件扩展名）
-			viper.SetConfigName(defaultConfigName)
+			loaderOpts = append(loaderOpts, config.WithConfigFile(*configFile))
 		}
 
 		// 读取匹配的环境变量
@@ -35,7 +30,13 @@ func OnInitialize(configFile *string, envPrefix string, loadDirs []string, defau
 		replacer := strings.NewReplacer(".", "_", "-", "_")
 		viper.SetEnvKeyReplacer(replacer)
 
+		// 环境覆盖文件由 <envPrefix>_ENV 环境变量选择，例如 production
+		loaderOpts = append(loaderOpts, config.WithEnvironment(viper.GetString("env")))
+
 		// 读取配置文件。如果指定了配置文件名，则使用指定的配置文件，否则在注册的搜索路径中搜索
-		_ = viper.ReadInConfig()
+		loader := config.NewLoader(defaultConfigName, append(loaderOpts, opts...)...)
+		if err := loader.Load(context.Background()); err != nil {
+			slog.Debug("Failed to read configuration", "err", err)
+		}
 	}
 }
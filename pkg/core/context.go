@@ -0,0 +1,88 @@
+package core
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+
+	"chunyu/pkg/clog"
+	"chunyu/pkg/log"
+)
+
+const (
+	// HeaderRequestID 是携带请求 ID 的 HTTP 头，gRPC 中使用小写形式的同名元数据.
+	HeaderRequestID = "X-Request-ID"
+
+	// HeaderAcceptLanguage 是携带客户端语言偏好的 HTTP 头，gRPC 中使用小写形式的同名元数据.
+	HeaderAcceptLanguage = "Accept-Language"
+
+	// HeaderContentLanguage 是错误响应中标识错误信息语言的 HTTP 头.
+	HeaderContentLanguage = "Content-Language"
+)
+
+// WithRequestID 将请求 ID 存入上下文，clog.L 和 slog 记录日志时会自动带上该字段.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	//nolint:staticcheck // clog.L 使用字符串类型的 key 读取
+	ctx = context.WithValue(ctx, clog.KeyRequestID, requestID)
+	return log.WithAttr(ctx, slog.String(clog.KeyRequestID, requestID))
+}
+
+// RequestID 返回上下文中的请求 ID，不存在时返回空字符串.
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(clog.KeyRequestID).(string)
+	return requestID
+}
+
+// WithUsername 将当前用户名存入上下文，通常由认证中间件调用.
+// clog.L 和 slog 记录日志时会自动带上该字段.
+// 上下文中存在 TrackUsername 放入的记录时，用户名同时写入该记录.
+func WithUsername(ctx context.Context, username string) context.Context {
+	if tracker, ok := ctx.Value(usernameTrackerKey{}).(*usernameTracker); ok {
+		tracker.set(username)
+	}
+	//nolint:staticcheck // clog.L 使用字符串类型的 key 读取
+	ctx = context.WithValue(ctx, clog.KeyUsername, username)
+	return log.WithAttr(ctx, slog.String(clog.KeyUsername, username))
+}
+
+// Username 返回上下文中的用户名，不存在时返回空字符串.
+func Username(ctx context.Context) string {
+	username, _ := ctx.Value(clog.KeyUsername).(string)
+	return username
+}
+
+// usernameTrackerKey 是上下文中保存 usernameTracker 的 key.
+type usernameTrackerKey struct{}
+
+// usernameTracker 记录在派生的上下文中设置的用户名.
+type usernameTracker struct {
+	mu       sync.Mutex
+	username string
+}
+
+func (t *usernameTracker) set(username string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.username = username
+}
+
+func (t *usernameTracker) get() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.username
+}
+
+// TrackUsername 在上下文中放入一个用户名记录，之后在其派生的上下文中调用 WithUsername 设置的用户名
+// 可以通过 TrackedUsername 从返回的上下文中读取. 用于请求处理完成后记录访问日志的中间件，
+// 因为处理函数设置的用户名只存在于派生的上下文中.
+func TrackUsername(ctx context.Context) context.Context {
+	return context.WithValue(ctx, usernameTrackerKey{}, &usernameTracker{})
+}
+
+// TrackedUsername 返回 TrackUsername 记录的用户名，不存在时返回空字符串.
+func TrackedUsername(ctx context.Context) string {
+	if tracker, ok := ctx.Value(usernameTrackerKey{}).(*usernameTracker); ok {
+		return tracker.get()
+	}
+	return ""
+}
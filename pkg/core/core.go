@@ -2,11 +2,23 @@ package core
 
 import (
 	"context"
+	"encoding/json"
+	"log/slog"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
 
 	"chunyu/pkg/errorsx"
+	"chunyu/pkg/tracing"
 )
 
 // Validator 是验证函数的类型，用于对绑定的数据结构进行验证.
@@ -27,6 +39,9 @@ type ErrorResponse struct {
 	Message string `json:"message,omitempty"`
 	// 附带的元数据信息
 	Metadata map[string]string `json:"metadata,omitempty"`
+	// 错误详情，例如字段错误和重试信息.
+	// 每一项都是 google.protobuf.Any 的 JSON 格式，使用 @type 字段标识详情的类型.
+	Details []json.RawMessage `json:"details,omitempty"`
 }
 
 // HandleJSONRequest 是处理 JSON 请求的快捷函数.
@@ -49,17 +64,55 @@ func HandleUriRequest[T any, R any](c *gin.Context, handler Handler[T, R], valid
 func HandleRequest[T any, R any](c *gin.Context, binder Binder, handler Handler[T, R], validators ...Validator[T]) {
 	var request T
 
+	// 为请求创建 span，并放入请求的上下文中
+	span := startSpan(c)
+	defer span.End()
+
 	// 绑定和验证请求数据
 	if err := ReadRequest(c, &request, binder, validators...); err != nil {
+		endSpan(span, err)
 		WriteResponse(c, nil, err)
 		return
 	}
 
 	// 调用实际的业务逻辑处理函数
 	response, err := handler(c.Request.Context(), &request)
+	endSpan(span, err)
 	WriteResponse(c, response, err)
 }
 
+// startSpan 从请求头中提取链路上下文，创建服务端 span 并更新请求的上下文.
+func startSpan(c *gin.Context) trace.Span {
+	ctx := otel.GetTextMapPropagator().Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+	route := c.FullPath()
+	ctx, span := tracing.Tracer().Start(ctx, c.Request.Method+" "+route,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(c.Request.Method),
+			semconv.HTTPRoute(route),
+			semconv.URLPath(c.Request.URL.Path),
+		),
+	)
+	c.Request = c.Request.WithContext(ctx)
+
+	return span
+}
+
+// endSpan 记录请求的结果到 span 中.
+func endSpan(span trace.Span, err error) {
+	if err == nil {
+		span.SetAttributes(semconv.HTTPResponseStatusCode(http.StatusOK))
+		return
+	}
+
+	errx := errorsx.FromError(err)
+	span.SetAttributes(semconv.HTTPResponseStatusCode(errx.Code), attribute.String("error.reason", errx.Reason))
+	span.RecordError(err)
+	if errx.Code >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, errx.Message)
+	}
+}
+
 // ShouldBindJSON 使用 JSON 格式的绑定函数绑定请求参数并执行验证。
 func ShouldBindJSON[T any](c *gin.Context, rq *T, validators ...Validator[T]) error {
 	return ReadRequest(c, rq, c.ShouldBindJSON, validators...)
@@ -82,7 +135,7 @@ func ShouldBindUri[T any](c *gin.Context, rq *T, validators ...Validator[T]) err
 func ReadRequest[T any](c *gin.Context, rq *T, binder Binder, validators ...Validator[T]) error {
 	// 调用绑定函数绑定请求数据
 	if err := binder(rq); err != nil {
-		return errorsx.ErrBind.WithMessage(err.Error())
+		return errorsx.ErrBind.WithCause(err).WithMessage("%s", err.Error())
 	}
 
 	// 如果数据结构实现了 Default 接口，则调用它的 Default 方法
@@ -109,10 +162,21 @@ func WriteResponse(c *gin.Context, data any, err error) {
 	if err != nil {
 		// 如果发生错误，生成错误响应
 		errx := errorsx.FromError(err) // 提取错误详细信息
+		LogError(c.Request.Context(), err)
+		// 根据 Accept-Language 头本地化错误信息
+		errx, locale := errorsx.Localize(errx, c.GetHeader(HeaderAcceptLanguage))
+		if locale != "" {
+			c.Header(HeaderContentLanguage, locale)
+		}
+		if requestID := RequestID(c.Request.Context()); requestID != "" {
+			errx = errx.WithRequestID(requestID)
+		}
+		// 底层错误和调用栈只记录在日志中，不返回给客户端
 		c.JSON(errx.Code, ErrorResponse{
 			Reason:   errx.Reason,
 			Message:  errx.Message,
 			Metadata: errx.Metadata,
+			Details:  renderDetails(c.Request.Context(), errx.Details),
 		})
 		return
 	}
@@ -120,3 +184,38 @@ func WriteResponse(c *gin.Context, data any, err error) {
 	// 如果没有错误，返回成功响应
 	c.JSON(http.StatusOK, data)
 }
+
+// renderDetails 将错误详情转换为 google.protobuf.Any 的 JSON 格式，无法转换的详情会被忽略.
+func renderDetails(ctx context.Context, details []proto.Message) []json.RawMessage {
+	if len(details) == 0 {
+		return nil
+	}
+
+	rendered := make([]json.RawMessage, 0, len(details))
+	for _, detail := range details {
+		a, err := anypb.New(detail)
+		if err == nil {
+			var data []byte
+			if data, err = protojson.Marshal(a); err == nil {
+				rendered = append(rendered, data)
+				continue
+			}
+		}
+		slog.WarnContext(ctx, "Failed to render error detail", "type", detail.ProtoReflect().Descriptor().FullName(), "err", err)
+	}
+	return rendered
+}
+
+// LogError 记录 err 的完整错误链和调用栈（如果有）.
+// 服务端错误（5xx）使用 error 级别，其他错误使用 debug 级别.
+func LogError(ctx context.Context, err error) {
+	if err == nil {
+		return
+	}
+
+	level := slog.LevelDebug
+	if errorsx.Code(err) >= http.StatusInternalServerError {
+		level = slog.LevelError
+	}
+	slog.Log(ctx, level, "Request failed", "err", err)
+}
@@ -2,12 +2,12 @@ package db
 
 import (
 	"github.com/google/wire"
-	redis "github.com/redis/go-redis/v9"
 )
 
 // ProviderSet is db providers.
+// NewRedis returns redis.UniversalClient directly, backed by a single node,
+// sentinel or cluster client depending on RedisOptions.Mode.
 var ProviderSet = wire.NewSet(
 	NewMySQL,
 	NewRedis,
-	wire.Bind(new(redis.UniversalClient), new(*redis.Client)), // 正确绑定接口和实现
 )
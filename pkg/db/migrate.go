@@ -0,0 +1,534 @@
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// defaultMigrationTable is the table recording applied migrations.
+	defaultMigrationTable = "schema_migrations"
+	// defaultMigrationLock names the advisory lock taken while migrating.
+	defaultMigrationLock = "chunyu_migrate"
+	// defaultMigrationLockTimeout bounds the time spent waiting for the lock.
+	defaultMigrationLockTimeout = time.Minute
+)
+
+// migrationFile matches `<version>_<name>.up.sql` and `<version>_<name>.down.sql`.
+var migrationFile = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// ErrChecksumMismatch is returned when an applied migration file has been
+// modified after it was applied.
+var ErrChecksumMismatch = errors.New("migration checksum mismatch")
+
+// Migration is a versioned pair of up and down SQL scripts.
+type Migration struct {
+	Version uint64
+	Name    string
+	Up      string
+	Down    string
+	// Checksum is the hex encoded SHA-256 of Up.
+	Checksum string
+}
+
+// MigrationStatus describes a known or applied migration.
+type MigrationStatus struct {
+	Version   uint64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+	// Modified reports that the up script changed after it was applied.
+	Modified bool
+	// Missing reports an applied migration whose files no longer exist.
+	Missing bool
+}
+
+// schemaMigration is a row of the migration table.
+type schemaMigration struct {
+	Version   uint64    `gorm:"column:version;primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"column:name;size:255;not null"`
+	Checksum  string    `gorm:"column:checksum;size:64;not null"`
+	AppliedAt time.Time `gorm:"column:applied_at;not null"`
+}
+
+// Migrator applies versioned, checksummed SQL migrations to MySQL and
+// PostgreSQL databases.
+// It is recommended that a migrator be created with the NewMigrator() function.
+type Migrator struct {
+	db          *gorm.DB
+	source      fs.FS
+	table       string
+	lockName    string
+	lockTimeout time.Duration
+}
+
+// MigratorOption defines optional parameters for initializing the migrator.
+type MigratorOption func(*Migrator)
+
+// WithMigrationTable sets the table used to record applied migrations.
+func WithMigrationTable(table string) MigratorOption {
+	return func(m *Migrator) {
+		m.table = table
+	}
+}
+
+// WithMigrationLock sets the name of the advisory lock and how long to wait
+// for it.
+func WithMigrationLock(name string, timeout time.Duration) MigratorOption {
+	return func(m *Migrator) {
+		m.lockName = name
+		m.lockTimeout = timeout
+	}
+}
+
+// NewMigrator creates a migrator which reads migration files from the root of
+// source, e.g. os.DirFS("migrations") or an embed.FS sub tree.
+func NewMigrator(db *gorm.DB, source fs.FS, opts ...MigratorOption) *Migrator {
+	m := &Migrator{
+		db:          db,
+		source:      source,
+		table:       defaultMigrationTable,
+		lockName:    defaultMigrationLock,
+		lockTimeout: defaultMigrationLockTimeout,
+	}
+
+	for _, o := range opts {
+		o(m)
+	}
+
+	return m
+}
+
+// Migrations returns the migrations found in the source, ordered by version.
+func (m *Migrator) Migrations() ([]*Migration, error) {
+	entries, err := fs.ReadDir(m.source, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := make(map[uint64]*Migration)
+	for _, entry := range entries {
+		match := migrationFile.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseUint(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %q: %w", entry.Name(), err)
+		}
+		content, err := fs.ReadFile(m.source, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %q: %w", entry.Name(), err)
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = mig
+		} else if mig.Name != match[2] {
+			return nil, fmt.Errorf("duplicate migration version %d: %q and %q", version, mig.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			sum := sha256.Sum256(content)
+			mig.Up, mig.Checksum = string(content), hex.EncodeToString(sum[:])
+		} else {
+			mig.Down = string(content)
+		}
+	}
+
+	migrations := make([]*Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Checksum == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up script", mig.Version, mig.Name)
+		}
+		migrations = append(migrations, mig)
+	}
+	slices.SortFunc(migrations, func(a, b *Migration) int {
+		return compareVersion(a.Version, b.Version)
+	})
+
+	return migrations, nil
+}
+
+// Up applies at most n pending migrations in version order. n <= 0 applies
+// all of them. It refuses to run when an applied migration was modified.
+// It returns the applied migrations.
+func (m *Migrator) Up(ctx context.Context, n int) ([]*Migration, error) {
+	migrations, err := m.Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []*Migration
+	err = m.locked(ctx, func(tx *gorm.DB) error {
+		applied, err := m.applied(tx)
+		if err != nil {
+			return err
+		}
+
+		for _, mig := range migrations {
+			if rec, ok := applied[mig.Version]; ok {
+				if rec.Checksum != mig.Checksum {
+					return fmt.Errorf("%w: %d_%s", ErrChecksumMismatch, mig.Version, mig.Name)
+				}
+				continue
+			}
+			if n > 0 && len(done) >= n {
+				break
+			}
+
+			rec := &schemaMigration{Version: mig.Version, Name: mig.Name, Checksum: mig.Checksum, AppliedAt: time.Now()}
+			if err := m.exec(tx, mig, mig.Up, func(tx *gorm.DB) error {
+				return tx.Table(m.table).Create(rec).Error
+			}); err != nil {
+				return err
+			}
+			done = append(done, mig)
+		}
+
+		return nil
+	})
+
+	return done, err
+}
+
+// Down reverts the n most recently applied migrations. n <= 0 means 1.
+// It returns the reverted migrations.
+func (m *Migrator) Down(ctx context.Context, n int) ([]*Migration, error) {
+	if n <= 0 {
+		n = 1
+	}
+
+	migrations, err := m.Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []*Migration
+	err = m.locked(ctx, func(tx *gorm.DB) error {
+		applied, err := m.applied(tx)
+		if err != nil {
+			return err
+		}
+
+		for _, mig := range slices.Backward(migrations) {
+			if _, ok := applied[mig.Version]; !ok {
+				continue
+			}
+			if len(done) >= n {
+				break
+			}
+			if strings.TrimSpace(mig.Down) == "" {
+				return fmt.Errorf("migration %d_%s has no down script", mig.Version, mig.Name)
+			}
+
+			if err := m.exec(tx, mig, mig.Down, func(tx *gorm.DB) error {
+				return tx.Table(m.table).Where("version = ?", mig.Version).Delete(&schemaMigration{}).Error
+			}); err != nil {
+				return err
+			}
+			done = append(done, mig)
+		}
+
+		return nil
+	})
+
+	return done, err
+}
+
+// Status returns the state of every known or applied migration, ordered by
+// version.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	migrations, err := m.Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	tx := m.db.WithContext(WithPrimary(ctx))
+	if err := m.ensureTable(tx); err != nil {
+		return nil, err
+	}
+	applied, err := m.applied(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, mig := range migrations {
+		st := MigrationStatus{Version: mig.Version, Name: mig.Name}
+		if rec, ok := applied[mig.Version]; ok {
+			st.Applied, st.AppliedAt, st.Modified = true, rec.AppliedAt, rec.Checksum != mig.Checksum
+			delete(applied, mig.Version)
+		}
+		statuses = append(statuses, st)
+	}
+	for _, rec := range applied {
+		statuses = append(statuses, MigrationStatus{
+			Version: rec.Version, Name: rec.Name, Applied: true, AppliedAt: rec.AppliedAt, Missing: true,
+		})
+	}
+	slices.SortFunc(statuses, func(a, b MigrationStatus) int {
+		return compareVersion(a.Version, b.Version)
+	})
+
+	return statuses, nil
+}
+
+// CreateMigration writes empty up and down scripts for a new migration named
+// name into dir. The version is the current UTC time formatted as
+// 20060102150405. It returns the paths of the created files.
+func CreateMigration(dir, name string) ([]string, error) {
+	name = strings.Trim(regexp.MustCompile(`\W+`).ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if name == "" {
+		return nil, fmt.Errorf("migration name must contain at least one letter or digit")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	version := time.Now().UTC().Format("20060102150405")
+	files := make([]string, 0, 2)
+	for _, direction := range []string{"up", "down"} {
+		path := filepath.Join(dir, fmt.Sprintf("%s_%s.%s.sql", version, name, direction))
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err != nil {
+			return files, err
+		}
+		_, err = fmt.Fprintf(f, "-- %s migration %s_%s\n", direction, version, name)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return files, err
+		}
+		files = append(files, path)
+	}
+
+	return files, nil
+}
+
+// exec runs script and record in a single transaction. Note that MySQL
+// commits DDL statements implicitly, so a failing MySQL migration may be
+// partially applied.
+func (m *Migrator) exec(tx *gorm.DB, mig *Migration, script string, record func(*gorm.DB) error) error {
+	err := tx.Transaction(func(tx *gorm.DB) error {
+		for _, stmt := range SplitSQLStatements(script) {
+			if err := tx.Exec(stmt).Error; err != nil {
+				return err
+			}
+		}
+		return record(tx)
+	})
+	if err != nil {
+		return fmt.Errorf("migration %d_%s failed: %w", mig.Version, mig.Name, err)
+	}
+
+	return nil
+}
+
+// locked runs fn on a single connection holding the migration advisory lock.
+func (m *Migrator) locked(ctx context.Context, fn func(tx *gorm.DB) error) error {
+	return m.db.WithContext(ctx).Connection(func(tx *gorm.DB) (err error) {
+		// Connection passes a DB whose statement is shared by every chained
+		// call, start a session so that each statement gets its own.
+		tx = tx.Session(&gorm.Session{})
+
+		unlock, err := m.lock(tx)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if uerr := unlock(); err == nil {
+				err = uerr
+			}
+		}()
+
+		if err := m.ensureTable(tx); err != nil {
+			return err
+		}
+
+		return fn(tx)
+	})
+}
+
+// lock takes a session level advisory lock on the connection of tx.
+func (m *Migrator) lock(tx *gorm.DB) (func() error, error) {
+	switch tx.Dialector.Name() {
+	case "mysql":
+		var ok int
+		if err := tx.Raw("SELECT GET_LOCK(?, ?)", m.lockName, int(m.lockTimeout.Seconds())).Scan(&ok).Error; err != nil {
+			return nil, fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+		if ok != 1 {
+			return nil, fmt.Errorf("timed out waiting for migration lock %q", m.lockName)
+		}
+		return func() error { return tx.Exec("SELECT RELEASE_LOCK(?)", m.lockName).Error }, nil
+	case "postgres":
+		key := int64(crc32.ChecksumIEEE([]byte(m.lockName)))
+		if err := tx.Exec(fmt.Sprintf("SET lock_timeout = %d", m.lockTimeout.Milliseconds())).Error; err != nil {
+			return nil, err
+		}
+		if err := tx.Exec("SELECT pg_advisory_lock(?)", key).Error; err != nil {
+			return nil, fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+		return func() error {
+			if err := tx.Exec("SELECT pg_advisory_unlock(?)", key).Error; err != nil {
+				return err
+			}
+			return tx.Exec("RESET lock_timeout").Error
+		}, nil
+	default:
+		return nil, fmt.Errorf("migrations are not supported for dialect %q", tx.Dialector.Name())
+	}
+}
+
+func (m *Migrator) ensureTable(tx *gorm.DB) error {
+	return tx.Exec(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	version BIGINT NOT NULL PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	checksum VARCHAR(64) NOT NULL,
+	applied_at TIMESTAMP NOT NULL
+)`, m.table)).Error
+}
+
+func (m *Migrator) applied(tx *gorm.DB) (map[uint64]schemaMigration, error) {
+	var records []schemaMigration
+	if err := tx.Table(m.table).Order("version").Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to list applied migrations: %w", err)
+	}
+
+	applied := make(map[uint64]schemaMigration, len(records))
+	for _, rec := range records {
+		applied[rec.Version] = rec
+	}
+
+	return applied, nil
+}
+
+func compareVersion(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// SplitSQLStatements splits a script into statements separated by semicolons.
+// Semicolons inside quotes, backticks, PostgreSQL dollar-quoted strings and
+// `--` or `/* */` comments are ignored. Empty statements are dropped.
+func SplitSQLStatements(script string) []string {
+	var (
+		stmts []string
+		buf   strings.Builder
+	)
+
+	flush := func() {
+		if stmt := strings.TrimSpace(buf.String()); stmt != "" && !isCommentOnly(stmt) {
+			stmts = append(stmts, stmt)
+		}
+		buf.Reset()
+	}
+
+	for i := 0; i < len(script); i++ {
+		c := script[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			end := i + 1
+			for end < len(script) {
+				if script[end] == '\\' && c != '`' {
+					end += 2
+					continue
+				}
+				if script[end] == c {
+					break
+				}
+				end++
+			}
+			end = min(end, len(script)-1)
+			buf.WriteString(script[i : end+1])
+			i = end
+		case c == '-' && strings.HasPrefix(script[i:], "--"):
+			end := strings.IndexByte(script[i:], '\n')
+			if end < 0 {
+				end = len(script) - i
+			}
+			buf.WriteString(script[i : i+end])
+			i += end - 1
+		case c == '/' && strings.HasPrefix(script[i:], "/*"):
+			end := strings.Index(script[i+2:], "*/")
+			if end < 0 {
+				end = len(script) - i - 4
+			}
+			buf.WriteString(script[i : i+end+4])
+			i += end + 3
+		case c == '$' && (i == 0 || !isIdentByte(script[i-1])):
+			tag := dollarTag.FindString(script[i:])
+			if tag == "" {
+				buf.WriteByte(c)
+				continue
+			}
+			end := strings.Index(script[i+len(tag):], tag)
+			if end < 0 {
+				end = len(script) - i - 2*len(tag)
+			}
+			buf.WriteString(script[i : i+end+2*len(tag)])
+			i += end + 2*len(tag) - 1
+		case c == ';':
+			flush()
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	flush()
+
+	return stmts
+}
+
+// dollarTag matches the opening tag of a PostgreSQL dollar-quoted string.
+var dollarTag = regexp.MustCompile(`^\$[A-Za-z_]*\$`)
+
+// isCommentOnly reports whether stmt only holds `--` and `/* */` comments.
+func isCommentOnly(stmt string) bool {
+	for stmt = strings.TrimSpace(stmt); stmt != ""; stmt = strings.TrimSpace(stmt) {
+		switch {
+		case strings.HasPrefix(stmt, "--"):
+			end := strings.IndexByte(stmt, '\n')
+			if end < 0 {
+				return true
+			}
+			stmt = stmt[end+1:]
+		case strings.HasPrefix(stmt, "/*"):
+			end := strings.Index(stmt[2:], "*/")
+			if end < 0 {
+				return true
+			}
+			stmt = stmt[end+4:]
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c == '$' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
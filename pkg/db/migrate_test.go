@@ -0,0 +1,272 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestSplitSQLStatements(t *testing.T) {
+	script := `-- create users; with a comment
+CREATE TABLE users (
+    name varchar(64) not null comment 'login name; cannot rename',
+    ` + "`semi;colon`" + ` int
+);
+
+/* block; comment */
+INSERT INTO users(name) VALUES ('it''s; fine'), ("double; quoted");;
+
+CREATE FUNCTION noop() RETURNS trigger AS $body$
+BEGIN
+    RETURN NEW;
+END;
+$body$ LANGUAGE plpgsql;
+SELECT price$usd FROM t;
+/* commented out; */
+-- DROP TABLE users;
+/* trailing comment */`
+
+	stmts := SplitSQLStatements(script)
+	require.Len(t, stmts, 4)
+	assert.Contains(t, stmts[0], "'login name; cannot rename'")
+	assert.Contains(t, stmts[0], "`semi;colon`")
+	assert.Contains(t, stmts[1], `("double; quoted")`)
+	assert.Contains(t, stmts[2], "RETURN NEW;\nEND;\n$body$ LANGUAGE plpgsql")
+	assert.Equal(t, "SELECT price$usd FROM t", stmts[3])
+}
+
+func TestMigrator_Migrations(t *testing.T) {
+	source := fstest.MapFS{
+		"2_add_email.up.sql":     {Data: []byte("ALTER TABLE users ADD email varchar(64);")},
+		"2_add_email.down.sql":   {Data: []byte("ALTER TABLE users DROP email;")},
+		"1_init.up.sql":          {Data: []byte("CREATE TABLE users (id int);")},
+		"1_init.down.sql":        {Data: []byte("DROP TABLE users;")},
+		"README.md":              {Data: []byte("ignored")},
+		"10_no_down.up.sql":      {Data: []byte("SELECT 1;")},
+		"subdir/3_nested.up.sql": {Data: []byte("ignored")},
+	}
+
+	migrations, err := NewMigrator(nil, source).Migrations()
+	require.NoError(t, err)
+	require.Len(t, migrations, 3)
+
+	assert.Equal(t, []uint64{1, 2, 10}, []uint64{migrations[0].Version, migrations[1].Version, migrations[2].Version})
+	assert.Equal(t, "init", migrations[0].Name)
+	assert.Equal(t, "DROP TABLE users;", migrations[0].Down)
+	assert.Len(t, migrations[0].Checksum, 64)
+	assert.NotEqual(t, migrations[0].Checksum, migrations[1].Checksum)
+
+	_, err = NewMigrator(nil, fstest.MapFS{
+		"1_init.up.sql":  {Data: []byte("SELECT 1;")},
+		"1_other.up.sql": {Data: []byte("SELECT 2;")},
+	}).Migrations()
+	assert.ErrorContains(t, err, "duplicate migration version 1")
+
+	_, err = NewMigrator(nil, fstest.MapFS{"1_init.down.sql": {Data: []byte("SELECT 1;")}}).Migrations()
+	assert.ErrorContains(t, err, "has no up script")
+}
+
+func TestCreateMigration(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "migrations")
+
+	files, err := CreateMigration(dir, "Add User-Email")
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	assert.Regexp(t, `/\d{14}_add_user_email\.up\.sql$`, files[0])
+	assert.Regexp(t, `/\d{14}_add_user_email\.down\.sql$`, files[1])
+
+	migrations, err := NewMigrator(nil, os.DirFS(dir)).Migrations()
+	require.NoError(t, err)
+	require.Len(t, migrations, 1)
+	assert.Equal(t, "add_user_email", migrations[0].Name)
+
+	_, err = CreateMigration(dir, "--")
+	assert.Error(t, err)
+}
+
+// fakeMigrationDB emulates the statements run by the Migrator on MySQL: the
+// advisory lock and the migration table. Other statements are recorded, and
+// fail when they contain "boom".
+type fakeMigrationDB struct {
+	mu sync.Mutex
+	// busy simulates the lock being held by another migrator.
+	busy    bool
+	locked  bool
+	applied map[int64][]driver.Value
+	scripts []string
+	// unlockedScripts counts the scripts executed without holding the lock.
+	unlockedScripts int
+}
+
+func (f *fakeMigrationDB) handle(query string, args []driver.Value) ([]string, [][]driver.Value, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case strings.HasPrefix(query, "SELECT GET_LOCK"):
+		if f.busy {
+			return []string{"ok"}, [][]driver.Value{{int64(0)}}, nil
+		}
+		f.locked = true
+		return []string{"ok"}, [][]driver.Value{{int64(1)}}, nil
+	case strings.HasPrefix(query, "SELECT RELEASE_LOCK"):
+		f.locked = false
+	case strings.HasPrefix(query, "CREATE TABLE IF NOT EXISTS schema_migrations"):
+	case strings.HasPrefix(query, "SELECT * FROM `schema_migrations`"):
+		versions := slices.Sorted(maps.Keys(f.applied))
+		rows := make([][]driver.Value, 0, len(versions))
+		for _, v := range versions {
+			rows = append(rows, f.applied[v])
+		}
+		return []string{"version", "name", "checksum", "applied_at"}, rows, nil
+	case strings.HasPrefix(query, "INSERT INTO `schema_migrations`"):
+		f.applied[args[0].(int64)] = args
+	case strings.HasPrefix(query, "DELETE FROM `schema_migrations`"):
+		delete(f.applied, args[0].(int64))
+	default:
+		if strings.Contains(query, "boom") {
+			return nil, nil, errors.New("syntax error near boom")
+		}
+		if !f.locked {
+			f.unlockedScripts++
+		}
+		f.scripts = append(f.scripts, query)
+	}
+
+	return nil, nil, nil
+}
+
+// takeScripts returns the executed scripts and resets them.
+func (f *fakeMigrationDB) takeScripts() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	scripts := f.scripts
+	f.scripts = nil
+	return scripts
+}
+
+func newMigrationDB(t *testing.T) (*gorm.DB, *fakeMigrationDB) {
+	t.Helper()
+
+	fake := &fakeMigrationDB{applied: map[int64][]driver.Value{}}
+	dsn := "migrate-" + t.Name()
+	recorder.handle(t, dsn, fake.handle)
+
+	conn, err := sql.Open("chunyu-fake", dsn)
+	require.NoError(t, err)
+	gdb, err := gorm.Open(mysql.New(mysql.Config{Conn: conn, SkipInitializeWithVersion: true}), &gorm.Config{Logger: logger.Discard})
+	require.NoError(t, err)
+
+	return gdb, fake
+}
+
+func TestMigrator_UpDownStatus(t *testing.T) {
+	gdb, fake := newMigrationDB(t)
+	source := fstest.MapFS{
+		"1_init.up.sql":        {Data: []byte("CREATE TABLE users (id int);")},
+		"1_init.down.sql":      {Data: []byte("DROP TABLE users;")},
+		"2_add_email.up.sql":   {Data: []byte("ALTER TABLE users ADD email varchar(64); CREATE INDEX idx_email ON users (email);")},
+		"2_add_email.down.sql": {Data: []byte("ALTER TABLE users DROP email;")},
+	}
+	m := NewMigrator(gdb, source)
+	ctx := context.Background()
+
+	done, err := m.Up(ctx, 0)
+	require.NoError(t, err)
+	require.Len(t, done, 2)
+	assert.Equal(t, []string{
+		"CREATE TABLE users (id int)",
+		"ALTER TABLE users ADD email varchar(64)",
+		"CREATE INDEX idx_email ON users (email)",
+	}, fake.takeScripts())
+	assert.False(t, fake.locked, "the lock must be released")
+	assert.Zero(t, fake.unlockedScripts)
+
+	// Nothing is pending anymore.
+	done, err = m.Up(ctx, 0)
+	require.NoError(t, err)
+	assert.Empty(t, done)
+	assert.Empty(t, fake.takeScripts())
+
+	statuses, err := m.Status(ctx)
+	require.NoError(t, err)
+	require.Len(t, statuses, 2)
+	for _, st := range statuses {
+		assert.True(t, st.Applied, st.Name)
+		assert.False(t, st.Modified, st.Name)
+	}
+
+	// Down reverts the latest migration only.
+	done, err = m.Down(ctx, 0)
+	require.NoError(t, err)
+	require.Len(t, done, 1)
+	assert.EqualValues(t, 2, done[0].Version)
+	assert.Equal(t, []string{"ALTER TABLE users DROP email"}, fake.takeScripts())
+
+	// Up with a count applies that many pending migrations.
+	done, err = m.Up(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, done, 1)
+	assert.EqualValues(t, 2, done[0].Version)
+	fake.takeScripts()
+
+	// A migration modified after being applied is reported and blocks Up.
+	modified := maps.Clone(source)
+	modified["1_init.up.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE users (id bigint);")}
+	delete(modified, "2_add_email.up.sql")
+	delete(modified, "2_add_email.down.sql")
+	modified["3_add_name.up.sql"] = &fstest.MapFile{Data: []byte("ALTER TABLE users ADD name text;")}
+
+	_, err = NewMigrator(gdb, modified).Up(ctx, 0)
+	assert.ErrorIs(t, err, ErrChecksumMismatch)
+	assert.Empty(t, fake.takeScripts(), "no migration may run after a checksum mismatch")
+	assert.False(t, fake.locked)
+
+	statuses, err = NewMigrator(gdb, modified).Status(ctx)
+	require.NoError(t, err)
+	require.Len(t, statuses, 3)
+	assert.True(t, statuses[0].Modified)
+	assert.True(t, statuses[1].Missing)
+	assert.False(t, statuses[2].Applied)
+}
+
+func TestMigrator_Failures(t *testing.T) {
+	gdb, fake := newMigrationDB(t)
+	ctx := context.Background()
+
+	// A failing script is not recorded as applied, and the lock is released.
+	_, err := NewMigrator(gdb, fstest.MapFS{
+		"1_init.up.sql":   {Data: []byte("CREATE TABLE users (id int);")},
+		"2_broken.up.sql": {Data: []byte("boom;")},
+	}).Up(ctx, 0)
+	assert.ErrorContains(t, err, "migration 2_broken failed")
+	assert.Len(t, fake.applied, 1)
+	assert.False(t, fake.locked)
+
+	// Reverting a migration without down script fails.
+	_, err = NewMigrator(gdb, fstest.MapFS{"1_init.up.sql": {Data: []byte("CREATE TABLE users (id int);")}}).Down(ctx, 1)
+	assert.ErrorContains(t, err, "has no down script")
+
+	// Nothing runs while another migrator holds the lock.
+	fake.takeScripts()
+	fake.busy = true
+	_, err = NewMigrator(gdb, fstest.MapFS{"3_next.up.sql": {Data: []byte("SELECT 1;")}}, WithMigrationLock("test_lock", time.Second)).Up(ctx, 0)
+	assert.ErrorContains(t, err, `timed out waiting for migration lock "test_lock"`)
+	assert.Empty(t, fake.takeScripts())
+}
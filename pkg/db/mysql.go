@@ -1,11 +1,12 @@
 package db
 
 import (
-	"fmt"
+	"maps"
 	"time"
 
 	"database/sql"
 
+	mysqldriver "github.com/go-sql-driver/mysql"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
@@ -20,19 +21,69 @@ type MySQLOptions struct {
 	MaxIdleConnections    int
 	MaxOpenConnections    int
 	MaxConnectionLifeTime time.Duration
+	// Replicas are the addresses of read replicas. Reads are routed to them
+	// with ReplicaPolicy, writes and transactions always use Addr.
+	// +optional
+	Replicas []string
+	// ReplicaPolicy is one of PolicyRandom, PolicyRoundRobin or
+	// PolicyLeastLatency. Empty means PolicyRandom.
+	// +optional
+	ReplicaPolicy string
+	// Charset is the connection character set. Defaults to utf8mb4.
+	Charset string
+	// TimeZone is the IANA time zone used to parse DATETIME and TIMESTAMP
+	// values, e.g. "Local", "UTC" or "Asia/Shanghai". Defaults to Local.
+	TimeZone string
+	// TLSConfig is the name of the TLS config, one of "true", "false",
+	// "skip-verify", "preferred" or a name registered with
+	// mysql.RegisterTLSConfig.
+	// +optional
+	TLSConfig string
+	// +optional
+	ReadTimeout time.Duration
+	// +optional
+	WriteTimeout time.Duration
+	// Params are extra connection parameters appended to the DSN. They take
+	// precedence over Charset.
+	// +optional
+	Params map[string]string
+	// SlowThreshold is the duration above which queries are logged as slow
+	// by the TracePlugin. Defaults to 200ms.
+	// +optional
+	SlowThreshold time.Duration
 	// +optional
 	Logger logger.Interface
 }
 
 // DSN return DSN from MySQLOptions.
 func (o *MySQLOptions) DSN() string {
-	return fmt.Sprintf(`%s:%s@tcp(%s)/%s?charset=utf8&parseTime=%t&loc=%s`,
-		o.Username,
-		o.Password,
-		o.Addr,
-		o.Database,
-		true,
-		"Local")
+	cfg := mysqldriver.NewConfig()
+	cfg.User = o.Username
+	cfg.Passwd = o.Password
+	cfg.Net = "tcp"
+	cfg.Addr = o.Addr
+	cfg.DBName = o.Database
+	cfg.ParseTime = true
+	cfg.TLSConfig = o.TLSConfig
+	cfg.ReadTimeout = o.ReadTimeout
+	cfg.WriteTimeout = o.WriteTimeout
+
+	cfg.Loc = time.Local
+	if o.TimeZone != "" {
+		// An invalid time zone is reported by options validation; fall back
+		// to Local here so that DSN never fails.
+		if loc, err := time.LoadLocation(o.TimeZone); err == nil {
+			cfg.Loc = loc
+		}
+	}
+
+	cfg.Params = map[string]string{"charset": o.Charset}
+	if o.Charset == "" {
+		cfg.Params["charset"] = "utf8mb4"
+	}
+	maps.Copy(cfg.Params, o.Params)
+
+	return cfg.FormatDSN()
 }
 
 // NewMySQL create a new gorm db instance with the given options.
@@ -64,6 +115,25 @@ func NewMySQL(opts *MySQLOptions) (*gorm.DB, error) {
 	// SetMaxIdleConns sets the maximum number of connections in the idle connection pool.
 	sqlDB.SetMaxIdleConns(opts.MaxIdleConnections)
 
+	err = useReplicas(db, opts.Replicas, opts.ReplicaPolicy, func(addr string) (*sql.DB, error) {
+		replica := *opts
+		replica.Addr = addr
+		return openReplica(mysql.Open(replica.DSN()), opts.Logger, opts.MaxIdleConnections, opts.MaxOpenConnections, opts.MaxConnectionLifeTime)
+	})
+	if err != nil {
+		_ = sqlDB.Close()
+		return nil, err
+	}
+
+	if err := db.Use(NewTracePlugin(WithSlowThreshold(opts.SlowThreshold))); err != nil {
+		closeDB(db)
+		return nil, err
+	}
+	if err := RegisterPoolMetrics(db, "mysql:"+opts.Addr+"/"+opts.Database); err != nil {
+		closeDB(db)
+		return nil, err
+	}
+
 	return db, nil
 }
 
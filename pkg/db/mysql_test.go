@@ -0,0 +1,26 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMySQLOptions_DSN(t *testing.T) {
+	opts := &MySQLOptions{
+		Addr:     "127.0.0.1:3306",
+		Username: "onex",
+		Password: "onex(#)666",
+		Database: "onex",
+	}
+	assert.Equal(t, "onex:onex(#)666@tcp(127.0.0.1:3306)/onex?loc=Local&parseTime=true&charset=utf8mb4", opts.DSN())
+
+	opts.TimeZone = "Asia/Shanghai"
+	opts.TLSConfig = "skip-verify"
+	opts.ReadTimeout = 3 * time.Second
+	opts.WriteTimeout = 5 * time.Second
+	opts.Params = map[string]string{"charset": "utf8", "collation": "utf8_general_ci"}
+	assert.Equal(t, "onex:onex(#)666@tcp(127.0.0.1:3306)/onex?loc=Asia%2FShanghai&parseTime=true&readTimeout=3s"+
+		"&tls=skip-verify&writeTimeout=5s&charset=utf8&collation=utf8_general_ci", opts.DSN())
+}
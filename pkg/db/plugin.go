@@ -1,20 +1,109 @@
 package db
 
 import (
+	"context"
+	"errors"
+	"log/slog"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 	"gorm.io/gorm"
-	"k8s.io/klog/v2"
+
+	"chunyu/pkg/metrics"
+	"chunyu/pkg/tracing"
 )
 
 const (
 	callBackBeforeName = "core:before"
 	callBackAfterName  = "core:after"
-	startTime          = "_start_time"
+	queryState         = "_query_state"
+
+	// defaultSlowThreshold is the duration above which queries are logged as
+	// slow.
+	defaultSlowThreshold = 200 * time.Millisecond
 )
 
-// TracePlugin defines gorm plugin used to trace sql.
-type TracePlugin struct{}
+// QueryEvent describes a finished query.
+type QueryEvent struct {
+	// Operation is one of create, query, update, delete, row or raw.
+	Operation string
+	Table     string
+	// SQL is the statement with placeholders, the values are not included.
+	SQL          string
+	RowsAffected int64
+	// Err is the error of the query. gorm.ErrRecordNotFound is not reported.
+	Err      error
+	Duration time.Duration
+	// Slow reports whether Duration exceeded the slow query threshold.
+	Slow bool
+}
+
+// QueryHook observes the queries traced by a TracePlugin, e.g. to export them
+// to a metrics or tracing backend.
+type QueryHook interface {
+	// BeforeQuery is called before the query with the statement context. The
+	// returned context is passed to AfterQuery.
+	BeforeQuery(ctx context.Context, operation string) context.Context
+	// AfterQuery is called once the query finished.
+	AfterQuery(ctx context.Context, event *QueryEvent)
+}
+
+// TracePlugin defines gorm plugin used to trace sql. Every query is logged with
+// slog using the statement context, at debug level, or at warn level when
+// slower than the slow threshold, or at error level when it failed. Queries
+// are also passed to the configured hooks.
+// It is recommended that a trace plugin be created with the NewTracePlugin()
+// function.
+type TracePlugin struct {
+	logger        *slog.Logger
+	slowThreshold time.Duration
+	hooks         []QueryHook
+}
+
+// TracePluginOption defines optional parameters for initializing the trace
+// plugin.
+type TracePluginOption func(*TracePlugin)
+
+// WithSlowThreshold sets the duration above which queries are logged as slow.
+// Zero disables slow query logging.
+func WithSlowThreshold(d time.Duration) TracePluginOption {
+	return func(p *TracePlugin) {
+		p.slowThreshold = d
+	}
+}
+
+// WithQueryLogger sets the logger used to log queries. slog.Default is used by
+// default.
+func WithQueryLogger(logger *slog.Logger) TracePluginOption {
+	return func(p *TracePlugin) {
+		p.logger = logger
+	}
+}
+
+// WithQueryHooks replaces the hooks called for every query, which default to
+// MetricsHook and TracingHook.
+func WithQueryHooks(hooks ...QueryHook) TracePluginOption {
+	return func(p *TracePlugin) {
+		p.hooks = hooks
+	}
+}
+
+// NewTracePlugin creates a new trace plugin with the given options.
+func NewTracePlugin(opts ...TracePluginOption) *TracePlugin {
+	p := &TracePlugin{
+		slowThreshold: defaultSlowThreshold,
+		hooks:         []QueryHook{MetricsHook(), TracingHook()},
+	}
+
+	for _, o := range opts {
+		o(p)
+	}
+
+	return p
+}
 
 // Name returns the name of trace plugin.
 func (op *TracePlugin) Name() string {
@@ -24,39 +113,171 @@ func (op *TracePlugin) Name() string {
 // Initialize initialize the trace plugin.
 func (op *TracePlugin) Initialize(db *gorm.DB) (err error) {
 	// 开始前
-	_ = db.Callback().Create().Before("gorm:before_create").Register(callBackBeforeName, before)
-	_ = db.Callback().Query().Before("gorm:query").Register(callBackBeforeName, before)
-	_ = db.Callback().Delete().Before("gorm:before_delete").Register(callBackBeforeName, before)
-	_ = db.Callback().Update().Before("gorm:setup_reflect_value").Register(callBackBeforeName, before)
-	_ = db.Callback().Row().Before("gorm:row").Register(callBackBeforeName, before)
-	_ = db.Callback().Raw().Before("gorm:raw").Register(callBackBeforeName, before)
+	_ = db.Callback().Create().Before("gorm:before_create").Register(callBackBeforeName, op.before("create"))
+	_ = db.Callback().Query().Before("gorm:query").Register(callBackBeforeName, op.before("query"))
+	_ = db.Callback().Delete().Before("gorm:before_delete").Register(callBackBeforeName, op.before("delete"))
+	_ = db.Callback().Update().Before("gorm:setup_reflect_value").Register(callBackBeforeName, op.before("update"))
+	_ = db.Callback().Row().Before("gorm:row").Register(callBackBeforeName, op.before("row"))
+	_ = db.Callback().Raw().Before("gorm:raw").Register(callBackBeforeName, op.before("raw"))
 
 	// 结束后
-	_ = db.Callback().Create().After("gorm:after_create").Register(callBackAfterName, after)
-	_ = db.Callback().Query().After("gorm:after_query").Register(callBackAfterName, after)
-	_ = db.Callback().Delete().After("gorm:after_delete").Register(callBackAfterName, after)
-	_ = db.Callback().Update().After("gorm:after_update").Register(callBackAfterName, after)
-	_ = db.Callback().Row().After("gorm:row").Register(callBackAfterName, after)
-	_ = db.Callback().Raw().After("gorm:raw").Register(callBackAfterName, after)
+	_ = db.Callback().Create().After("gorm:after_create").Register(callBackAfterName, op.after)
+	_ = db.Callback().Query().After("gorm:after_query").Register(callBackAfterName, op.after)
+	_ = db.Callback().Delete().After("gorm:after_delete").Register(callBackAfterName, op.after)
+	_ = db.Callback().Update().After("gorm:after_update").Register(callBackAfterName, op.after)
+	_ = db.Callback().Row().After("gorm:row").Register(callBackAfterName, op.after)
+	_ = db.Callback().Raw().After("gorm:raw").Register(callBackAfterName, op.after)
 
 	return
 }
 
 var _ gorm.Plugin = &TracePlugin{}
 
-func before(db *gorm.DB) {
-	db.InstanceSet(startTime, time.Now())
+// state is the state of a query between the before and after callbacks.
+type state struct {
+	operation string
+	start     time.Time
+	// contexts are the contexts returned by the BeforeQuery of each hook.
+	contexts []context.Context
 }
 
-func after(db *gorm.DB) {
-	_ts, isExist := db.InstanceGet(startTime)
-	if !isExist {
-		return
+func (op *TracePlugin) before(operation string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		ctx := db.Statement.Context
+		if ctx == nil {
+			ctx = context.Background()
+		}
+
+		st := &state{operation: operation, contexts: make([]context.Context, len(op.hooks))}
+		for i, hook := range op.hooks {
+			st.contexts[i] = hook.BeforeQuery(ctx, operation)
+		}
+		st.start = time.Now()
+		db.InstanceSet(queryState, st)
 	}
+}
 
-	ts, ok := _ts.(time.Time)
+func (op *TracePlugin) after(db *gorm.DB) {
+	v, ok := db.InstanceGet(queryState)
 	if !ok {
 		return
 	}
-	klog.V(4).Infof("sql cost time: %fs", time.Since(ts).Seconds())
+	st, ok := v.(*state)
+	if !ok {
+		return
+	}
+	db.InstanceSet(queryState, nil)
+
+	event := &QueryEvent{
+		Operation:    st.operation,
+		Table:        db.Statement.Table,
+		SQL:          db.Statement.SQL.String(),
+		RowsAffected: db.Statement.RowsAffected,
+		Duration:     time.Since(st.start),
+	}
+	if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+		event.Err = db.Error
+	}
+	event.Slow = op.slowThreshold > 0 && event.Duration > op.slowThreshold
+
+	// Hooks are unwound in reverse order, like deferred calls.
+	for i := len(op.hooks) - 1; i >= 0; i-- {
+		op.hooks[i].AfterQuery(st.contexts[i], event)
+	}
+
+	op.log(db.Statement.Context, event)
+}
+
+// log logs event with the request scoped attributes held by ctx.
+func (op *TracePlugin) log(ctx context.Context, event *QueryEvent) {
+	logger := op.logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	level, msg := slog.LevelDebug, "SQL query"
+	switch {
+	case event.Err != nil:
+		level, msg = slog.LevelError, "SQL query failed"
+	case event.Slow:
+		level, msg = slog.LevelWarn, "Slow SQL query"
+	}
+	if !logger.Enabled(ctx, level) {
+		return
+	}
+
+	attrs := []slog.Attr{
+		slog.String("operation", event.Operation),
+		slog.String("table", event.Table),
+		slog.String("sql", event.SQL),
+		slog.Int64("rows", event.RowsAffected),
+		slog.Duration("duration", event.Duration),
+	}
+	if event.Slow {
+		attrs = append(attrs, slog.Duration("threshold", op.slowThreshold))
+	}
+	if event.Err != nil {
+		attrs = append(attrs, slog.String("err", event.Err.Error()))
+	}
+	logger.LogAttrs(ctx, level, msg, attrs...)
+}
+
+// QueryHookFuncs adapts a pair of functions to QueryHook. Either function may
+// be nil.
+type QueryHookFuncs struct {
+	Before func(ctx context.Context, operation string) context.Context
+	After  func(ctx context.Context, event *QueryEvent)
+}
+
+// BeforeQuery implements QueryHook.
+func (h QueryHookFuncs) BeforeQuery(ctx context.Context, operation string) context.Context {
+	if h.Before == nil {
+		return ctx
+	}
+	return h.Before(ctx, operation)
+}
+
+// AfterQuery implements QueryHook.
+func (h QueryHookFuncs) AfterQuery(ctx context.Context, event *QueryEvent) {
+	if h.After != nil {
+		h.After(ctx, event)
+	}
+}
+
+// MetricsHook returns a hook recording the duration and errors of queries in
+// the metrics package.
+func MetricsHook() QueryHook {
+	return QueryHookFuncs{After: func(_ context.Context, event *QueryEvent) {
+		metrics.ObserveDBQuery(event.Operation, event.Table, event.Duration, event.Err != nil)
+	}}
+}
+
+// TracingHook returns a hook creating a client span for every query, in the
+// trace of the statement context.
+func TracingHook() QueryHook {
+	return QueryHookFuncs{
+		Before: func(ctx context.Context, operation string) context.Context {
+			ctx, _ = tracing.Tracer().Start(ctx, "gorm."+operation,
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(semconv.DBOperationName(operation)),
+			)
+			return ctx
+		},
+		After: func(ctx context.Context, event *QueryEvent) {
+			span := trace.SpanFromContext(ctx)
+			span.SetAttributes(
+				semconv.DBCollectionName(event.Table),
+				semconv.DBQueryText(event.SQL),
+				attribute.Int64("db.rows_affected", event.RowsAffected),
+			)
+			if event.Err != nil {
+				span.RecordError(event.Err)
+				span.SetStatus(codes.Error, event.Err.Error())
+			}
+			span.End()
+		},
+	}
 }
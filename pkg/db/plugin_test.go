@@ -0,0 +1,62 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type ctxKey struct{}
+
+func TestTracePlugin(t *testing.T) {
+	conn, err := sql.Open("chunyu-fake", "trace")
+	require.NoError(t, err)
+	db, err := gorm.Open(mysql.New(mysql.Config{Conn: conn, SkipInitializeWithVersion: true}), &gorm.Config{Logger: logger.Discard})
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	var events []QueryEvent
+	hook := QueryHookFuncs{
+		Before: func(ctx context.Context, operation string) context.Context {
+			return context.WithValue(ctx, ctxKey{}, operation)
+		},
+		After: func(ctx context.Context, event *QueryEvent) {
+			assert.Equal(t, event.Operation, ctx.Value(ctxKey{}))
+			events = append(events, *event)
+		},
+	}
+	require.NoError(t, db.Use(NewTracePlugin(
+		WithQueryLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))),
+		WithSlowThreshold(time.Nanosecond),
+		WithQueryHooks(hook),
+	)))
+
+	ctx := context.WithValue(context.Background(), ctxKey{}, "request")
+	db.WithContext(ctx).Create(&user{Name: "a"})
+	db.WithContext(ctx).First(&user{})
+	recorder.take()
+
+	require.Len(t, events, 2)
+	assert.Equal(t, "create", events[0].Operation)
+	assert.Equal(t, "users", events[0].Table)
+	assert.Contains(t, events[0].SQL, "INSERT INTO `users`")
+	assert.Equal(t, int64(1), events[0].RowsAffected)
+	assert.True(t, events[0].Slow)
+	assert.NoError(t, events[0].Err)
+
+	assert.Equal(t, "query", events[1].Operation)
+	assert.NoError(t, events[1].Err, "record not found is not an error")
+
+	assert.Contains(t, buf.String(), `level=WARN msg="Slow SQL query" operation=create table=users`)
+	assert.Contains(t, buf.String(), "rows=1")
+	assert.Contains(t, buf.String(), "threshold=1ns")
+}
@@ -1,6 +1,7 @@
 package db
 
 import (
+	"database/sql"
 	"fmt"
 	"strings"
 	"time"
@@ -19,6 +20,18 @@ type PostgreSQLOptions struct {
 	MaxIdleConnections    int
 	MaxOpenConnections    int
 	MaxConnectionLifeTime time.Duration
+	// Replicas are the addresses of read replicas. Reads are routed to them
+	// with ReplicaPolicy, writes and transactions always use Addr.
+	// +optional
+	Replicas []string
+	// ReplicaPolicy is one of PolicyRandom, PolicyRoundRobin or
+	// PolicyLeastLatency. Empty means PolicyRandom.
+	// +optional
+	ReplicaPolicy string
+	// SlowThreshold is the duration above which queries are logged as slow
+	// by the TracePlugin. Defaults to 200ms.
+	// +optional
+	SlowThreshold time.Duration
 	// +optional
 	Logger logger.Interface
 }
@@ -69,6 +82,25 @@ func NewPostgreSQL(opts *PostgreSQLOptions) (*gorm.DB, error) {
 	// SetMaxIdleConns sets the maximum number of connections in the idle connection pool.
 	sqlDB.SetMaxIdleConns(opts.MaxIdleConnections)
 
+	err = useReplicas(db, opts.Replicas, opts.ReplicaPolicy, func(addr string) (*sql.DB, error) {
+		replica := *opts
+		replica.Addr = addr
+		return openReplica(postgres.Open(replica.DSN()), opts.Logger, opts.MaxIdleConnections, opts.MaxOpenConnections, opts.MaxConnectionLifeTime)
+	})
+	if err != nil {
+		_ = sqlDB.Close()
+		return nil, err
+	}
+
+	if err := db.Use(NewTracePlugin(WithSlowThreshold(opts.SlowThreshold))); err != nil {
+		closeDB(db)
+		return nil, err
+	}
+	if err := RegisterPoolMetrics(db, "postgresql:"+opts.Addr+"/"+opts.Database); err != nil {
+		closeDB(db)
+		return nil, err
+	}
+
 	return db, nil
 }
 
@@ -2,16 +2,45 @@ package db
 
 import (
 	"context"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/redis/go-redis/v9"
+
+	"chunyu/pkg/metrics"
+)
+
+// Supported redis deployment modes.
+const (
+	// RedisModeSingle connects to a single redis node at Addr.
+	RedisModeSingle = "single"
+	// RedisModeSentinel connects to the master named MasterName through the
+	// sentinels listed in Addrs, and follows failovers.
+	RedisModeSentinel = "sentinel"
+	// RedisModeCluster connects to a redis cluster using Addrs as seed nodes.
+	RedisModeCluster = "cluster"
 )
 
 // RedisOptions defines options for redis database.
 type RedisOptions struct {
-	Addr         string
-	Username     string
-	Password     string
+	// Mode is one of RedisModeSingle, RedisModeSentinel or RedisModeCluster.
+	// Empty means RedisModeSingle.
+	Mode string
+	// Addr is the address of the redis node in single mode.
+	Addr string
+	// Addrs are the sentinel addresses in sentinel mode, or the seed nodes in
+	// cluster mode.
+	Addrs []string
+	// MasterName is the name of the master monitored by the sentinels.
+	MasterName string
+	// SentinelUsername and SentinelPassword authenticate against the sentinels
+	// when they differ from the data nodes.
+	SentinelUsername string
+	SentinelPassword string
+	Username         string
+	Password         string
+	// Database is ignored in cluster mode, which only supports database 0.
 	Database     int
 	MaxRetries   int
 	MinIdleConns int
@@ -22,26 +51,77 @@ type RedisOptions struct {
 	PoolSize     int
 }
 
-// NewRedis create a new redis db instance with the given options.
-func NewRedis(opts *RedisOptions) (*redis.Client, error) {
-	options := &redis.Options{
-		Addr:         opts.Addr,
-		Username:     opts.Username,
-		Password:     opts.Password,
-		DB:           opts.Database,
-		MaxRetries:   opts.MaxRetries,
-		MinIdleConns: opts.MinIdleConns,
-		DialTimeout:  opts.DialTimeout,
-		ReadTimeout:  opts.ReadTimeout,
-		WriteTimeout: opts.WriteTimeout,
-		PoolTimeout:  opts.PoolTimeout,
-		PoolSize:     opts.PoolSize,
-	}
+// NewRedis create a new redis client for the configured deployment mode.
+// The returned client is a *redis.Client in single and sentinel mode and a
+// *redis.ClusterClient in cluster mode.
+func NewRedis(opts *RedisOptions) (redis.UniversalClient, error) {
+	var rdb redis.UniversalClient
 
-	rdb := redis.NewClient(options)
+	switch opts.Mode {
+	case "", RedisModeSingle:
+		rdb = redis.NewClient(&redis.Options{
+			Addr:         opts.Addr,
+			Username:     opts.Username,
+			Password:     opts.Password,
+			DB:           opts.Database,
+			MaxRetries:   opts.MaxRetries,
+			MinIdleConns: opts.MinIdleConns,
+			DialTimeout:  opts.DialTimeout,
+			ReadTimeout:  opts.ReadTimeout,
+			WriteTimeout: opts.WriteTimeout,
+			PoolTimeout:  opts.PoolTimeout,
+			PoolSize:     opts.PoolSize,
+		})
+	case RedisModeSentinel:
+		rdb = redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:       opts.MasterName,
+			SentinelAddrs:    opts.Addrs,
+			SentinelUsername: opts.SentinelUsername,
+			SentinelPassword: opts.SentinelPassword,
+			Username:         opts.Username,
+			Password:         opts.Password,
+			DB:               opts.Database,
+			MaxRetries:       opts.MaxRetries,
+			MinIdleConns:     opts.MinIdleConns,
+			DialTimeout:      opts.DialTimeout,
+			ReadTimeout:      opts.ReadTimeout,
+			WriteTimeout:     opts.WriteTimeout,
+			PoolTimeout:      opts.PoolTimeout,
+			PoolSize:         opts.PoolSize,
+		})
+	case RedisModeCluster:
+		rdb = redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:        opts.Addrs,
+			Username:     opts.Username,
+			Password:     opts.Password,
+			MaxRetries:   opts.MaxRetries,
+			MinIdleConns: opts.MinIdleConns,
+			DialTimeout:  opts.DialTimeout,
+			ReadTimeout:  opts.ReadTimeout,
+			WriteTimeout: opts.WriteTimeout,
+			PoolTimeout:  opts.PoolTimeout,
+			PoolSize:     opts.PoolSize,
+		})
+	default:
+		return nil, fmt.Errorf("unsupported redis mode %q", opts.Mode)
+	}
 
 	// check redis if is ok
 	if _, err := rdb.Ping(context.Background()).Result(); err != nil {
+		_ = rdb.Close()
+		return nil, err
+	}
+
+	name := opts.Addr
+	switch opts.Mode {
+	case RedisModeSentinel:
+		name = opts.MasterName
+	case RedisModeCluster:
+		name = strings.Join(opts.Addrs, ",")
+	}
+	rdb.AddHook(tracingHook{server: name})
+	if err := metrics.RegisterRedisPoolStats(name, rdb); err != nil {
+		_ = rdb.Close()
 		return nil, err
 	}
 
@@ -0,0 +1,82 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"chunyu/pkg/tracing"
+)
+
+// tracingHook is a redis hook creating a client span for every command and
+// pipeline.
+type tracingHook struct {
+	// server is the address or master name of the redis deployment.
+	server string
+}
+
+var _ redis.Hook = tracingHook{}
+
+// DialHook implements redis.Hook.
+func (h tracingHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		ctx, span := h.start(ctx, "redis.dial")
+		defer span.End()
+
+		conn, err := next(ctx, network, addr)
+		end(span, err)
+
+		return conn, err
+	}
+}
+
+// ProcessHook implements redis.Hook.
+func (h tracingHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		ctx, span := h.start(ctx, "redis."+cmd.Name(), semconv.DBOperationName(cmd.Name()))
+		defer span.End()
+
+		err := next(ctx, cmd)
+		end(span, err)
+
+		return err
+	}
+}
+
+// ProcessPipelineHook implements redis.Hook.
+func (h tracingHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		names := make([]string, 0, len(cmds))
+		for _, cmd := range cmds {
+			names = append(names, cmd.Name())
+		}
+
+		ctx, span := h.start(ctx, "redis.pipeline", semconv.DBOperationName(strings.Join(names, " ")))
+		defer span.End()
+
+		err := next(ctx, cmds)
+		end(span, err)
+
+		return err
+	}
+}
+
+func (h tracingHook) start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	attrs = append(attrs, semconv.DBSystemRedis, semconv.ServerAddress(h.server))
+	return tracing.Tracer().Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+}
+
+// end records err in span. A missing key is not a failure.
+func end(span trace.Span, err error) {
+	if err != nil && !errors.Is(err, redis.Nil) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
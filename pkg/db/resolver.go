@@ -0,0 +1,372 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/rand/v2"
+	"regexp"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"chunyu/pkg/metrics"
+)
+
+// Supported replica selection policies.
+const (
+	// PolicyRandom picks a replica at random for every read.
+	PolicyRandom = "random"
+	// PolicyRoundRobin cycles through the replicas in order.
+	PolicyRoundRobin = "round-robin"
+	// PolicyLeastLatency picks the replica with the lowest moving average
+	// query latency.
+	PolicyLeastLatency = "least-latency"
+)
+
+const (
+	callBackReplicaName = "chunyu:replica"
+	callBackRestoreName = "chunyu:restore_primary"
+	resolverRoute       = "_resolver_route"
+)
+
+// selectSQL matches statements which are safe to run on a replica.
+var selectSQL = regexp.MustCompile(`(?i)^\s*(select|show|describe|desc|explain)\s`)
+
+type routeKey struct{}
+
+// WithPrimary returns a copy of ctx which routes every query issued with it,
+// reads included, to the primary. Use it to read your own writes:
+//
+//	db.WithContext(db.WithPrimary(ctx)).First(&user)
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, routeKey{}, true)
+}
+
+func forcePrimary(ctx context.Context) bool {
+	primary, _ := ctx.Value(routeKey{}).(bool)
+	return primary
+}
+
+// Replica is a read-only connection pool.
+type Replica struct {
+	// Addr identifies the replica in logs.
+	Addr string
+
+	pool    gorm.ConnPool
+	latency atomic.Int64
+}
+
+// NewReplica creates a replica backed by the given connection pool, usually a
+// *sql.DB.
+func NewReplica(addr string, pool gorm.ConnPool) *Replica {
+	return &Replica{Addr: addr, pool: pool}
+}
+
+// Latency returns the exponentially weighted moving average of the query
+// latency observed on the replica, or zero if no query has finished yet.
+func (r *Replica) Latency() time.Duration {
+	return time.Duration(r.latency.Load())
+}
+
+// observe folds d into the moving average with a weight of 1/8.
+func (r *Replica) observe(d time.Duration) {
+	for {
+		old := r.latency.Load()
+		next := int64(d)
+		if old != 0 {
+			next = old + (int64(d)-old)/8
+		}
+		if r.latency.CompareAndSwap(old, next) {
+			return
+		}
+	}
+}
+
+// ReplicaPolicy selects the replica used for a read. replicas is never empty.
+type ReplicaPolicy interface {
+	Resolve(replicas []*Replica) *Replica
+}
+
+// ReplicaPolicyFunc adapts a function to a ReplicaPolicy.
+type ReplicaPolicyFunc func(replicas []*Replica) *Replica
+
+// Resolve calls f(replicas).
+func (f ReplicaPolicyFunc) Resolve(replicas []*Replica) *Replica {
+	return f(replicas)
+}
+
+// NewReplicaPolicy returns the policy registered under name. Empty means
+// PolicyRandom.
+func NewReplicaPolicy(name string) (ReplicaPolicy, error) {
+	switch name {
+	case "", PolicyRandom:
+		return ReplicaPolicyFunc(func(replicas []*Replica) *Replica {
+			return replicas[rand.IntN(len(replicas))]
+		}), nil
+	case PolicyRoundRobin:
+		var next atomic.Uint64
+		return ReplicaPolicyFunc(func(replicas []*Replica) *Replica {
+			return replicas[(next.Add(1)-1)%uint64(len(replicas))]
+		}), nil
+	case PolicyLeastLatency:
+		return ReplicaPolicyFunc(leastLatency), nil
+	default:
+		return nil, fmt.Errorf("unsupported replica policy %q, must be one of random, round-robin, least-latency", name)
+	}
+}
+
+// leastLatency picks the replica with the lowest latency. Replicas without
+// observations are tried first, and one read in ten goes to a random replica
+// so that the averages of slower replicas keep being refreshed.
+func leastLatency(replicas []*Replica) *Replica {
+	if rand.IntN(10) == 0 {
+		return replicas[rand.IntN(len(replicas))]
+	}
+
+	best := replicas[0]
+	for _, r := range replicas[1:] {
+		if r.Latency() < best.Latency() {
+			best = r
+		}
+	}
+
+	return best
+}
+
+// Resolver defines a gorm plugin which routes reads to replicas and keeps
+// writes, locking reads and transactions on the primary.
+type Resolver struct {
+	primary  gorm.ConnPool
+	replicas []*Replica
+	policy   ReplicaPolicy
+}
+
+// NewResolver creates a resolver which routes reads to the given replicas
+// using policy. A nil policy means PolicyRandom.
+func NewResolver(policy ReplicaPolicy, replicas ...*Replica) *Resolver {
+	if policy == nil {
+		policy, _ = NewReplicaPolicy(PolicyRandom)
+	}
+
+	return &Resolver{replicas: replicas, policy: policy}
+}
+
+// Name returns the name of resolver plugin.
+func (r *Resolver) Name() string {
+	return "resolverPlugin"
+}
+
+// Initialize initialize the resolver plugin.
+func (r *Resolver) Initialize(db *gorm.DB) error {
+	r.primary = db.ConnPool
+
+	if err := db.Callback().Query().Before("gorm:query").Register(callBackReplicaName, r.switchReplica); err != nil {
+		return err
+	}
+	if err := db.Callback().Query().After("gorm:after_query").Register(callBackRestoreName, r.restore); err != nil {
+		return err
+	}
+	if err := db.Callback().Row().Before("gorm:row").Register(callBackReplicaName, r.switchReplica); err != nil {
+		return err
+	}
+
+	return db.Callback().Row().After("gorm:row").Register(callBackRestoreName, r.restore)
+}
+
+// Replicas returns the replicas managed by the resolver.
+func (r *Resolver) Replicas() []*Replica {
+	return r.replicas
+}
+
+// Close closes the replica pools which implement io.Closer.
+func (r *Resolver) Close() error {
+	var errs []error
+	for _, replica := range r.replicas {
+		if closer, ok := replica.pool.(interface{ Close() error }); ok {
+			if err := closer.Close(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to close replicas: %v", errs)
+	}
+	return nil
+}
+
+func (r *Resolver) switchReplica(db *gorm.DB) {
+	if db.Error != nil || len(r.replicas) == 0 {
+		return
+	}
+
+	// Transactions and sessions with a custom pool use their own connection.
+	if db.Statement.ConnPool != r.primary {
+		return
+	}
+	if forcePrimary(db.Statement.Context) {
+		return
+	}
+	// SELECT ... FOR UPDATE/SHARE must see and lock the primary rows.
+	if _, ok := db.Statement.Clauses["FOR"]; ok {
+		return
+	}
+	// Raw SQL passed to Row/Rows may be a write.
+	if sql := db.Statement.SQL.String(); sql != "" && !selectSQL.MatchString(sql) {
+		return
+	}
+
+	replica := r.policy.Resolve(r.replicas)
+	db.InstanceSet(resolverRoute, &route{pool: db.Statement.ConnPool, replica: replica, start: time.Now()})
+	db.Statement.ConnPool = replica.pool
+}
+
+// restore puts the primary pool back so that a reused statement does not
+// send a later write or transaction to the replica.
+func (r *Resolver) restore(db *gorm.DB) {
+	v, _ := db.InstanceGet(resolverRoute)
+	rt, ok := v.(*route)
+	if !ok {
+		return
+	}
+	db.InstanceSet(resolverRoute, nil)
+
+	db.Statement.ConnPool = rt.pool
+	rt.replica.observe(time.Since(rt.start))
+}
+
+// route records a read switched to a replica.
+type route struct {
+	pool    gorm.ConnPool
+	replica *Replica
+	start   time.Time
+}
+
+var _ gorm.Plugin = &Resolver{}
+
+// useReplicas opens a connection pool for every replica address with open and
+// registers a Resolver on db.
+func useReplicas(db *gorm.DB, addrs []string, policyName string, open func(addr string) (*sql.DB, error)) error {
+	if len(addrs) == 0 {
+		return nil
+	}
+
+	policy, err := NewReplicaPolicy(policyName)
+	if err != nil {
+		return err
+	}
+
+	replicas := make([]*Replica, 0, len(addrs))
+	for _, addr := range addrs {
+		pool, err := open(addr)
+		if err != nil {
+			_ = NewResolver(policy, replicas...).Close()
+			return fmt.Errorf("failed to open replica %s: %w", addr, err)
+		}
+		replicas = append(replicas, NewReplica(addr, pool))
+	}
+
+	resolver := NewResolver(policy, replicas...)
+	if err := db.Use(resolver); err != nil {
+		_ = resolver.Close()
+		return err
+	}
+
+	return nil
+}
+
+// closeDB closes the primary pool of db and the pools of its replicas. It
+// releases a database whose setup failed.
+func closeDB(db *gorm.DB) {
+	if plugin, ok := db.Config.Plugins[(&Resolver{}).Name()].(*Resolver); ok {
+		_ = plugin.Close()
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		_ = sqlDB.Close()
+	}
+}
+
+// openReplica opens a replica connection pool with the pool limits of the
+// primary.
+func openReplica(dialector gorm.Dialector, log logger.Interface, maxIdle, maxOpen int, lifetime time.Duration) (*sql.DB, error) {
+	db, err := gorm.Open(dialector, &gorm.Config{Logger: log})
+	if err != nil {
+		return nil, err
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+
+	sqlDB.SetMaxOpenConns(maxOpen)
+	sqlDB.SetConnMaxLifetime(lifetime)
+	sqlDB.SetMaxIdleConns(maxIdle)
+
+	return sqlDB, nil
+}
+
+// SetPoolLimits applies the connection pool limits to the primary pool of db
+// and to the pools of its replicas. It can be called at any time, for example
+// when the configuration is reloaded.
+func SetPoolLimits(db *gorm.DB, maxIdle, maxOpen int, lifetime time.Duration) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+
+	pools := []*sql.DB{sqlDB}
+	if plugin, ok := db.Config.Plugins[(&Resolver{}).Name()].(*Resolver); ok {
+		for _, replica := range plugin.replicas {
+			if pool, ok := replica.pool.(*sql.DB); ok {
+				pools = append(pools, pool)
+			}
+		}
+	}
+
+	for _, pool := range pools {
+		pool.SetMaxOpenConns(maxOpen)
+		pool.SetConnMaxLifetime(lifetime)
+		pool.SetMaxIdleConns(maxIdle)
+	}
+
+	return nil
+}
+
+// RegisterPoolMetrics exports the sql.DBStats of the primary pool of db,
+// labelled with name, and of the pools of its replicas, labelled with
+// name@address. name must identify the pool in the process, NewMySQL and
+// NewPostgreSQL use <driver>:<address>/<database>. If a pool is already
+// registered with one of the names, nothing is registered and the
+// prometheus.AlreadyRegisteredError is returned.
+func RegisterPoolMetrics(db *gorm.DB, name string) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+
+	pools := map[string]*sql.DB{name: sqlDB}
+	if plugin, ok := db.Config.Plugins[(&Resolver{}).Name()].(*Resolver); ok {
+		for _, replica := range plugin.replicas {
+			if pool, ok := replica.pool.(*sql.DB); ok {
+				pools[name+"@"+replica.Addr] = pool
+			}
+		}
+	}
+
+	var registered []string
+	for poolName, pool := range pools {
+		if err := metrics.RegisterDBStats(poolName, pool); err != nil {
+			for _, n := range registered {
+				metrics.UnregisterDBStats(n)
+			}
+			return err
+		}
+		registered = append(registered, poolName)
+	}
+
+	return nil
+}
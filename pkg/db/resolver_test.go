@@ -0,0 +1,291 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
+
+	"chunyu/pkg/metrics"
+)
+
+// fakeDriver is a database/sql driver which records the statements executed
+// on every DSN and returns empty results, or the results of the handler
+// registered for the DSN.
+type fakeDriver struct {
+	mu       sync.Mutex
+	calls    []string
+	handlers map[string]fakeHandler
+}
+
+// fakeHandler answers the statements executed on a DSN. It returns the
+// columns and rows of queries, which are ignored for other statements.
+type fakeHandler func(query string, args []driver.Value) (columns []string, rows [][]driver.Value, err error)
+
+var recorder = &fakeDriver{handlers: map[string]fakeHandler{}}
+
+func init() {
+	sql.Register("chunyu-fake", recorder)
+}
+
+func (d *fakeDriver) Open(name string) (driver.Conn, error) {
+	return &fakeConn{dsn: name}, nil
+}
+
+func (d *fakeDriver) record(dsn, query string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.calls = append(d.calls, dsn+": "+strings.Fields(query)[0])
+}
+
+// take returns the recorded calls and resets the recorder.
+func (d *fakeDriver) take() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	calls := d.calls
+	d.calls = nil
+	return calls
+}
+
+// handle registers h for the statements executed on dsn until the test ends.
+func (d *fakeDriver) handle(t *testing.T, dsn string, h fakeHandler) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.handlers[dsn] = h
+
+	t.Cleanup(func() {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		delete(d.handlers, dsn)
+	})
+}
+
+// run records query and passes it to the handler of dsn, if any.
+func (d *fakeDriver) run(dsn, query string, args []driver.Value) (*fakeRows, error) {
+	d.record(dsn, query)
+
+	d.mu.Lock()
+	h := d.handlers[dsn]
+	d.mu.Unlock()
+	if h == nil {
+		return &fakeRows{}, nil
+	}
+
+	columns, rows, err := h(query, args)
+	if err != nil {
+		return nil, err
+	}
+	return &fakeRows{columns: columns, rows: rows}, nil
+}
+
+type fakeConn struct{ dsn string }
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{dsn: c.dsn, query: query}, nil
+}
+func (c *fakeConn) Close() error              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) { return fakeTx{}, nil }
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeStmt struct{ dsn, query string }
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	if _, err := recorder.run(s.dsn, s.query, args); err != nil {
+		return nil, err
+	}
+	return fakeResult{}, nil
+}
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return recorder.run(s.dsn, s.query, args)
+}
+
+type fakeResult struct{}
+
+func (fakeResult) LastInsertId() (int64, error) { return 1, nil }
+func (fakeResult) RowsAffected() (int64, error) { return 1, nil }
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+type user struct {
+	ID   uint
+	Name string
+}
+
+func newResolverDB(t *testing.T, policy string, replicas ...string) *gorm.DB {
+	t.Helper()
+
+	primary, err := sql.Open("chunyu-fake", "primary")
+	require.NoError(t, err)
+
+	db, err := gorm.Open(mysql.New(mysql.Config{Conn: primary, SkipInitializeWithVersion: true}), &gorm.Config{
+		PrepareStmt: true,
+		Logger:      logger.Discard,
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, useReplicas(db, replicas, policy, func(addr string) (*sql.DB, error) {
+		return sql.Open("chunyu-fake", addr)
+	}))
+	recorder.take()
+
+	return db
+}
+
+func TestResolver_Routing(t *testing.T) {
+	db := newResolverDB(t, PolicyRandom, "replica")
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		run  func()
+		want []string
+	}{
+		{"query", func() { db.Find(&[]user{}) }, []string{"replica: SELECT"}},
+		{"create", func() { db.Create(&user{Name: "a"}) }, []string{"primary: INSERT"}},
+		{"update", func() { db.Model(&user{ID: 1}).Update("name", "b") }, []string{"primary: UPDATE"}},
+		{"delete", func() { db.Delete(&user{ID: 1}) }, []string{"primary: DELETE"}},
+		{"locking read", func() {
+			db.Clauses(clause.Locking{Strength: "UPDATE"}).Find(&[]user{})
+		}, []string{"primary: SELECT"}},
+		{"context override", func() { db.WithContext(WithPrimary(ctx)).Find(&[]user{}) }, []string{"primary: SELECT"}},
+		{"transaction", func() {
+			_ = db.Transaction(func(tx *gorm.DB) error {
+				return tx.Find(&[]user{}).Error
+			})
+		}, []string{"primary: SELECT"}},
+		{"raw select", func() {
+			rows, _ := db.Raw("SELECT 1").Rows()
+			rows.Close()
+		}, []string{"replica: SELECT"}},
+		{"raw write", func() {
+			rows, _ := db.Raw("UPDATE users SET name = 'c' RETURNING id").Rows()
+			rows.Close()
+		}, []string{"primary: UPDATE"}},
+		{"exec", func() { db.Exec("SELECT 1") }, []string{"primary: SELECT"}},
+		{"reused statement", func() {
+			q := db.Model(&user{}).Where("id = ?", 1)
+			q.Find(&[]user{})
+			q.Update("name", "d")
+		}, []string{"replica: SELECT", "primary: UPDATE"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.run()
+			assert.Equal(t, tt.want, recorder.take())
+		})
+	}
+}
+
+func TestResolver_RoundRobin(t *testing.T) {
+	db := newResolverDB(t, PolicyRoundRobin, "replica-1", "replica-2")
+
+	for range 4 {
+		db.Find(&[]user{})
+	}
+
+	assert.Equal(t, []string{"replica-1: SELECT", "replica-2: SELECT", "replica-1: SELECT", "replica-2: SELECT"}, recorder.take())
+}
+
+func TestLeastLatency(t *testing.T) {
+	fast, slow := NewReplica("fast", nil), NewReplica("slow", nil)
+	fast.observe(1)
+	slow.observe(100)
+
+	policy, err := NewReplicaPolicy(PolicyLeastLatency)
+	require.NoError(t, err)
+
+	picked := 0
+	for range 100 {
+		if policy.Resolve([]*Replica{slow, fast}) == fast {
+			picked++
+		}
+	}
+	assert.Greater(t, picked, 80)
+}
+
+func TestSetPoolLimits(t *testing.T) {
+	db := newResolverDB(t, PolicyRandom, "replica")
+
+	require.NoError(t, SetPoolLimits(db, 2, 3, 0))
+
+	primary, err := db.DB()
+	require.NoError(t, err)
+	assert.Equal(t, 3, primary.Stats().MaxOpenConnections)
+
+	replica := db.Config.Plugins["resolverPlugin"].(*Resolver).Replicas()[0]
+	assert.Equal(t, 3, replica.pool.(*sql.DB).Stats().MaxOpenConnections)
+}
+
+func TestRegisterPoolMetrics(t *testing.T) {
+	db := newResolverDB(t, PolicyRandom, "replica")
+	t.Cleanup(func() {
+		metrics.UnregisterDBStats("mysql:primary/app")
+		metrics.UnregisterDBStats("mysql:primary/app@replica")
+	})
+
+	require.NoError(t, RegisterPoolMetrics(db, "mysql:primary/app"))
+
+	// Another pool with the same name is rejected instead of replacing the
+	// statistics of the first one.
+	var are prometheus.AlreadyRegisteredError
+	require.ErrorAs(t, RegisterPoolMetrics(newResolverDB(t, PolicyRandom), "mysql:primary/app"), &are)
+
+	// A partially registered pool is rolled back: the primary is free, but
+	// the replica collides.
+	other := newResolverDB(t, PolicyRandom, "replica")
+	require.True(t, metrics.UnregisterDBStats("mysql:primary/app"))
+	require.ErrorAs(t, RegisterPoolMetrics(other, "mysql:primary/app"), &are)
+	assert.False(t, metrics.UnregisterDBStats("mysql:primary/app"))
+}
+
+func TestCloseDB(t *testing.T) {
+	db := newResolverDB(t, PolicyRandom, "replica")
+
+	// The replicas opened for a resolver which cannot be used are closed.
+	var opened *sql.DB
+	err := useReplicas(db, []string{"other"}, PolicyRandom, func(addr string) (*sql.DB, error) {
+		opened, _ = sql.Open("chunyu-fake", addr)
+		return opened, nil
+	})
+	require.Error(t, err)
+	assert.ErrorContains(t, opened.Ping(), "database is closed")
+
+	closeDB(db)
+	primary, err := db.DB()
+	require.NoError(t, err)
+	assert.ErrorContains(t, primary.Ping(), "database is closed")
+	replica := db.Config.Plugins["resolverPlugin"].(*Resolver).Replicas()[0]
+	assert.ErrorContains(t, replica.pool.(*sql.DB).Ping(), "database is closed")
+}
@@ -0,0 +1,156 @@
+package errorsx
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// 错误码目录支持的输出格式.
+const (
+	CatalogMarkdown = "markdown"
+	CatalogJSON     = "json"
+	CatalogOpenAPI  = "openapi"
+)
+
+// CatalogEntry 是错误码目录中的一项.
+type CatalogEntry struct {
+	Reason   string `json:"reason"`
+	Code     int    `json:"code"`
+	GRPCCode string `json:"grpcCode"`
+	Message  string `json:"message"`
+	Doc      string `json:"doc,omitempty"`
+}
+
+// Catalog 返回 defs 对应的错误码目录.
+func Catalog(defs []Definition) []CatalogEntry {
+	entries := make([]CatalogEntry, len(defs))
+	for i, def := range defs {
+		entries[i] = CatalogEntry{
+			Reason:   def.Reason,
+			Code:     def.Code,
+			GRPCCode: def.grpcCode().String(),
+			Message:  def.Message,
+			Doc:      def.Doc,
+		}
+	}
+	return entries
+}
+
+// WriteCatalog 将 defs 以 format 格式写入 w，format 可以是 markdown、json 或 openapi.
+// openapi 格式输出 OpenAPI 3 文档的 components 部分，每个错误是一个以 Reason 命名的 response.
+func WriteCatalog(w io.Writer, defs []Definition, format string) error {
+	entries := Catalog(defs)
+
+	switch format {
+	case CatalogMarkdown, "md":
+		return writeMarkdown(w, entries)
+	case CatalogJSON:
+		return writeJSON(w, entries)
+	case CatalogOpenAPI:
+		components, err := openAPIComponents(entries)
+		if err != nil {
+			return err
+		}
+		return writeJSON(w, components)
+	default:
+		return fmt.Errorf("unsupported catalog format %q, must be markdown, json or openapi", format)
+	}
+}
+
+// writeMarkdown 将 entries 写成 Markdown 表格.
+func writeMarkdown(w io.Writer, entries []CatalogEntry) error {
+	var b strings.Builder
+	b.WriteString("# Error Catalog\n\n")
+	b.WriteString("| Reason | HTTP Code | gRPC Code | Message | Description |\n")
+	b.WriteString("| --- | --- | --- | --- | --- |\n")
+	for _, e := range entries {
+		fmt.Fprintf(&b, "| `%s` | %d | %s | %s | %s |\n",
+			e.Reason, e.Code, e.GRPCCode, markdownCell(e.Message), markdownCell(e.Doc))
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// markdownCell 转义 s 以便放入 Markdown 表格的单元格.
+func markdownCell(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.ReplaceAll(s, "\n", "<br>")
+}
+
+// writeJSON 将 v 写成带缩进的 JSON.
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// invalidComponentKey 匹配 OpenAPI components 的 key 中不允许出现的字符.
+var invalidComponentKey = regexp.MustCompile(`[^a-zA-Z0-9._-]`)
+
+// openAPIComponents 返回 entries 对应的 OpenAPI components.
+// ErrorResponse schema 与 core.ErrorResponse 的 JSON 格式一致.
+// 不同的 Reason 替换非法字符后得到相同的 key 时返回错误，例如 Quota/Exceeded 与 Quota_Exceeded.
+func openAPIComponents(entries []CatalogEntry) (map[string]any, error) {
+	responses := make(map[string]any, len(entries))
+	reasons := make(map[string]string, len(entries))
+	for _, e := range entries {
+		key := invalidComponentKey.ReplaceAllString(e.Reason, "_")
+		if reason, ok := reasons[key]; ok {
+			return nil, fmt.Errorf("errorsx: reasons %s and %s map to the same OpenAPI component %s", reason, e.Reason, key)
+		}
+		reasons[key] = e.Reason
+
+		description := e.Doc
+		if description == "" {
+			description = e.Message
+		}
+
+		responses[key] = map[string]any{
+			"description": description,
+			"content": map[string]any{
+				"application/json": map[string]any{
+					"schema": map[string]any{"$ref": "#/components/schemas/ErrorResponse"},
+					"example": map[string]any{
+						"reason":  e.Reason,
+						"message": e.Message,
+					},
+				},
+			},
+			"x-http-code": e.Code,
+			"x-grpc-code": e.GRPCCode,
+		}
+	}
+
+	return map[string]any{
+		"components": map[string]any{
+			"schemas": map[string]any{
+				"ErrorResponse": map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"reason":  map[string]any{"type": "string", "description": "Business error code identifying the error."},
+						"message": map[string]any{"type": "string", "description": "Human readable error message."},
+						"metadata": map[string]any{
+							"type":                 "object",
+							"description":          "Additional information, e.g. the X-Request-ID of the request.",
+							"additionalProperties": map[string]any{"type": "string"},
+						},
+						"details": map[string]any{
+							"type":        "array",
+							"description": "Error details in the JSON format of google.protobuf.Any, e.g. google.rpc.BadRequest.",
+							"items": map[string]any{
+								"type":                 "object",
+								"properties":           map[string]any{"@type": map[string]any{"type": "string"}},
+								"additionalProperties": true,
+							},
+						},
+					},
+				},
+			},
+			"responses": responses,
+		},
+	}, nil
+}
@@ -2,29 +2,80 @@ package errorsx
 
 import "net/http"
 
-// errorsx 预定义标准的错误.
+// errorsx 预定义标准的错误，它们都注册在 DefaultRegistry 中.
+// 它们是只读的模板，使用 WithMessage、KV 等方法派生新的错误，不要直接修改其字段.
 var (
 	// OK 代表请求成功.
 	OK = &ErrorX{Code: http.StatusOK, Message: ""}
 
 	// ErrInternal 表示所有未知的服务器端错误.
-	ErrInternal = &ErrorX{Code: http.StatusInternalServerError, Reason: "InternalError", Message: "Internal server error."}
+	ErrInternal = Register(Definition{
+		Code:    http.StatusInternalServerError,
+		Reason:  "InternalError",
+		Message: "Internal server error.",
+		Doc:     "An unexpected error occurred on the server side.",
+	})
 
 	// ErrNotFound 表示资源未找到.
-	ErrNotFound = &ErrorX{Code: http.StatusNotFound, Reason: "NotFound", Message: "Resource not found."}
+	ErrNotFound = Register(Definition{
+		Code:    http.StatusNotFound,
+		Reason:  "NotFound",
+		Message: "Resource not found.",
+		Doc:     "The requested resource does not exist.",
+	})
 
 	// ErrBind 表示请求体绑定错误.
-	ErrBind = &ErrorX{Code: http.StatusBadRequest, Reason: "BindError", Message: "Error occurred while binding the request body to the struct."}
+	ErrBind = Register(Definition{
+		Code:    http.StatusBadRequest,
+		Reason:  "BindError",
+		Message: "Error occurred while binding the request body to the struct.",
+		Doc:     "The request body, query or path parameters could not be decoded.",
+	})
 
 	// ErrInvalidArgument 表示参数验证失败.
-	ErrInvalidArgument = &ErrorX{Code: http.StatusBadRequest, Reason: "InvalidArgument", Message: "Argument verification failed."}
+	ErrInvalidArgument = Register(Definition{
+		Code:    http.StatusBadRequest,
+		Reason:  "InvalidArgument",
+		Message: "Argument verification failed.",
+		Doc:     "The request parameters failed validation, see the BadRequest detail for the invalid fields.",
+	})
 
 	// ErrUnauthenticated 表示认证失败.
-	ErrUnauthenticated = &ErrorX{Code: http.StatusUnauthorized, Reason: "Unauthenticated", Message: "Unauthenticated."}
+	ErrUnauthenticated = Register(Definition{
+		Code:    http.StatusUnauthorized,
+		Reason:  "Unauthenticated",
+		Message: "Unauthenticated.",
+		Doc:     "The request does not carry valid credentials.",
+	})
 
 	// ErrPermissionDenied 表示请求没有权限.
-	ErrPermissionDenied = &ErrorX{Code: http.StatusForbidden, Reason: "PermissionDenied", Message: "Permission denied. Access to the requested resource is forbidden."}
+	ErrPermissionDenied = Register(Definition{
+		Code:    http.StatusForbidden,
+		Reason:  "PermissionDenied",
+		Message: "Permission denied. Access to the requested resource is forbidden.",
+		Doc:     "The caller is authenticated but not allowed to access the resource.",
+	})
 
 	// ErrOperationFailed 表示操作失败.
-	ErrOperationFailed = &ErrorX{Code: http.StatusConflict, Reason: "OperationFailed", Message: "The requested operation has failed. Please try again later."}
+	ErrOperationFailed = Register(Definition{
+		Code:    http.StatusConflict,
+		Reason:  "OperationFailed",
+		Message: "The requested operation has failed. Please try again later.",
+		Doc:     "The operation conflicts with the current state of the resource.",
+	})
 )
+
+func init() {
+	// 预定义错误的中文翻译，英文使用声明中的 Message
+	if err := AddMessages("zh-CN", map[string]string{
+		ErrInternal.Reason:         "服务器内部错误.",
+		ErrNotFound.Reason:         "资源不存在.",
+		ErrBind.Reason:             "请求参数解析失败.",
+		ErrInvalidArgument.Reason:  "参数校验失败.",
+		ErrUnauthenticated.Reason:  "未认证.",
+		ErrPermissionDenied.Reason: "没有权限访问该资源.",
+		ErrOperationFailed.Reason:  "操作失败，请稍后重试.",
+	}); err != nil {
+		panic(err)
+	}
+}
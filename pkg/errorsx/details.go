@@ -0,0 +1,98 @@
+package errorsx
+
+import (
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// WithDetails 返回追加了错误详情的副本，err 本身不会被修改.
+// 错误详情会作为 gRPC 状态的 details 返回，并在 HTTP 响应中渲染.
+// 通常使用 errdetails 中定义的类型，例如 BadRequest、RetryInfo、QuotaFailure、
+// LocalizedMessage 和 DebugInfo. ErrorInfo 由 Reason 和 Metadata 表示，不要通过该方法添加.
+// 错误详情被追加后不应再被修改.
+func (err *ErrorX) WithDetails(details ...proto.Message) *ErrorX {
+	cp := err.clone()
+	cp.Details = append(cp.Details, details...)
+	return cp
+}
+
+// WithFieldViolation 返回在 BadRequest 详情中追加了字段错误的副本，err 本身不会被修改.
+func (err *ErrorX) WithFieldViolation(field, description string) *ErrorX {
+	violation := &errdetails.BadRequest_FieldViolation{Field: field, Description: description}
+
+	br := &errdetails.BadRequest{}
+	if prev, ok := findDetail[*errdetails.BadRequest](err.Details); ok {
+		br.FieldViolations = append(br.FieldViolations, prev.FieldViolations...)
+	}
+	br.FieldViolations = append(br.FieldViolations, violation)
+	return err.replaceDetail(br)
+}
+
+// WithQuotaViolation 返回在 QuotaFailure 详情中追加了配额错误的副本，err 本身不会被修改.
+func (err *ErrorX) WithQuotaViolation(subject, description string) *ErrorX {
+	violation := &errdetails.QuotaFailure_Violation{Subject: subject, Description: description}
+
+	qf := &errdetails.QuotaFailure{}
+	if prev, ok := findDetail[*errdetails.QuotaFailure](err.Details); ok {
+		qf.Violations = append(qf.Violations, prev.Violations...)
+	}
+	qf.Violations = append(qf.Violations, violation)
+	return err.replaceDetail(qf)
+}
+
+// WithRetryDelay 返回设置了 RetryInfo 详情的副本，告知客户端在 delay 之后重试.
+func (err *ErrorX) WithRetryDelay(delay time.Duration) *ErrorX {
+	return err.replaceDetail(&errdetails.RetryInfo{RetryDelay: durationpb.New(delay)})
+}
+
+// WithLocalizedMessage 返回设置了 LocalizedMessage 详情的副本，locale 使用 BCP 47 格式，例如 zh-CN.
+func (err *ErrorX) WithLocalizedMessage(locale, message string) *ErrorX {
+	return err.replaceDetail(&errdetails.LocalizedMessage{Locale: locale, Message: message})
+}
+
+// WithDebugInfo 返回设置了 DebugInfo 详情的副本.
+// DebugInfo 会原样返回给客户端，不要在其中放入敏感信息.
+func (err *ErrorX) WithDebugInfo(detail string, stackEntries ...string) *ErrorX {
+	return err.replaceDetail(&errdetails.DebugInfo{Detail: detail, StackEntries: stackEntries})
+}
+
+// replaceDetail 返回使用 detail 替换同类型详情的副本，不存在同类型详情时追加.
+func (err *ErrorX) replaceDetail(detail proto.Message) *ErrorX {
+	cp := err.clone()
+	name := detail.ProtoReflect().Descriptor().FullName()
+	for i, d := range cp.Details {
+		if d.ProtoReflect().Descriptor().FullName() == name {
+			cp.Details[i] = detail
+			return cp
+		}
+	}
+	cp.Details = append(cp.Details, detail)
+	return cp
+}
+
+// Detail 返回 err 中第一个类型为 T 的错误详情，例如:
+//
+//	if info, ok := errorsx.Detail[*errdetails.RetryInfo](err); ok {
+//		time.Sleep(info.GetRetryDelay().AsDuration())
+//	}
+func Detail[T proto.Message](err error) (T, bool) {
+	if errx := FromError(err); errx != nil {
+		return findDetail[T](errx.Details)
+	}
+	var zero T
+	return zero, false
+}
+
+// findDetail 返回 details 中第一个类型为 T 的错误详情.
+func findDetail[T proto.Message](details []proto.Message) (T, bool) {
+	for _, d := range details {
+		if typed, ok := d.(T); ok {
+			return typed, true
+		}
+	}
+	var zero T
+	return zero, false
+}
@@ -3,11 +3,16 @@ package errorsx
 import (
 	"errors"
 	"fmt"
+	"maps"
 	"net/http"
+	"slices"
 
 	httpstatus "github.com/go-kratos/kratos/v2/transport/http/status"
 	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/protoadapt"
 )
 
 // ErrorX 定义了 OneX 项目体系中使用的错误类型，用于描述错误的详细信息.
@@ -23,6 +28,26 @@ type ErrorX struct {
 
 	// Metadata 用于存储与该错误相关的额外元信息，可以包含上下文或调试信息.
 	Metadata map[string]string `json:"metadata,omitempty"`
+
+	// Details 是除 ErrorInfo 以外的错误详情，例如 errdetails.BadRequest 和 errdetails.RetryInfo.
+	// 它们会与 gRPC 状态的 details 相互转换，并在 HTTP 响应中渲染.
+	Details []proto.Message `json:"-"`
+
+	// grpcCode 是错误的 gRPC 状态码，为 codes.OK 时根据 Code 推导.
+	grpcCode codes.Code
+
+	// localizable 表示 Message 来自 Registry 中声明的模板，可以按 Reason 本地化.
+	// 使用 WithMessage 设置的 Message 描述了具体的错误，不会被本地化.
+	localizable bool
+
+	// args 是 WithArgs 传入的模板参数，本地化时用于格式化翻译后的模板.
+	args []any
+
+	// cause 是被包装的底层错误，只用于日志和 errors.Is/As，不会返回给客户端.
+	cause error
+
+	// stack 是创建错误时的调用栈，仅在 EnableStackTrace(true) 后记录.
+	stack []uintptr
 }
 
 // New 创建一个新的错误.
@@ -31,51 +56,131 @@ func New(code int, reason string, format string, args ...any) *ErrorX {
 		Code:    code,
 		Reason:  reason,
 		Message: fmt.Sprintf(format, args...),
+		stack:   callers(),
+	}
+}
+
+// Wrap 创建一个新的错误，并将 cause 作为其底层错误.
+// cause 可以通过 Unwrap、Is 和 As 访问，例如 errors.Is(err, gorm.ErrRecordNotFound).
+// 即使 cause 为 nil 也会返回非 nil 的错误.
+func Wrap(cause error, code int, reason string, format string, args ...any) *ErrorX {
+	return &ErrorX{
+		Code:    code,
+		Reason:  reason,
+		Message: fmt.Sprintf(format, args...),
+		cause:   cause,
+		stack:   callers(),
 	}
 }
 
 // Error 实现 error 接口中的 `Error` 方法.
+// 如果存在底层错误，会附加在末尾.
 func (err *ErrorX) Error() string {
-	return fmt.Sprintf("error: code = %d reason = %s message = %s metadata = %v", err.Code, err.Reason, err.Message, err.Metadata)
+	msg := fmt.Sprintf("error: code = %d reason = %s message = %s metadata = %v", err.Code, err.Reason, err.Message, err.Metadata)
+	if err.cause != nil {
+		msg += ": cause = " + err.cause.Error()
+	}
+	return msg
 }
 
-// WithMessage 设置错误的 Message 字段.  
+// Unwrap 返回被包装的底层错误，没有时返回 nil.
+func (err *ErrorX) Unwrap() error {
+	return err.cause
+}
+
+// WithArgs 返回使用 args 格式化 Message 模板后的副本，err 本身不会被修改.
+// 通常用于 Registry 中声明的错误，例如 ErrUserNotFound.WithArgs("alice").
+// 本地化时 args 会用于格式化翻译后的模板.
+func (err *ErrorX) WithArgs(args ...any) *ErrorX {
+	cp := err.clone()
+	cp.Message = fmt.Sprintf(err.Message, args...)
+	cp.args = slices.Clone(args)
+	return cp
+}
+
+// GRPCCode 返回错误的 gRPC 状态码.
+// 如果错误在声明时没有指定 gRPC 状态码，则根据 HTTP 状态码推导.
+func (err *ErrorX) GRPCCode() codes.Code {
+	if err.grpcCode != codes.OK {
+		return err.grpcCode
+	}
+	return httpstatus.ToGRPCCode(err.Code)
+}
+
+// WithCause 返回以 cause 为底层错误的副本，err 本身不会被修改.
+// 通常用于基于预定义错误包装底层错误，例如 ErrInternal.WithCause(err).
+func (err *ErrorX) WithCause(cause error) *ErrorX {
+	cp := err.clone()
+	cp.cause = cause
+	return cp
+}
+
+// WithMessage 返回设置了 Message 字段的错误副本，err 本身不会被修改.
+// 预定义的错误因此可以作为模板被并发使用.
 func (err *ErrorX) WithMessage(format string, args ...any) *ErrorX {
-	err.Message = fmt.Sprintf(format, args...)
-	return err
+	cp := err.clone()
+	cp.Message = fmt.Sprintf(format, args...)
+	cp.localizable = false
+	cp.args = nil
+	return cp
 }
 
-// WithMetadata 设置元数据.
+// WithMetadata 返回设置了元数据的错误副本，md 会被复制，err 本身不会被修改.
 func (err *ErrorX) WithMetadata(md map[string]string) *ErrorX {
-	err.Metadata = md
-	return err
+	cp := err.clone()
+	cp.Metadata = maps.Clone(md)
+	return cp
 }
 
-// KV 使用 key-value 对设置元数据.
+// KV 返回使用 key-value 对追加了元数据的错误副本，err 本身不会被修改.
 func (err *ErrorX) KV(kvs ...string) *ErrorX {
-	if err.Metadata == nil {
-		err.Metadata = make(map[string]string) // 初始化元数据映射
+	cp := err.clone()
+	if cp.Metadata == nil {
+		cp.Metadata = make(map[string]string) // 初始化元数据映射
 	}
 
 	for i := 0; i < len(kvs); i += 2 {
 		// kvs 必须是成对的
 		if i+1 < len(kvs) {
-			err.Metadata[kvs[i]] = kvs[i+1]
+			cp.Metadata[kvs[i]] = kvs[i+1]
 		}
 	}
-	return err
+	return cp
+}
+
+// clone 返回 err 的深拷贝.
+// 如果 err 没有调用栈（例如预定义的错误模板），会为副本记录调用栈.
+func (err *ErrorX) clone() *ErrorX {
+	cp := *err
+	cp.Metadata = maps.Clone(err.Metadata)
+	cp.Details = slices.Clone(err.Details)
+	if cp.stack == nil {
+		cp.stack = callers()
+	}
+	return &cp
 }
 
 // GRPCStatus 返回 gRPC 状态表示.
+// Reason 和 Metadata 作为第一个 details 中的 errdetails.ErrorInfo 返回，随后是 Details.
 func (err *ErrorX) GRPCStatus() *status.Status {
-	details := errdetails.ErrorInfo{Reason: err.Reason, Metadata: err.Metadata}
-	s, _ := status.New(httpstatus.ToGRPCCode(err.Code), err.Message).WithDetails(&details)
+	details := make([]protoadapt.MessageV1, 0, len(err.Details)+1)
+	details = append(details, &errdetails.ErrorInfo{Reason: err.Reason, Metadata: err.Metadata})
+	for _, d := range err.Details {
+		details = append(details, protoadapt.MessageV1Of(d))
+	}
+
+	s := status.New(err.GRPCCode(), err.Message)
+	if withDetails, detailErr := s.WithDetails(details...); detailErr == nil {
+		return withDetails
+	}
+	// 无法序列化的 Details 会被丢弃，但依然保留 ErrorInfo
+	s, _ = s.WithDetails(details[0])
 	return s
 }
 
-// WithRequestID 设置请求 ID.  
+// WithRequestID 返回设置了请求 ID 元数据的错误副本，err 本身不会被修改.
 func (err *ErrorX) WithRequestID(requestID string) *ErrorX {
-	return err.KV("X-Request-ID", requestID) // 设置请求 ID  
+	return err.KV("X-Request-ID", requestID) // 设置请求 ID
 }
 
 // Is 判断当前错误是否与目标错误匹配.
@@ -119,21 +224,31 @@ func FromError(err error) *ErrorX {
 
 	// gRPC 的 status.FromError 方法尝试将 error 转换为 gRPC 错误的 status 对象.
 	// 如果 err 不能转换为 gRPC 错误（即不是 gRPC 的 status 错误），
-	// 则返回一个带有默认值的 ErrorX，表示是一个未知类型的错误.
+	// 则返回以 err 为底层错误的 ErrInternal，表示是一个未知类型的错误.
+	// err 的内容可能包含内部信息，只通过 Unwrap 和日志访问，不会返回给客户端.
 	gs, ok := status.FromError(err)
 	if !ok {
-		return New(ErrInternal.Code, ErrInternal.Reason, err.Error())
+		return ErrInternal.WithCause(err)
 	}
 
 	// 如果 err 是 gRPC 的错误类型，会成功返回一个 gRPC status 对象（gs）.
 	// 使用 gRPC 状态中的错误代码和消息创建一个 ErrorX.
-	ret := New(httpstatus.FromGRPCCode(gs.Code()), ErrInternal.Reason, gs.Message())
+	ret := New(httpstatus.FromGRPCCode(gs.Code()), ErrInternal.Reason, "%s", gs.Message())
+	ret.grpcCode = gs.Code()
 
 	// 遍历 gRPC 错误详情中的所有附加信息（Details）.
+	// ErrorInfo 转换为 Reason 和 Metadata，其余可以解析的详情保存在 Details 中.
 	for _, detail := range gs.Details() {
-		if typed, ok := detail.(*errdetails.ErrorInfo); ok {
+		switch typed := detail.(type) {
+		case *errdetails.ErrorInfo:
 			ret.Reason = typed.Reason
-			return ret.WithMetadata(typed.Metadata)
+			ret.Metadata = maps.Clone(typed.Metadata)
+			// 已声明的错误使用声明中的 HTTP 状态码
+			if def, ok := DefaultRegistry.Lookup(typed.Reason); ok {
+				ret.Code = def.Code
+			}
+		case proto.Message:
+			ret.Details = append(ret.Details, typed)
 		}
 	}
 
@@ -1,8 +1,15 @@
 package errorsx
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"google.golang.org/genproto/googleapis/rpc/errdetails"
@@ -28,12 +35,15 @@ func TestErrorX_WithMessage(t *testing.T) {
 	errx := New(400, "BadRequest.InvalidInput", "Invalid input for field %s", "username")
 
 	// 更新错误的消息
-	errx.WithMessage("New error message: %s", "retry failed")
+	updated := errx.WithMessage("New error message: %s", "retry failed")
 
 	// 验证变更
-	assert.Equal(t, "New error message: retry failed", errx.Message)
-	assert.Equal(t, 400, errx.Code)                         // Code 不变
-	assert.Equal(t, "BadRequest.InvalidInput", errx.Reason) // Reason 不变
+	assert.Equal(t, "New error message: retry failed", updated.Message)
+	assert.Equal(t, 400, updated.Code)                         // Code 不变
+	assert.Equal(t, "BadRequest.InvalidInput", updated.Reason) // Reason 不变
+
+	// 原错误不变
+	assert.Equal(t, "Invalid input for field username", errx.Message)
 }
 
 func TestErrorX_WithMetadata(t *testing.T) {
@@ -41,19 +51,48 @@ func TestErrorX_WithMetadata(t *testing.T) {
 	errx := New(400, "BadRequest.InvalidInput", "Invalid input")
 
 	// 添加元数据
-	errx.WithMetadata(map[string]string{
+	md := map[string]string{
 		"field": "username",
 		"type":  "empty",
-	})
+	}
+	errx = errx.WithMetadata(md)
+	md["field"] = "changed" // 元数据被复制
 
 	// 验证元数据
 	assert.Equal(t, "username", errx.Metadata["field"])
 	assert.Equal(t, "empty", errx.Metadata["type"])
 
 	// 动态添加更多元数据
-	errx.KV("user_id", "12345", "trace_id", "xyz-789")
-	assert.Equal(t, "12345", errx.Metadata["user_id"])
-	assert.Equal(t, "xyz-789", errx.Metadata["trace_id"])
+	withKV := errx.KV("user_id", "12345", "trace_id", "xyz-789")
+	assert.Equal(t, "12345", withKV.Metadata["user_id"])
+	assert.Equal(t, "xyz-789", withKV.Metadata["trace_id"])
+	assert.Equal(t, "username", withKV.Metadata["field"])
+
+	// 原错误的元数据不变
+	assert.NotContains(t, errx.Metadata, "user_id")
+}
+
+func TestErrorX_TemplatesAreImmutable(t *testing.T) {
+	// 并发地从预定义错误派生新错误，使用 -race 运行时可以检测到共享状态的修改
+	var wg sync.WaitGroup
+	for i := range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			id := strconv.Itoa(i)
+			errx := ErrBind.WithMessage("bad field %s", id).KV("field", id).WithRequestID(id)
+			errx = errx.WithMetadata(errx.Metadata).KV("extra", id)
+
+			assert.Equal(t, "bad field "+id, errx.Message)
+			assert.Equal(t, map[string]string{"field": id, "X-Request-ID": id, "extra": id}, errx.Metadata)
+			assert.True(t, errx.Is(ErrBind))
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, "Error occurred while binding the request body to the struct.", ErrBind.Message)
+	assert.Nil(t, ErrBind.Metadata)
 }
 
 func TestErrorX_Is(t *testing.T) {
@@ -67,6 +106,82 @@ func TestErrorX_Is(t *testing.T) {
 	assert.False(t, err1.Is(err3)) // Reason 不同
 }
 
+func TestErrorX_WrapAndCause(t *testing.T) {
+	dbErr := errors.New("connection refused")
+
+	// 使用 Wrap 和 WithCause 包装底层错误
+	wrapped := Wrap(dbErr, 500, "InternalError.DB", "Query failed")
+	fromTemplate := ErrInternal.WithCause(fmt.Errorf("query user: %w", dbErr))
+
+	for _, err := range []*ErrorX{wrapped, fromTemplate} {
+		// 底层错误可以通过 Is/As/Unwrap 访问
+		assert.True(t, Is(err, dbErr))
+		assert.NotNil(t, Unwrap(err))
+		assert.Contains(t, err.Error(), "cause = ")
+	}
+	assert.Equal(t, dbErr, Unwrap(wrapped))
+	assert.True(t, Is(fromTemplate, ErrInternal)) // 仍然匹配 Code 和 Reason
+	assert.Nil(t, Unwrap(ErrInternal))            // 模板不会被修改
+
+	// 被 fmt.Errorf 再次包装后依然可以找到 ErrorX
+	var errx *ErrorX
+	assert.True(t, As(fmt.Errorf("handler: %w", wrapped), &errx))
+	assert.Equal(t, "InternalError.DB", errx.Reason)
+
+	// 普通错误转换后保留为底层错误
+	assert.True(t, Is(FromError(dbErr), dbErr))
+
+	assert.Equal(t, []string{"InternalError: Internal server error.", "query user: connection refused", "connection refused"},
+		Chain(fromTemplate))
+}
+
+func TestErrorX_StackTrace(t *testing.T) {
+	assert.Nil(t, New(500, "InternalError", "no stack").StackTrace())
+
+	EnableStackTrace(true)
+	defer EnableStackTrace(false)
+
+	for _, err := range []*ErrorX{
+		New(500, "InternalError", "with stack"),
+		Wrap(errors.New("boom"), 500, "InternalError", "with stack"),
+		ErrInternal.WithCause(errors.New("boom")),
+		ErrNotFound.KV("id", "1"),
+	} {
+		frames := err.StackTrace()
+		if assert.NotEmpty(t, frames) {
+			// 第一帧是创建错误的调用方，不包含本包内部的帧
+			assert.Equal(t, "chunyu/pkg/errorsx.TestErrorX_StackTrace", frames[0].Function)
+		}
+		assert.Contains(t, fmt.Sprintf("%+v", err), "TestErrorX_StackTrace")
+	}
+	assert.Nil(t, ErrInternal.StackTrace())
+}
+
+func TestErrorX_LogValue(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	err := Wrap(errors.New("dial tcp: i/o timeout"), 500, "InternalError.Redis", "Cache unavailable")
+	logger.Error("Request failed", "err", err)
+
+	var out struct {
+		Err struct {
+			Reason  string   `json:"reason"`
+			Message string   `json:"message"`
+			Causes  []string `json:"causes"`
+		} `json:"err"`
+	}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &out))
+	assert.Equal(t, "InternalError.Redis", out.Err.Reason)
+	assert.Equal(t, "Cache unavailable", out.Err.Message)
+	assert.Equal(t, []string{"dial tcp: i/o timeout"}, out.Err.Causes)
+
+	// 底层错误不会出现在 JSON 编码的错误中
+	data, jsonErr := json.Marshal(err)
+	assert.NoError(t, jsonErr)
+	assert.NotContains(t, string(data), "i/o timeout")
+}
+
 func TestErrorX_FromError_WithPlainError(t *testing.T) {
 	// 创建一个普通的 Go 错误
 	plainErr := errors.New("Something went wrong")
@@ -75,9 +190,11 @@ func TestErrorX_FromError_WithPlainError(t *testing.T) {
 	errx := FromError(plainErr)
 
 	// 检查转换后的 ErrorX
-	assert.Equal(t, ErrInternal.Code, errx.Code)          // 默认 500
-	assert.Equal(t, ErrInternal.Reason, errx.Reason)      // 默认 ""
-	assert.Equal(t, "Something went wrong", errx.Message) // 转换时保留原始错误消息
+	assert.Equal(t, ErrInternal.Code, errx.Code)       // 默认 500
+	assert.Equal(t, ErrInternal.Reason, errx.Reason)   // 默认 ""
+	assert.Equal(t, ErrInternal.Message, errx.Message) // 原始错误消息不会返回给客户端
+	assert.ErrorIs(t, errx, plainErr)                  // 原始错误作为底层错误保留
+	assert.Contains(t, errx.Error(), "Something went wrong")
 }
 
 func TestErrorX_FromError_WithGRPCError(t *testing.T) {
@@ -116,3 +233,47 @@ func TestErrorX_FromError_WithGRPCErrorDetails(t *testing.T) {
 	assert.Equal(t, "name", errx.Metadata["field"])
 	assert.Equal(t, "required", errx.Metadata["type"])
 }
+
+func TestErrorX_Details_GRPCRoundTrip(t *testing.T) {
+	errx := ErrInvalidArgument.
+		WithFieldViolation("name", "must not be empty").
+		WithFieldViolation("age", "must be positive").
+		WithRetryDelay(3*time.Second).
+		WithQuotaViolation("user:1", "daily limit exceeded").
+		WithLocalizedMessage("zh-CN", "参数错误").
+		WithDebugInfo("validation failed", "main.go:10")
+
+	// 同类型的详情会被合并或替换
+	assert.Len(t, errx.Details, 5)
+	assert.Empty(t, ErrInvalidArgument.Details) // 模板不会被修改
+
+	// 转换为 gRPC 状态后再转换回来
+	got := FromError(errx.GRPCStatus().Err())
+	assert.Equal(t, errx.Reason, got.Reason)
+	assert.Len(t, got.Details, 5)
+
+	br, ok := Detail[*errdetails.BadRequest](got)
+	assert.True(t, ok)
+	assert.Len(t, br.GetFieldViolations(), 2)
+	assert.Equal(t, "age", br.GetFieldViolations()[1].GetField())
+
+	retry, ok := Detail[*errdetails.RetryInfo](got)
+	assert.True(t, ok)
+	assert.Equal(t, 3*time.Second, retry.GetRetryDelay().AsDuration())
+
+	quota, ok := Detail[*errdetails.QuotaFailure](got)
+	assert.True(t, ok)
+	assert.Equal(t, "user:1", quota.GetViolations()[0].GetSubject())
+
+	msg, ok := Detail[*errdetails.LocalizedMessage](got)
+	assert.True(t, ok)
+	assert.Equal(t, "参数错误", msg.GetMessage())
+
+	debug, ok := Detail[*errdetails.DebugInfo](got)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"main.go:10"}, debug.GetStackEntries())
+
+	// 没有该类型的详情
+	_, ok = Detail[*errdetails.ResourceInfo](got)
+	assert.False(t, ok)
+}
@@ -0,0 +1,173 @@
+package errorsx
+
+import (
+	"fmt"
+	"io/fs"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+
+	"golang.org/x/text/language"
+	"gopkg.in/yaml.v3"
+)
+
+// Localizer 保存按 Reason 索引的多语言错误信息模板，并根据客户端的语言偏好本地化错误.
+//
+// 错误原本的 Message 视为回退语言的翻译，查找翻译的顺序为：
+//  1. 按优先级依次匹配客户端接受的语言，使用第一个包含该 Reason 翻译的语言，
+//     匹配到回退语言时即使没有翻译也不再继续查找，使用错误原本的 Message；
+//  2. 回退语言中该 Reason 的翻译；
+//  3. 错误原本的 Message.
+type Localizer struct {
+	mu       sync.RWMutex
+	fallback language.Tag
+	// tags 是支持的语言，第一个为回退语言.
+	tags     []language.Tag
+	matcher  language.Matcher
+	catalogs map[language.Tag]map[string]string
+}
+
+// DefaultLocalizer 是默认的 Localizer，回退语言为英语.
+// errorsx 预定义错误的中文翻译注册在其中.
+var DefaultLocalizer = NewLocalizer("en")
+
+// NewLocalizer 创建一个 Localizer，fallback 是没有匹配的语言时使用的回退语言，例如 en.
+func NewLocalizer(fallback string) *Localizer {
+	tag := language.Make(fallback)
+	return &Localizer{
+		fallback: tag,
+		tags:     []language.Tag{tag},
+		matcher:  language.NewMatcher([]language.Tag{tag}),
+		catalogs: make(map[language.Tag]map[string]string),
+	}
+}
+
+// AddMessages 添加 locale 语言的错误信息模板，messages 的 key 为 Reason.
+// 模板可以包含 fmt 的格式化动词，使用 ErrorX.WithArgs 传入的参数格式化.
+// 已存在的翻译会被覆盖.
+func (l *Localizer) AddMessages(locale string, messages map[string]string) error {
+	tag, err := language.Parse(locale)
+	if err != nil {
+		return fmt.Errorf("errorsx: invalid locale %q: %w", locale, err)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	catalog, ok := l.catalogs[tag]
+	if !ok {
+		catalog = make(map[string]string, len(messages))
+		l.catalogs[tag] = catalog
+	}
+	maps.Copy(catalog, messages)
+
+	if !slices.Contains(l.tags, tag) {
+		l.tags = append(l.tags, tag)
+		l.matcher = language.NewMatcher(l.tags)
+	}
+	return nil
+}
+
+// LoadMessages 读取 dir 目录中以语言命名的 JSON 或 YAML 文件，例如 zh-CN.yaml，
+// 并添加其中的错误信息模板. 文件的内容是 Reason 到模板的映射.
+func (l *Localizer) LoadMessages(fsys fs.FS, dir string) error {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		ext := path.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".json" && ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+
+		data, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return err
+		}
+
+		// JSON 是 YAML 的子集，因此都使用 YAML 解析
+		var messages map[string]string
+		if err := yaml.Unmarshal(data, &messages); err != nil {
+			return fmt.Errorf("errorsx: failed to parse %s: %w", entry.Name(), err)
+		}
+		if err := l.AddMessages(strings.TrimSuffix(entry.Name(), ext), messages); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Localize 返回按 acceptLanguages 本地化了 Message 的错误副本，以及使用的语言.
+// acceptLanguages 的格式与 Accept-Language 头相同，例如 zh-CN,zh;q=0.9,en;q=0.8.
+// 只有 Registry 中声明的错误会被本地化，使用 WithMessage 设置了 Message 的错误
+// 以及没有找到翻译的错误会原样返回，此时语言为空字符串. 客户端选择回退语言时，
+// 错误同样原样返回，语言为回退语言.
+func (l *Localizer) Localize(err *ErrorX, acceptLanguages ...string) (*ErrorX, string) {
+	if err == nil || !err.localizable {
+		return err, ""
+	}
+
+	template, tag, ok := l.lookup(err.Reason, acceptLanguages)
+	if !ok {
+		return err, ""
+	}
+	if template == "" {
+		// 客户端选择了回退语言，错误原本的 Message 即为该语言
+		return err, tag.String()
+	}
+
+	cp := err.clone()
+	cp.Message = template
+	if len(err.args) > 0 {
+		cp.Message = fmt.Sprintf(template, err.args...)
+	}
+	return cp, tag.String()
+}
+
+// lookup 返回 reason 在 acceptLanguages 中第一个有翻译的语言下的模板，没有时使用回退语言.
+// 匹配到回退语言而其中没有 reason 的翻译时，返回空模板，表示使用错误原本的 Message.
+func (l *Localizer) lookup(reason string, acceptLanguages []string) (string, language.Tag, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	for _, desired := range parseAcceptLanguages(acceptLanguages) {
+		_, index, confidence := l.matcher.Match(desired)
+		if confidence == language.No {
+			continue
+		}
+		tag := l.tags[index]
+		if template, ok := l.catalogs[tag][reason]; ok || tag == l.fallback {
+			return template, tag, true
+		}
+	}
+
+	template, ok := l.catalogs[l.fallback][reason]
+	return template, l.fallback, ok
+}
+
+// parseAcceptLanguages 按优先级返回 acceptLanguages 中的语言，无法解析的值会被忽略.
+func parseAcceptLanguages(acceptLanguages []string) []language.Tag {
+	var tags []language.Tag
+	for _, value := range acceptLanguages {
+		parsed, _, err := language.ParseAcceptLanguage(value)
+		if err != nil {
+			continue
+		}
+		tags = append(tags, parsed...)
+	}
+	return tags
+}
+
+// AddMessages 在 DefaultLocalizer 中添加 locale 语言的错误信息模板.
+func AddMessages(locale string, messages map[string]string) error {
+	return DefaultLocalizer.AddMessages(locale, messages)
+}
+
+// Localize 使用 DefaultLocalizer 本地化 err.
+func Localize(err *ErrorX, acceptLanguages ...string) (*ErrorX, string) {
+	return DefaultLocalizer.Localize(err, acceptLanguages...)
+}
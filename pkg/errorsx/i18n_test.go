@@ -0,0 +1,86 @@
+package errorsx
+
+import (
+	"net/http"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalizer_Localize(t *testing.T) {
+	reg := NewRegistry()
+	errUser := reg.MustRegister(Definition{Code: http.StatusNotFound, Reason: "NotFound.User", Message: "User %s not found."})
+	errQuota := reg.MustRegister(Definition{Code: http.StatusTooManyRequests, Reason: "Quota.Exceeded", Message: "Too many requests."})
+
+	l := NewLocalizer("en")
+	require.NoError(t, l.AddMessages("zh-CN", map[string]string{"NotFound.User": "用户 %s 不存在."}))
+	require.NoError(t, l.AddMessages("en", map[string]string{"Quota.Exceeded": "Quota exceeded."}))
+	assert.Error(t, l.AddMessages("not a locale!", nil))
+
+	tests := []struct {
+		name           string
+		err            *ErrorX
+		acceptLanguage []string
+		wantMessage    string
+		wantLocale     string
+	}{
+		{"exact match with args", errUser.WithArgs("alice"), []string{"zh-CN"}, "用户 alice 不存在.", "zh-CN"},
+		{"accept-language header", errUser.WithArgs("alice"), []string{"fr;q=0.5, zh;q=0.9"}, "用户 alice 不存在.", "zh-CN"},
+		{"fallback locale uses message", errUser.WithArgs("alice"), []string{"en-US"}, "User alice not found.", "en"},
+		{"preferred fallback locale", errUser.WithArgs("alice"), []string{"en-US,zh-CN;q=0.5"}, "User alice not found.", "en"},
+		{"unsupported preferred locale", errUser.WithArgs("alice"), []string{"fr,zh;q=0.1"}, "用户 alice 不存在.", "zh-CN"},
+		{"no matching locale falls back to message", errUser.WithArgs("alice"), []string{"fr"}, "User alice not found.", ""},
+		{"no translation in requested locale", errQuota, []string{"zh-CN"}, "Quota exceeded.", "en"},
+		{"no accept-language", errQuota, nil, "Quota exceeded.", "en"},
+		{"invalid accept-language", errQuota, []string{";;"}, "Quota exceeded.", "en"},
+		{"custom message is kept", errUser.WithMessage("user bob is disabled"), []string{"zh-CN"}, "user bob is disabled", ""},
+		{"unregistered error", New(http.StatusNotFound, "NotFound.User", "not found"), []string{"zh-CN"}, "not found", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, locale := l.Localize(tt.err, tt.acceptLanguage...)
+			assert.Equal(t, tt.wantMessage, got.Message)
+			assert.Equal(t, tt.wantLocale, locale)
+		})
+	}
+
+	// 本地化返回的是副本
+	localized, _ := l.Localize(errUser, "zh-CN")
+	assert.Equal(t, "User %s not found.", errUser.Message)
+	assert.True(t, Is(localized, errUser))
+}
+
+func TestLocalizer_LoadMessages(t *testing.T) {
+	fsys := fstest.MapFS{
+		"i18n/zh-CN.yaml": {Data: []byte("NotFound: 找不到资源.\n")},
+		"i18n/ja.json":    {Data: []byte(`{"NotFound": "リソースが見つかりません."}`)},
+		"i18n/README.md":  {Data: []byte("ignored")},
+	}
+
+	l := NewLocalizer("en")
+	require.NoError(t, l.LoadMessages(fsys, "i18n"))
+
+	got, locale := l.Localize(ErrNotFound, "ja-JP")
+	assert.Equal(t, "リソースが見つかりません.", got.Message)
+	assert.Equal(t, "ja", locale)
+
+	got, _ = l.Localize(ErrNotFound, "zh-CN")
+	assert.Equal(t, "找不到资源.", got.Message)
+
+	assert.Error(t, l.LoadMessages(fstest.MapFS{"i18n/en.yaml": {Data: []byte("- not a map")}}, "i18n"))
+}
+
+func TestDefaultLocalizer(t *testing.T) {
+	for _, errx := range []*ErrorX{ErrInternal, ErrNotFound, ErrBind, ErrInvalidArgument, ErrUnauthenticated, ErrPermissionDenied, ErrOperationFailed} {
+		got, locale := Localize(errx, "zh-CN")
+		assert.Equal(t, "zh-CN", locale, errx.Reason)
+		assert.NotEqual(t, errx.Message, got.Message)
+	}
+
+	// 优先使用英语的客户端得到错误原本的 Message
+	got, locale := Localize(ErrNotFound, "en-US,zh-CN;q=0.5")
+	assert.Equal(t, "en", locale)
+	assert.Equal(t, ErrNotFound.Message, got.Message)
+}
@@ -0,0 +1,118 @@
+package errorsx
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	httpstatus "github.com/go-kratos/kratos/v2/transport/http/status"
+	"google.golang.org/grpc/codes"
+)
+
+// Definition 描述一个在 Registry 中声明的错误.
+type Definition struct {
+	// Code 是错误的 HTTP 状态码，必须是 4xx 或 5xx.
+	Code int
+
+	// GRPCCode 是错误的 gRPC 状态码，为 codes.OK 时根据 Code 推导.
+	GRPCCode codes.Code
+
+	// Reason 是错误的业务错误码，在 Registry 中唯一，例如 NotFound.User.
+	Reason string
+
+	// Message 是错误信息的模板，可以包含 fmt 的格式化动词，使用 ErrorX.WithArgs 填充.
+	Message string
+
+	// Doc 是错误的说明，只用于生成错误码目录，不会返回给客户端.
+	Doc string
+}
+
+// grpcCode 返回错误的 gRPC 状态码.
+func (def Definition) grpcCode() codes.Code {
+	if def.GRPCCode != codes.OK {
+		return def.GRPCCode
+	}
+	return httpstatus.ToGRPCCode(def.Code)
+}
+
+// Registry 保存服务声明的错误，用于检测重复的 Reason 和生成错误码目录.
+type Registry struct {
+	mu   sync.RWMutex
+	defs map[string]Definition
+}
+
+// DefaultRegistry 是默认的错误注册表，errorsx 预定义的错误也注册在其中.
+var DefaultRegistry = NewRegistry()
+
+// NewRegistry 创建一个空的错误注册表.
+func NewRegistry() *Registry {
+	return &Registry{defs: make(map[string]Definition)}
+}
+
+// Register 注册 def 并返回对应的错误模板.
+// Reason 为空、已被注册或者 Code 不是 4xx/5xx 时返回错误.
+func (r *Registry) Register(def Definition) (*ErrorX, error) {
+	if def.Reason == "" {
+		return nil, fmt.Errorf("errorsx: empty reason for error %q", def.Message)
+	}
+	if def.Code < http.StatusBadRequest || http.StatusText(def.Code) == "" {
+		return nil, fmt.Errorf("errorsx: invalid HTTP code %d for reason %s", def.Code, def.Reason)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.defs[def.Reason]; ok {
+		return nil, fmt.Errorf("errorsx: duplicate reason %s", def.Reason)
+	}
+	r.defs[def.Reason] = def
+
+	return &ErrorX{Code: def.Code, Reason: def.Reason, Message: def.Message, grpcCode: def.GRPCCode, localizable: true}, nil
+}
+
+// MustRegister 与 Register 相同，但在出错时 panic，适合在包初始化时声明错误:
+//
+//	var ErrUserNotFound = errorsx.Register(errorsx.Definition{
+//		Code:    http.StatusNotFound,
+//		Reason:  "NotFound.User",
+//		Message: "User %s not found.",
+//		Doc:     "The requested user does not exist.",
+//	})
+func (r *Registry) MustRegister(def Definition) *ErrorX {
+	errx, err := r.Register(def)
+	if err != nil {
+		panic(err)
+	}
+	return errx
+}
+
+// Lookup 返回 reason 对应的错误声明.
+func (r *Registry) Lookup(reason string) (Definition, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	def, ok := r.defs[reason]
+	return def, ok
+}
+
+// Definitions 返回所有的错误声明，按 Reason 排序.
+func (r *Registry) Definitions() []Definition {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	defs := make([]Definition, 0, len(r.defs))
+	for _, def := range r.defs {
+		defs = append(defs, def)
+	}
+	slices.SortFunc(defs, func(a, b Definition) int {
+		return strings.Compare(a.Reason, b.Reason)
+	})
+	return defs
+}
+
+// Register 在 DefaultRegistry 中注册 def 并返回对应的错误模板，出错时 panic.
+func Register(def Definition) *ErrorX {
+	return DefaultRegistry.MustRegister(def)
+}
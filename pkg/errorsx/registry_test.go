@@ -0,0 +1,104 @@
+package errorsx
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+)
+
+func TestRegistry_Register(t *testing.T) {
+	reg := NewRegistry()
+
+	errUser, err := reg.Register(Definition{Code: http.StatusNotFound, Reason: "NotFound.User", Message: "User %s not found."})
+	require.NoError(t, err)
+	assert.Equal(t, "User alice not found.", errUser.WithArgs("alice").Message)
+	assert.Equal(t, "User %s not found.", errUser.Message) // 模板不会被修改
+	assert.Equal(t, codes.NotFound, errUser.GRPCCode())
+
+	// 指定的 gRPC 状态码优先于推导的状态码
+	errQuota := reg.MustRegister(Definition{Code: http.StatusTooManyRequests, GRPCCode: codes.ResourceExhausted, Reason: "Quota.Exceeded"})
+	assert.Equal(t, codes.ResourceExhausted, errQuota.GRPCStatus().Code())
+
+	// 重复的 Reason、空的 Reason 和非法的 HTTP 状态码都会被拒绝
+	_, err = reg.Register(Definition{Code: http.StatusBadRequest, Reason: "NotFound.User"})
+	assert.ErrorContains(t, err, "duplicate reason NotFound.User")
+	_, err = reg.Register(Definition{Code: http.StatusBadRequest})
+	assert.Error(t, err)
+	_, err = reg.Register(Definition{Code: http.StatusOK, Reason: "Success"})
+	assert.Error(t, err)
+	assert.Panics(t, func() { reg.MustRegister(Definition{Code: http.StatusNotFound, Reason: "Quota.Exceeded"}) })
+
+	def, ok := reg.Lookup("NotFound.User")
+	assert.True(t, ok)
+	assert.Equal(t, http.StatusNotFound, def.Code)
+
+	defs := reg.Definitions()
+	require.Len(t, defs, 2)
+	assert.Equal(t, "NotFound.User", defs[0].Reason)
+	assert.Equal(t, "Quota.Exceeded", defs[1].Reason)
+}
+
+func TestRegistry_Predefined(t *testing.T) {
+	for _, errx := range []*ErrorX{ErrInternal, ErrNotFound, ErrBind, ErrInvalidArgument, ErrUnauthenticated, ErrPermissionDenied, ErrOperationFailed} {
+		def, ok := DefaultRegistry.Lookup(errx.Reason)
+		assert.True(t, ok, errx.Reason)
+		assert.Equal(t, errx.Code, def.Code)
+		assert.NotEmpty(t, def.Doc)
+	}
+
+	// 已声明的错误通过 gRPC 传输后恢复声明中的 HTTP 状态码
+	assert.Equal(t, http.StatusConflict, FromError(ErrOperationFailed.GRPCStatus().Err()).Code)
+}
+
+func TestWriteCatalog(t *testing.T) {
+	defs := []Definition{
+		{Code: http.StatusNotFound, Reason: "NotFound.User", Message: "User %s not found.", Doc: "The user | account does not exist."},
+		{Code: http.StatusTooManyRequests, GRPCCode: codes.ResourceExhausted, Reason: "Quota/Exceeded", Message: "Too many requests."},
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, WriteCatalog(&out, defs, CatalogMarkdown))
+	assert.Contains(t, out.String(), "| `NotFound.User` | 404 | NotFound | User %s not found. | The user \\| account does not exist. |\n")
+	assert.Contains(t, out.String(), "| `Quota/Exceeded` | 429 | ResourceExhausted | Too many requests. |  |\n")
+
+	out.Reset()
+	require.NoError(t, WriteCatalog(&out, defs, CatalogJSON))
+	var entries []CatalogEntry
+	require.NoError(t, json.Unmarshal(out.Bytes(), &entries))
+	assert.Equal(t, CatalogEntry{Reason: "NotFound.User", Code: 404, GRPCCode: "NotFound", Message: "User %s not found.", Doc: "The user | account does not exist."}, entries[0])
+
+	out.Reset()
+	require.NoError(t, WriteCatalog(&out, defs, CatalogOpenAPI))
+	var doc struct {
+		Components struct {
+			Schemas   map[string]any `json:"schemas"`
+			Responses map[string]struct {
+				Description string `json:"description"`
+				Content     map[string]struct {
+					Schema  map[string]string `json:"schema"`
+					Example map[string]string `json:"example"`
+				} `json:"content"`
+			} `json:"responses"`
+		} `json:"components"`
+	}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &doc))
+	assert.Contains(t, doc.Components.Schemas, "ErrorResponse")
+	require.Contains(t, doc.Components.Responses, "Quota_Exceeded")
+	user := doc.Components.Responses["NotFound.User"]
+	assert.Equal(t, "The user | account does not exist.", user.Description)
+	assert.Equal(t, "#/components/schemas/ErrorResponse", user.Content["application/json"].Schema["$ref"])
+	assert.Equal(t, "NotFound.User", user.Content["application/json"].Example["reason"])
+
+	// 替换非法字符后重复的 key 会被拒绝
+	out.Reset()
+	collision := []Definition{defs[1], {Code: http.StatusTooManyRequests, Reason: "Quota_Exceeded"}}
+	assert.ErrorContains(t, WriteCatalog(&out, collision, CatalogOpenAPI), "Quota/Exceeded and Quota_Exceeded")
+	assert.Empty(t, out.String())
+
+	assert.Error(t, WriteCatalog(&out, defs, "xml"))
+}
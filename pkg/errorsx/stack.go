@@ -0,0 +1,128 @@
+package errorsx
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync/atomic"
+)
+
+// maxStackDepth 是记录调用栈的最大深度.
+const maxStackDepth = 32
+
+var (
+	// stackEnabled 表示创建错误时是否记录调用栈.
+	stackEnabled atomic.Bool
+
+	// pkgPrefix 是本包函数名的前缀，用于从调用栈中去掉本包内部的帧.
+	pkgPrefix = reflect.TypeFor[ErrorX]().PkgPath() + "."
+)
+
+// EnableStackTrace 设置创建错误时是否记录调用栈，默认关闭.
+// 记录调用栈有一定开销，建议只在开发环境或排查问题时开启.
+func EnableStackTrace(enabled bool) {
+	stackEnabled.Store(enabled)
+}
+
+// callers 返回当前的调用栈，未开启 EnableStackTrace 时返回 nil.
+func callers() []uintptr {
+	if !stackEnabled.Load() {
+		return nil
+	}
+
+	pcs := make([]uintptr, maxStackDepth)
+	n := runtime.Callers(2, pcs)
+	return pcs[:n]
+}
+
+// StackTrace 返回创建错误时的调用栈，第一帧是创建错误的调用方.
+// 未开启 EnableStackTrace 时返回 nil.
+func (err *ErrorX) StackTrace() []runtime.Frame {
+	if len(err.stack) == 0 {
+		return nil
+	}
+
+	var frames []runtime.Frame
+	it := runtime.CallersFrames(err.stack)
+	for {
+		frame, more := it.Next()
+		// 去掉本包内部的帧，例如 New、clone 和 WithMessage
+		if len(frames) > 0 || !isInternalFrame(frame) {
+			frames = append(frames, frame)
+		}
+		if !more {
+			break
+		}
+	}
+	return frames
+}
+
+// isInternalFrame 判断 frame 是否属于本包的非测试代码.
+func isInternalFrame(frame runtime.Frame) bool {
+	return strings.HasPrefix(frame.Function, pkgPrefix) && !strings.HasSuffix(frame.File, "_test.go")
+}
+
+// Format 实现 fmt.Formatter 接口.
+// %s 和 %v 输出 Error() 的结果，%+v 还会输出调用栈以及底层错误的详细信息.
+func (err *ErrorX) Format(s fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		if s.Flag('+') {
+			_, _ = io.WriteString(s, err.Error())
+			for _, frame := range err.StackTrace() {
+				_, _ = fmt.Fprintf(s, "\n%s\n\t%s:%d", frame.Function, frame.File, frame.Line)
+			}
+			if err.cause != nil {
+				_, _ = fmt.Fprintf(s, "\ncaused by: %+v", err.cause)
+			}
+			return
+		}
+		_, _ = io.WriteString(s, err.Error())
+	case 's':
+		_, _ = io.WriteString(s, err.Error())
+	case 'q':
+		_, _ = fmt.Fprintf(s, "%q", err.Error())
+	}
+}
+
+// LogValue 实现 slog.LogValuer 接口，记录日志时输出完整的错误链和调用栈.
+// 这些信息只用于日志，不会出现在返回给客户端的响应中.
+func (err *ErrorX) LogValue() slog.Value {
+	attrs := []slog.Attr{
+		slog.Int("code", err.Code),
+		slog.String("reason", err.Reason),
+		slog.String("message", err.Message),
+	}
+	if len(err.Metadata) > 0 {
+		attrs = append(attrs, slog.Any("metadata", err.Metadata))
+	}
+	if chain := Chain(err.cause); len(chain) > 0 {
+		attrs = append(attrs, slog.Any("causes", chain))
+	}
+	if frames := err.StackTrace(); len(frames) > 0 {
+		stack := make([]string, len(frames))
+		for i, frame := range frames {
+			stack[i] = fmt.Sprintf("%s %s:%d", frame.Function, frame.File, frame.Line)
+		}
+		attrs = append(attrs, slog.Any("stack", stack))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// Chain 返回 err 及其通过 Unwrap 得到的所有底层错误的描述.
+// *ErrorX 只输出 reason 和 message，不会重复输出其底层错误.
+func Chain(err error) []string {
+	var chain []string
+	for err != nil {
+		if errx, ok := err.(*ErrorX); ok {
+			chain = append(chain, errx.Reason+": "+errx.Message)
+		} else {
+			chain = append(chain, err.Error())
+		}
+		err = Unwrap(err)
+	}
+	return chain
+}
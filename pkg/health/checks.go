@@ -0,0 +1,24 @@
+package health
+
+import (
+	"context"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+
+	"chunyu/pkg/db"
+)
+
+// DBChecker returns a checker pinging the database behind gdb.
+func DBChecker(gdb *gorm.DB) Checker {
+	return CheckerFunc(func(ctx context.Context) error {
+		return db.MustRawDB(gdb).PingContext(ctx)
+	})
+}
+
+// RedisChecker returns a checker pinging the redis server behind rdb.
+func RedisChecker(rdb redis.UniversalClient) Checker {
+	return CheckerFunc(func(ctx context.Context) error {
+		return rdb.Ping(ctx).Err()
+	})
+}
@@ -0,0 +1,326 @@
+// Package health provides a registry of named health checks and the HTTP
+// handlers serving them.
+//
+// Every registered check is a readiness check, served by /readyz. Checks
+// registered with Liveness are also liveness checks, served by /livez: they
+// should only fail when the process must be restarted, so dependencies such
+// as databases are usually readiness checks only.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultTimeout is used for checks which do not set their own timeout.
+	defaultTimeout = 5 * time.Second
+
+	statusOK     = "ok"
+	statusFailed = "failed"
+)
+
+// Kind selects the checks run by Registry.Check.
+type Kind int
+
+const (
+	// Readiness selects every check.
+	Readiness Kind = iota
+	// Liveness selects the checks registered with the Liveness option.
+	Liveness
+)
+
+// DefaultRegistry is the registry used by the package level functions.
+var DefaultRegistry = NewRegistry()
+
+// Checker checks the health of a component.
+type Checker interface {
+	// Check returns nil if the component is healthy. It must return when ctx
+	// is done.
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc is an adapter to allow the use of ordinary functions as checkers.
+type CheckerFunc func(ctx context.Context) error
+
+// Check implements Checker.
+func (fn CheckerFunc) Check(ctx context.Context) error {
+	return fn(ctx)
+}
+
+// Result is the result of a single check.
+type Result struct {
+	Name     string        `json:"name"`
+	Status   string        `json:"status"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"-"`
+	Cached   bool          `json:"cached,omitempty"`
+}
+
+// MarshalJSON renders the duration in a human readable form.
+func (r Result) MarshalJSON() ([]byte, error) {
+	type result Result
+	return json.Marshal(struct {
+		result
+		Duration string `json:"duration"`
+	}{result(r), r.Duration.String()})
+}
+
+// Healthy reports whether the check passed.
+func (r Result) Healthy() bool {
+	return r.Status == statusOK
+}
+
+// Report is the result of a set of checks.
+type Report struct {
+	Status string   `json:"status"`
+	Checks []Result `json:"checks,omitempty"`
+}
+
+// Healthy reports whether every check passed.
+func (r Report) Healthy() bool {
+	return r.Status == statusOK
+}
+
+// check is a registered checker and its cached result.
+type check struct {
+	name     string
+	checker  Checker
+	liveness bool
+	timeout  time.Duration
+	cacheTTL time.Duration
+
+	// mu guards the fields below. It is not held while the check runs.
+	mu      sync.Mutex
+	last    Result
+	checked time.Time
+	// running is the execution in progress, joined by concurrent probes.
+	running *execution
+}
+
+// execution is a single run of a check.
+type execution struct {
+	done   chan struct{}
+	result Result
+}
+
+// CheckOption defines optional parameters for registering a check.
+type CheckOption func(*check)
+
+// WithLiveness makes the check a liveness check as well as a readiness check.
+func WithLiveness() CheckOption {
+	return func(c *check) {
+		c.liveness = true
+	}
+}
+
+// WithTimeout bounds the duration of the check. A check which does not
+// return in time fails.
+func WithTimeout(d time.Duration) CheckOption {
+	return func(c *check) {
+		c.timeout = d
+	}
+}
+
+// WithCacheTTL caches the result of the check for d, so that frequent probes
+// do not hit the checked component every time.
+func WithCacheTTL(d time.Duration) CheckOption {
+	return func(c *check) {
+		c.cacheTTL = d
+	}
+}
+
+// Registry holds named health checks.
+// It is recommended that a registry be created with the NewRegistry() function.
+type Registry struct {
+	mu     sync.RWMutex
+	checks []*check
+
+	timeout  time.Duration
+	cacheTTL time.Duration
+}
+
+// RegistryOption defines optional parameters for initializing the registry.
+type RegistryOption func(*Registry)
+
+// WithDefaultTimeout sets the timeout of checks which do not set their own.
+func WithDefaultTimeout(d time.Duration) RegistryOption {
+	return func(r *Registry) {
+		r.timeout = d
+	}
+}
+
+// WithDefaultCacheTTL sets the cache TTL of checks which do not set their own.
+// Zero disables caching.
+func WithDefaultCacheTTL(d time.Duration) RegistryOption {
+	return func(r *Registry) {
+		r.cacheTTL = d
+	}
+}
+
+// NewRegistry creates a new registry with the given options.
+func NewRegistry(opts ...RegistryOption) *Registry {
+	r := &Registry{timeout: defaultTimeout}
+	for _, o := range opts {
+		o(r)
+	}
+
+	return r
+}
+
+// Register adds a named check. Registering a name twice replaces the previous
+// check.
+func (r *Registry) Register(name string, checker Checker, opts ...CheckOption) {
+	c := &check{name: name, checker: checker, timeout: r.timeout, cacheTTL: r.cacheTTL}
+	for _, o := range opts {
+		o(c)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.checks = slices.DeleteFunc(r.checks, func(c *check) bool { return c.name == name })
+	r.checks = append(r.checks, c)
+}
+
+// Unregister removes a named check.
+func (r *Registry) Unregister(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.checks = slices.DeleteFunc(r.checks, func(c *check) bool { return c.name == name })
+}
+
+// Check runs the checks of the given kind concurrently, except the excluded
+// ones, and returns their results in registration order.
+func (r *Registry) Check(ctx context.Context, kind Kind, exclude ...string) Report {
+	r.mu.RLock()
+	var checks []*check
+	for _, c := range r.checks {
+		if (kind == Readiness || c.liveness) && !slices.Contains(exclude, c.name) {
+			checks = append(checks, c)
+		}
+	}
+	r.mu.RUnlock()
+
+	report := Report{Status: statusOK, Checks: make([]Result, len(checks))}
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			report.Checks[i] = c.run(ctx)
+		}()
+	}
+	wg.Wait()
+
+	for _, result := range report.Checks {
+		if !result.Healthy() {
+			report.Status = statusFailed
+		}
+	}
+
+	return report
+}
+
+// run runs the check, or returns its cached result. Concurrent calls share a
+// single execution. The check runs detached from ctx, so that a probe which
+// gives up does not fail, nor cache a failure for, the other probes; the
+// caller only stops waiting for it.
+func (c *check) run(ctx context.Context) Result {
+	c.mu.Lock()
+	if c.cacheTTL > 0 && !c.checked.IsZero() && time.Since(c.checked) < c.cacheTTL {
+		result := c.last
+		c.mu.Unlock()
+		result.Cached = true
+		return result
+	}
+
+	exec := c.running
+	if exec == nil {
+		exec = &execution{done: make(chan struct{})}
+		c.running = exec
+		go c.execute(context.WithoutCancel(ctx), exec)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-exec.done:
+		return exec.result
+	case <-ctx.Done():
+		return Result{Name: c.name, Status: statusFailed, Error: fmt.Sprintf("check canceled: %v", ctx.Err())}
+	}
+}
+
+// execute runs the check within its timeout and stores the result.
+func (c *check) execute(ctx context.Context, exec *execution) {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- c.checker.Check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = fmt.Errorf("check timed out: %w", ctx.Err())
+	}
+
+	result := Result{Name: c.name, Status: statusOK, Duration: time.Since(start)}
+	if err != nil {
+		result.Status, result.Error = statusFailed, err.Error()
+	}
+	exec.result = result
+
+	c.mu.Lock()
+	c.last, c.checked = result, time.Now()
+	c.running = nil
+	c.mu.Unlock()
+	close(exec.done)
+}
+
+// Handler returns a handler serving the checks of the given kind.
+//
+// It answers 200 when every check passed and 503 otherwise. The results of
+// the individual checks are included when a check failed or when the verbose
+// query parameter is set. Checks can be skipped with the exclude query
+// parameter, e.g. /readyz?exclude=redis&exclude=mysql.
+func (r *Registry) Handler(kind Kind) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		query := req.URL.Query()
+		var exclude []string
+		for _, value := range query["exclude"] {
+			exclude = append(exclude, strings.Split(value, ",")...)
+		}
+
+		report := r.Check(req.Context(), kind, exclude...)
+		code := http.StatusOK
+		if !report.Healthy() {
+			code = http.StatusServiceUnavailable
+		} else if _, verbose := query["verbose"]; !verbose {
+			report.Checks = nil
+		}
+
+		rw.Header().Set("Content-Type", "application/json")
+		rw.WriteHeader(code)
+		_ = json.NewEncoder(rw).Encode(report)
+	})
+}
+
+// Register adds a named check to the default registry.
+func Register(name string, checker Checker, opts ...CheckOption) {
+	DefaultRegistry.Register(name, checker, opts...)
+}
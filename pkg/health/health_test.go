@@ -0,0 +1,117 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry_Handler(t *testing.T) {
+	r := NewRegistry()
+	r.Register("ping", CheckerFunc(func(context.Context) error { return nil }), WithLiveness())
+	r.Register("db", CheckerFunc(func(context.Context) error { return errors.New("connection refused") }))
+
+	tests := []struct {
+		name   string
+		kind   Kind
+		target string
+		code   int
+		checks []string
+	}{
+		{"livez", Liveness, "/livez", http.StatusOK, nil},
+		{"livez verbose", Liveness, "/livez?verbose", http.StatusOK, []string{"ping"}},
+		{"readyz", Readiness, "/readyz", http.StatusServiceUnavailable, []string{"ping", "db"}},
+		{"readyz exclude", Readiness, "/readyz?exclude=db", http.StatusOK, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			r.Handler(tt.kind).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			assert.Equal(t, tt.code, rec.Code)
+
+			var report struct {
+				Status string
+				Checks []struct{ Name, Status, Error string }
+			}
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+			var names []string
+			for _, c := range report.Checks {
+				names = append(names, c.Name)
+				if c.Name == "db" {
+					assert.Equal(t, "connection refused", c.Error)
+				}
+			}
+			assert.Equal(t, tt.checks, names)
+		})
+	}
+}
+
+func TestRegistry_TimeoutAndCache(t *testing.T) {
+	r := NewRegistry(WithDefaultTimeout(20 * time.Millisecond))
+
+	var calls atomic.Int32
+	r.Register("slow", CheckerFunc(func(ctx context.Context) error {
+		calls.Add(1)
+		<-ctx.Done()
+		return nil
+	}), WithCacheTTL(time.Minute))
+
+	report := r.Check(context.Background(), Readiness)
+	require.False(t, report.Healthy())
+	assert.Contains(t, report.Checks[0].Error, "timed out")
+
+	report = r.Check(context.Background(), Readiness)
+	assert.True(t, report.Checks[0].Cached)
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestRegistry_CallerCancellation(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	r := NewRegistry()
+	r.Register("slow", CheckerFunc(func(ctx context.Context) error {
+		calls.Add(1)
+		select {
+		case <-release:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}), WithTimeout(time.Minute), WithCacheTTL(time.Minute))
+
+	// A probe giving up fails without affecting the check.
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+	report := r.Check(ctx, Readiness)
+	assert.False(t, report.Healthy())
+	assert.Contains(t, report.Checks[0].Error, "check canceled")
+
+	// A later probe joins the execution still in progress instead of
+	// starting another one, and its result is cached.
+	results := make(chan Report, 2)
+	for range 2 {
+		go func() { results <- r.Check(context.Background(), Readiness) }()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	for range 2 {
+		report := <-results
+		assert.True(t, report.Healthy(), report.Checks)
+	}
+	assert.EqualValues(t, 1, calls.Load())
+
+	report = r.Check(context.Background(), Readiness)
+	assert.True(t, report.Healthy())
+	assert.True(t, report.Checks[0].Cached)
+}
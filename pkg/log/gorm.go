@@ -33,6 +33,11 @@ func NewGormLogger(log *slog.Logger, debug bool, slowThreshold time.Duration) *G
 	}
 }
 
+// Default 返回使用默认 slog Logger 的 GORM Logger
+func Default() *GormLogger {
+	return NewGormLogger(slog.Default(), false, 0)
+}
+
 // LogMode 设置日志级别，实现 gorm.io/gorm/logger.Interface 接口
 func (l *GormLogger) LogMode(level logger.LogLevel) logger.Interface {
 	newLogger := *l
@@ -1,9 +1,12 @@
 package log
 
 import (
+	"strings"
 	"time"
 
 	"github.com/spf13/pflag"
+
+	"chunyu/pkg/util/validation"
 )
 
 type Options struct {
@@ -12,13 +15,13 @@ type Options struct {
 	Name         string        `json:"name" mapstructure:"name" comment:"实例名称"`
 	Version      string        `json:"version" mapstructure:"version" comment:"实例版本"`
 	Debug        bool          `json:"debug" mapstructure:"debug" comment:"是否开启调试模式"`
-	MaxAge       time.Duration `json:"max_age" mapstructure:"max_age" comment:"日志最大保存时间"`
-	RotationTime time.Duration `json:"rotation_time" mapstructure:"rotation_time" comment:"日志滚动时间"`
-	RotationSize int64         `json:"rotation_size" mapstructure:"rotation_size" comment:"日志滚动大小"` // 单位字节
-	Level        string        `json:"level" mapstructure:"level" comment:"日志级别"`                   // debug/info/warn/error
-	TickSec      int           `json:"tick_sec" mapstructure:"tick_sec" comment:"时间窗口(秒)"`
-	First        int           `json:"first" mapstructure:"first" comment:"每个时间窗口内记录的前N条日志"`
-	Thereafter   int           `json:"thereafter" mapstructure:"thereafter" comment:"超过N条后每M条记录一次"` // 采样器
+	MaxAge       time.Duration `json:"max_age" mapstructure:"max_age" comment:"日志最大保存时间" validate:"min=0"`
+	RotationTime time.Duration `json:"rotation_time" mapstructure:"rotation_time" comment:"日志滚动时间" validate:"min=0"`
+	RotationSize int64         `json:"rotation_size" mapstructure:"rotation_size" comment:"日志滚动大小" validate:"min=0"`     // 单位字节
+	Level        string        `json:"level" mapstructure:"level" comment:"日志级别" validate:"oneof=debug info warn error"` // debug/info/warn/error
+	TickSec      int           `json:"tick_sec" mapstructure:"tick_sec" comment:"时间窗口(秒)" validate:"min=0"`
+	First        int           `json:"first" mapstructure:"first" comment:"每个时间窗口内记录的前N条日志" validate:"min=0"`
+	Thereafter   int           `json:"thereafter" mapstructure:"thereafter" comment:"超过N条后每M条记录一次" validate:"min=0"` // 采样器
 }
 
 func NewOptions() *Options {
@@ -38,8 +41,10 @@ func NewOptions() *Options {
 }
 
 func (o *Options) Validate() []error {
-	errs := []error{}
-	return errs
+	// SetLevel 不区分大小写，例如 INFO，校验时同样忽略大小写
+	normalized := *o
+	normalized.Level = strings.ToLower(o.Level)
+	return validation.ValidateStruct(&normalized, "log")
 }
 
 func (o *Options) AddFlags(fs *pflag.FlagSet) {
@@ -4,6 +4,7 @@ import (
 	"context"
 	"log/slog"
 
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap/exp/zapslog"
 	"go.uber.org/zap/zapcore"
 )
@@ -23,13 +24,27 @@ func newSlog(core zapcore.Core, opts ...zapslog.HandlerOption) *Slog {
 	}
 }
 
+// Handle 记录 context 中的参数，以及当前 span 的 trace_id 和 span_id
 func (s *Slog) Handle(ctx context.Context, record slog.Record) error {
 	if attrs, ok := ctx.Value(SlogFieldsKey).([]slog.Attr); ok {
 		record.AddAttrs(attrs...)
 	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		record.AddAttrs(slog.String("trace_id", sc.TraceID().String()), slog.String("span_id", sc.SpanID().String()))
+	}
 	return s.Handler.Handle(ctx, record)
 }
 
+// WithAttrs 保留 Slog 的包装，使 slog.With 创建的 logger 同样记录 context 中的参数
+func (s *Slog) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &Slog{Handler: s.Handler.WithAttrs(attrs).(*zapslog.Handler)}
+}
+
+// WithGroup 保留 Slog 的包装，同 WithAttrs
+func (s *Slog) WithGroup(name string) slog.Handler {
+	return &Slog{Handler: s.Handler.WithGroup(name).(*zapslog.Handler)}
+}
+
 // WithAttr 使用此函数创建的上下文，当应用在 slog 上下文时，会自动记录存在 context 中的参数
 func WithAttr(parent context.Context, attr slog.Attr) context.Context {
 	if parent == nil {
@@ -0,0 +1,132 @@
+// Package metrics holds the Prometheus registry of the framework and the
+// metrics recorded by its servers and database clients.
+//
+// The registry exports the Go runtime and process metrics, and is served by
+// Handler, usually on the health check address.
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Namespace prefixes the name of every metric of the framework.
+const Namespace = "chunyu"
+
+// Registry is the registry the framework metrics are registered with.
+// Applications register their own collectors with it to export them.
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "Number of HTTP requests handled, by method, route and status code.",
+	}, []string{"method", "route", "code"})
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Duration of HTTP requests, by method and route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+	httpInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Subsystem: "http",
+		Name:      "requests_in_flight",
+		Help:      "Number of HTTP requests being handled.",
+	})
+
+	grpcRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "grpc",
+		Name:      "server_handled_total",
+		Help:      "Number of gRPC calls handled, by method, type and status code.",
+	}, []string{"method", "type", "code"})
+	grpcDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Subsystem: "grpc",
+		Name:      "server_handling_seconds",
+		Help:      "Duration of gRPC calls, by method and type.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "type"})
+
+	dbDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Subsystem: "db",
+		Name:      "query_duration_seconds",
+		Help:      "Duration of database queries, by operation and table.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	}, []string{"operation", "table"})
+	dbErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "db",
+		Name:      "query_errors_total",
+		Help:      "Number of failed database queries, by operation and table.",
+	}, []string{"operation", "table"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests, httpDuration, httpInFlight,
+		grpcRequests, grpcDuration,
+		dbDuration, dbErrors,
+	)
+}
+
+// Handler returns the handler exposing the metrics of Registry.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// HTTPRequestStarted records the start of an HTTP request and returns the
+// function recording its end.
+func HTTPRequestStarted() func(method, route string, code int) {
+	start := time.Now()
+	httpInFlight.Inc()
+
+	return func(method, route string, code int) {
+		httpInFlight.Dec()
+		httpRequests.WithLabelValues(method, route, strconv.Itoa(code)).Inc()
+		httpDuration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+	}
+}
+
+// ObserveGRPCCall records a handled gRPC call. typ is unary or stream.
+func ObserveGRPCCall(method, typ, code string, d time.Duration) {
+	grpcRequests.WithLabelValues(method, typ, code).Inc()
+	grpcDuration.WithLabelValues(method, typ).Observe(d.Seconds())
+}
+
+// ObserveDBQuery records a database query. failed reports whether the query
+// returned an error.
+func ObserveDBQuery(operation, table string, d time.Duration, failed bool) {
+	dbDuration.WithLabelValues(operation, table).Observe(d.Seconds())
+	if failed {
+		dbErrors.WithLabelValues(operation, table).Inc()
+	}
+}
+
+// RegisterDBStats exports the sql.DBStats of the pool db, labelled with name.
+// It returns a prometheus.AlreadyRegisteredError if a pool is already
+// registered with name; to replace a pool, e.g. after a reconnect, call
+// UnregisterDBStats first.
+func RegisterDBStats(name string, db *sql.DB) error {
+	return Registry.Register(collectors.NewDBStatsCollector(db, name))
+}
+
+// UnregisterDBStats stops exporting the statistics of the pool registered with
+// name. It reports whether a pool was registered.
+func UnregisterDBStats(name string) bool {
+	// Collectors are identified by their descriptors, which only depend on name.
+	return Registry.Unregister(collectors.NewDBStatsCollector(nil, name))
+}
@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"database/sql"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakePool struct {
+	stats redis.PoolStats
+}
+
+func (p *fakePool) PoolStats() *redis.PoolStats {
+	return &p.stats
+}
+
+func TestHandler(t *testing.T) {
+	HTTPRequestStarted()(http.MethodGet, "/v1/users/:id", http.StatusNotFound)
+	ObserveDBQuery("query", "users", time.Millisecond, true)
+	assert.Equal(t, 1.0, testutil.ToFloat64(httpRequests.WithLabelValues(http.MethodGet, "/v1/users/:id", "404")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(dbErrors.WithLabelValues("query", "users")))
+
+	require.NoError(t, RegisterRedisPoolStats("cache", &fakePool{redis.PoolStats{TotalConns: 3}}))
+	// Another client with the same name is rejected, it does not silently
+	// replace the first one.
+	var are prometheus.AlreadyRegisteredError
+	require.ErrorAs(t, RegisterRedisPoolStats("cache", &fakePool{redis.PoolStats{TotalConns: 4}}), &are)
+	// A client is replaced by unregistering it first.
+	assert.True(t, UnregisterRedisPoolStats("cache"))
+	require.NoError(t, RegisterRedisPoolStats("cache", &fakePool{redis.PoolStats{TotalConns: 4}}))
+
+	require.NoError(t, RegisterDBStats("mysql:db:3306/app", &sql.DB{}))
+	require.ErrorAs(t, RegisterDBStats("mysql:db:3306/app", &sql.DB{}), &are)
+	require.NoError(t, RegisterDBStats("postgresql:db:5432/app", &sql.DB{}))
+	assert.True(t, UnregisterDBStats("mysql:db:3306/app"))
+	assert.False(t, UnregisterDBStats("mysql:db:3306/app"))
+	assert.True(t, UnregisterDBStats("postgresql:db:5432/app"))
+
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, _ := io.ReadAll(rec.Body)
+
+	assert.Contains(t, string(body), `chunyu_redis_pool_connections{client="cache"} 4`)
+	assert.Contains(t, string(body), `chunyu_http_requests_total{code="404",method="GET",route="/v1/users/:id"} 1`)
+	assert.Contains(t, string(body), "go_goroutines")
+	assert.Contains(t, string(body), "process_start_time_seconds")
+}
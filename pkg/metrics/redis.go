@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+)
+
+// PoolStatter is implemented by the redis clients.
+type PoolStatter interface {
+	PoolStats() *redis.PoolStats
+}
+
+// RegisterRedisPoolStats exports the connection pool statistics of rdb,
+// labelled with name. It returns a prometheus.AlreadyRegisteredError if a
+// client is already registered with name; to replace a client, call
+// UnregisterRedisPoolStats first.
+func RegisterRedisPoolStats(name string, rdb PoolStatter) error {
+	return Registry.Register(newRedisCollector(name, rdb))
+}
+
+// UnregisterRedisPoolStats stops exporting the statistics of the client
+// registered with name. It reports whether a client was registered.
+func UnregisterRedisPoolStats(name string) bool {
+	return Registry.Unregister(newRedisCollector(name, nil))
+}
+
+// redisCollector collects the pool statistics of a redis client on scrape.
+type redisCollector struct {
+	rdb PoolStatter
+
+	hits       *prometheus.Desc
+	misses     *prometheus.Desc
+	timeouts   *prometheus.Desc
+	totalConns *prometheus.Desc
+	idleConns  *prometheus.Desc
+	staleConns *prometheus.Desc
+}
+
+func newRedisCollector(name string, rdb PoolStatter) *redisCollector {
+	labels := prometheus.Labels{"client": name}
+	desc := func(metric, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(Namespace, "redis_pool", metric), help, nil, labels)
+	}
+
+	return &redisCollector{
+		rdb:        rdb,
+		hits:       desc("hits_total", "Number of times a free connection was found in the pool."),
+		misses:     desc("misses_total", "Number of times a free connection was not found in the pool."),
+		timeouts:   desc("timeouts_total", "Number of times a wait for a connection timed out."),
+		totalConns: desc("connections", "Number of connections in the pool."),
+		idleConns:  desc("idle_connections", "Number of idle connections in the pool."),
+		staleConns: desc("stale_connections_total", "Number of stale connections removed from the pool."),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *redisCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.hits
+	ch <- c.misses
+	ch <- c.timeouts
+	ch <- c.totalConns
+	ch <- c.idleConns
+	ch <- c.staleConns
+}
+
+// Collect implements prometheus.Collector.
+func (c *redisCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.rdb.PoolStats()
+	ch <- prometheus.MustNewConstMetric(c.hits, prometheus.CounterValue, float64(stats.Hits))
+	ch <- prometheus.MustNewConstMetric(c.misses, prometheus.CounterValue, float64(stats.Misses))
+	ch <- prometheus.MustNewConstMetric(c.timeouts, prometheus.CounterValue, float64(stats.Timeouts))
+	ch <- prometheus.MustNewConstMetric(c.totalConns, prometheus.GaugeValue, float64(stats.TotalConns))
+	ch <- prometheus.MustNewConstMetric(c.idleConns, prometheus.GaugeValue, float64(stats.IdleConns))
+	ch <- prometheus.MustNewConstMetric(c.staleConns, prometheus.CounterValue, float64(stats.StaleConns))
+}
@@ -4,6 +4,8 @@ import (
 	"time"
 
 	"github.com/spf13/pflag"
+
+	"chunyu/pkg/util/validation"
 )
 
 var _ IOptions = (*GRPCOptions)(nil)
@@ -17,7 +19,8 @@ type GRPCOptions struct {
 	// Address with server address.
 	Addr string `json:"addr" mapstructure:"addr"`
 
-	Timeout time.Duration `json:"timeout" mapstructure:"timeout"`
+	// Timeout is the per-call deadline enforced by the server. Zero disables it.
+	Timeout time.Duration `json:"timeout" mapstructure:"timeout" validate:"min=0"`
 }
 
 // NewGRPCOptions is for creating an unauthenticated, unauthorized, insecure port.
@@ -35,11 +38,11 @@ func NewGRPCOptions() *GRPCOptions {
 func (o *GRPCOptions) Validate() []error {
 	var errors []error
 
-	if err := ValidateAddress(o.Addr); err != nil {
+	if err := ValidateNetworkAddress(o.Network, o.Addr); err != nil {
 		errors = append(errors, err)
 	}
 
-	return errors
+	return append(errors, validation.ValidateStruct(o, "grpc")...)
 }
 
 // AddFlags adds flags related to features for a specific api server to the
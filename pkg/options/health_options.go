@@ -1,59 +1,106 @@
 package options
 
 import (
+	"context"
 	"log/slog"
 	"net/http"
 	"net/http/pprof"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/spf13/pflag"
+
+	"chunyu/pkg/health"
+	"chunyu/pkg/metrics"
+	"chunyu/pkg/util/validation"
 )
 
 var _ IOptions = (*HealthOptions)(nil)
 
 type HealthOptions struct {
-	HTTPProfile        bool   `json:"enable-http-profiler" mapstructure:"enable-http-profiler"`
-	HealthCheckPath    string `json:"check-path" mapstructure:"check-path"`
-	HealthCheckAddress string `json:"check-address" mapstructure:"check-address"`
+	HTTPProfile        bool          `json:"enable-http-profiler" mapstructure:"enable-http-profiler"`
+	HealthCheckPath    string        `json:"check-path" mapstructure:"check-path" validate:"required"`
+	LivenessPath       string        `json:"liveness-path" mapstructure:"liveness-path" validate:"required"`
+	ReadinessPath      string        `json:"readiness-path" mapstructure:"readiness-path" validate:"required"`
+	MetricsPath        string        `json:"metrics-path" mapstructure:"metrics-path"`
+	HealthCheckAddress string        `json:"check-address" mapstructure:"check-address" validate:"required,address"`
+	CheckTimeout       time.Duration `json:"check-timeout" mapstructure:"check-timeout" validate:"min=0"`
+	CacheTTL           time.Duration `json:"cache-ttl" mapstructure:"cache-ttl" validate:"min=0"`
 }
 
 func NewHealthOptions() *HealthOptions {
 	return &HealthOptions{
 		HTTPProfile:        false,
 		HealthCheckPath:    "/healthz",
+		LivenessPath:       "/livez",
+		ReadinessPath:      "/readyz",
+		MetricsPath:        "/metrics",
 		HealthCheckAddress: "0.0.0.0:20250",
+		CheckTimeout:       5 * time.Second,
+		CacheTTL:           0,
 	}
 }
 
 func (o *HealthOptions) Validate() []error {
-	errs := []error{}
-
-	return errs
+	return validation.ValidateStruct(o, "health")
 }
 
 func (o *HealthOptions) AddFlags(fs *pflag.FlagSet, prefixes ...string) {
 	fs.BoolVar(&o.HTTPProfile, "health.enable-http-profiler", o.HTTPProfile, "Expose runtime profiling data via HTTP.")
-	fs.StringVar(&o.HealthCheckPath, "health.check-path", o.HealthCheckPath, "Specifies liveness health check request path.")
-	fs.StringVar(&o.HealthCheckAddress, "health.check-address", o.HealthCheckAddress, "Specifies liveness health check bind address.")
+	fs.StringVar(&o.HealthCheckPath, "health.check-path", o.HealthCheckPath, "Specifies health check request path, running every check.")
+	fs.StringVar(&o.LivenessPath, "health.liveness-path", o.LivenessPath, "Specifies liveness health check request path.")
+	fs.StringVar(&o.ReadinessPath, "health.readiness-path", o.ReadinessPath, "Specifies readiness health check request path.")
+	fs.StringVar(&o.MetricsPath, "health.metrics-path", o.MetricsPath, "Specifies Prometheus metrics request path, empty disables metrics.")
+	fs.StringVar(&o.HealthCheckAddress, "health.check-address", o.HealthCheckAddress, "Specifies health check bind address.")
+	fs.DurationVar(&o.CheckTimeout, "health.check-timeout", o.CheckTimeout, "Timeout of a single health check.")
+	fs.DurationVar(&o.CacheTTL, "health.cache-ttl", o.CacheTTL, "Duration the result of a health check is cached for, 0 disables caching.")
 }
 
-func (o *HealthOptions) ServeHealthCheck() {
+// NewRegistry creates a health check registry using the configured timeout
+// and cache TTL as defaults.
+func (o *HealthOptions) NewRegistry() *health.Registry {
+	return health.NewRegistry(health.WithDefaultTimeout(o.CheckTimeout), health.WithDefaultCacheTTL(o.CacheTTL))
+}
+
+// Handler returns the handler serving the health check endpoints of reg, the
+// metrics and the profiling endpoints when enabled.
+func (o *HealthOptions) Handler(reg *health.Registry) http.Handler {
 	r := mux.NewRouter()
 
-	r.HandleFunc(o.HealthCheckPath, handler).Methods(http.MethodGet)
+	r.Handle(o.HealthCheckPath, reg.Handler(health.Readiness)).Methods(http.MethodGet)
+	r.Handle(o.LivenessPath, reg.Handler(health.Liveness)).Methods(http.MethodGet)
+	r.Handle(o.ReadinessPath, reg.Handler(health.Readiness)).Methods(http.MethodGet)
+	if o.MetricsPath != "" {
+		r.Handle(o.MetricsPath, metrics.Handler()).Methods(http.MethodGet)
+	}
 	if o.HTTPProfile {
 		r.HandleFunc("/debug/pprof/profile", pprof.Profile)
 		r.HandleFunc("/debug/pprof/{_:.*}", pprof.Index)
 	}
 
-	slog.Info("Starting health check server", "path", o.HealthCheckPath, "addr", o.HealthCheckAddress)
-	if err := http.ListenAndServe(o.HealthCheckAddress, r); err != nil {
+	return r
+}
+
+// ServeHealthCheck serves the checks of health.DefaultRegistry until ctx is
+// done, then shuts the listener down. It returns early with the error if the
+// server fails to serve, e.g. when the address is already in use.
+func (o *HealthOptions) ServeHealthCheck(ctx context.Context) error {
+	srv := &http.Server{Addr: o.HealthCheckAddress, Handler: o.Handler(health.DefaultRegistry)}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = srv.Shutdown(context.Background())
+		case <-done:
+		}
+	}()
+
+	slog.Info("Starting health check server", "livez", o.LivenessPath, "readyz", o.ReadinessPath, "addr", o.HealthCheckAddress)
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 		slog.Error("Error serving health check endpoint", "error", err)
+		return err
 	}
-}
 
-func handler(rw http.ResponseWriter, r *http.Request) {
-	rw.Header().Set("Content-type", "application/json")
-	rw.WriteHeader(http.StatusOK)
-	rw.Write([]byte(`{"status": "ok"}`))
+	return nil
 }
@@ -0,0 +1,44 @@
+package options
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthOptions_ServeHealthCheck(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = lis.Close() })
+
+	// The address is in use: the error is returned without waiting for ctx.
+	o := NewHealthOptions()
+	o.HealthCheckAddress = lis.Addr().String()
+	assert.Error(t, o.ServeHealthCheck(context.Background()))
+
+	addr := lis.Addr().String()
+	require.NoError(t, lis.Close())
+	o.HealthCheckAddress = addr
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() { errCh <- o.ServeHealthCheck(ctx) }()
+	require.Eventually(t, func() bool {
+		conn, err := net.Dial("tcp", addr)
+		if err == nil {
+			_ = conn.Close()
+		}
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	select {
+	case err := <-errCh:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("ServeHealthCheck did not return after ctx was cancelled")
+	}
+}
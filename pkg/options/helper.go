@@ -44,6 +44,23 @@ func ValidateAddress(addr string) error {
 	return nil
 }
 
+// ValidateNetworkAddress validates addr according to the given network.
+// TCP networks require a valid :port or IP:port address, unix networks require
+// a non-empty socket path.
+func ValidateNetworkAddress(network, addr string) error {
+	switch network {
+	case "", "tcp", "tcp4", "tcp6":
+		return ValidateAddress(addr)
+	case "unix", "unixpacket":
+		if addr == "" {
+			return fmt.Errorf("unix socket path must not be empty")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported network %q, must be one of tcp, tcp4, tcp6, unix, unixpacket", network)
+	}
+}
+
 // CreateListener create net listener by given address and returns it and port.
 func CreateListener(addr string) (net.Listener, int, error) {
 	network := "tcp"
@@ -4,6 +4,8 @@ import (
 	"time"
 
 	"github.com/spf13/pflag"
+
+	"chunyu/pkg/util/validation"
 )
 
 var _ IOptions = (*HTTPOptions)(nil)
@@ -17,15 +19,30 @@ type HTTPOptions struct {
 	Addr string `json:"addr" mapstructure:"addr"`
 
 	// Timeout with server timeout. Used by http client side.
-	Timeout time.Duration `json:"timeout" mapstructure:"timeout"`
+	Timeout time.Duration `json:"timeout" mapstructure:"timeout" validate:"min=0"`
+
+	// ReadTimeout is the maximum duration for reading the entire request,
+	// including the body. Zero means Timeout is used.
+	ReadTimeout time.Duration `json:"read-timeout" mapstructure:"read-timeout" validate:"min=0"`
+
+	// WriteTimeout is the maximum duration before timing out writes of the
+	// response. Zero means Timeout is used.
+	WriteTimeout time.Duration `json:"write-timeout" mapstructure:"write-timeout" validate:"min=0"`
+
+	// IdleTimeout is the maximum amount of time to wait for the next request
+	// when keep-alives are enabled. Zero means ReadTimeout is used.
+	IdleTimeout time.Duration `json:"idle-timeout" mapstructure:"idle-timeout" validate:"min=0"`
 }
 
 // NewHTTPOptions creates a HTTPOptions object with default parameters.
 func NewHTTPOptions() *HTTPOptions {
 	return &HTTPOptions{
-		Network: "tcp",
-		Addr:    "0.0.0.0:38443",
-		Timeout: 30 * time.Second,
+		Network:      "tcp",
+		Addr:         "0.0.0.0:38443",
+		Timeout:      30 * time.Second,
+		ReadTimeout:  30 * time.Second,
+		WriteTimeout: 30 * time.Second,
+		IdleTimeout:  120 * time.Second,
 	}
 }
 
@@ -38,11 +55,11 @@ func (o *HTTPOptions) Validate() []error {
 
 	errors := []error{}
 
-	if err := ValidateAddress(o.Addr); err != nil {
+	if err := ValidateNetworkAddress(o.Network, o.Addr); err != nil {
 		errors = append(errors, err)
 	}
 
-	return errors
+	return append(errors, validation.ValidateStruct(o, "http")...)
 }
 
 // AddFlags adds flags related to HTTPS server for a specific APIServer to the
@@ -51,6 +68,9 @@ func (o *HTTPOptions) AddFlags(fs *pflag.FlagSet, prefixes ...string) {
 	fs.StringVar(&o.Network, "http.network", o.Network, "Specify the network for the HTTP server.")
 	fs.StringVar(&o.Addr, "http.addr", o.Addr, "Specify the HTTP server bind address and port.")
 	fs.DurationVar(&o.Timeout, "http.timeout", o.Timeout, "Timeout for server connections.")
+	fs.DurationVar(&o.ReadTimeout, "http.read-timeout", o.ReadTimeout, "Maximum duration for reading the entire request, including the body.")
+	fs.DurationVar(&o.WriteTimeout, "http.write-timeout", o.WriteTimeout, "Maximum duration before timing out writes of the response.")
+	fs.DurationVar(&o.IdleTimeout, "http.idle-timeout", o.IdleTimeout, "Maximum amount of time to wait for the next request when keep-alives are enabled.")
 }
 
 // Complete fills in any fields not set that are required to have valid data.
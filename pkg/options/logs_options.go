@@ -6,6 +6,8 @@ import (
 	"github.com/jinzhu/copier"
 	"github.com/spf13/pflag"
 	logsapi "k8s.io/component-base/logs/api/v1"
+
+	"chunyu/pkg/util/validation"
 )
 
 var _ IOptions = (*LogsOptions)(nil)
@@ -14,11 +16,11 @@ var _ IOptions = (*LogsOptions)(nil)
 type LogsOptions struct {
 	// Format Flag specifies the structure of log messages.
 	// default value of format is `text`
-	Format string `json:"format,omitempty" mapstructure:"format"`
+	Format string `json:"format,omitempty" mapstructure:"format" validate:"oneof=text json"`
 	// Maximum number of nanoseconds (i.e. 1s = 1000000000) between log
 	// flushes. Ignored if the selected logging backend writes log
 	// messages without buffering.
-	FlushFrequency time.Duration `json:"flush-frequency" mapstructure:"flush-frequency"`
+	FlushFrequency time.Duration `json:"flush-frequency" mapstructure:"flush-frequency" validate:"min=0"`
 	// Verbosity is the threshold that determines which log messages are
 	// logged. Default is zero which logs only the most important
 	// messages. Higher values enable additional messages. Error messages
@@ -46,9 +48,7 @@ func NewLogsOptions() *LogsOptions {
 
 // Validate verifies flags passed to LogsOptions.
 func (o *LogsOptions) Validate() []error {
-	errs := []error{}
-
-	return errs
+	return validation.ValidateStruct(o, "logs")
 }
 
 // AddFlags adds command line flags for the configuration.
@@ -0,0 +1,135 @@
+package options
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/pflag"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+
+	"chunyu/pkg/db"
+	"chunyu/pkg/log"
+	"chunyu/pkg/util/validation"
+)
+
+var _ IOptions = (*MySQLOptions)(nil)
+
+// MySQLOptions defines options for mysql database.
+type MySQLOptions struct {
+	Addr                  string            `json:"addr,omitempty" mapstructure:"addr" validate:"address"`
+	Username              string            `json:"username,omitempty" mapstructure:"username"`
+	Password              string            `json:"-" mapstructure:"password"`
+	Database              string            `json:"database" mapstructure:"database"`
+	MaxIdleConnections    int               `json:"max-idle-connections,omitempty" mapstructure:"max-idle-connections,omitempty" validate:"min=0"`
+	MaxOpenConnections    int               `json:"max-open-connections,omitempty" mapstructure:"max-open-connections" validate:"min=0"`
+	MaxConnectionLifeTime time.Duration     `json:"max-connection-life-time,omitempty" mapstructure:"max-connection-life-time" validate:"min=0"`
+	LogLevel              int               `json:"log-level" mapstructure:"log-level" validate:"min=1,max=4"`
+	SlowThreshold         time.Duration     `json:"slow-threshold" mapstructure:"slow-threshold" validate:"min=0"`
+	Replicas              []string          `json:"replicas,omitempty" mapstructure:"replicas" validate:"address"`
+	ReplicaPolicy         string            `json:"replica-policy,omitempty" mapstructure:"replica-policy"`
+	Charset               string            `json:"charset" mapstructure:"charset"`
+	TimeZone              string            `json:"time-zone" mapstructure:"time-zone"`
+	TLSConfig             string            `json:"tls-config,omitempty" mapstructure:"tls-config"`
+	ReadTimeout           time.Duration     `json:"read-timeout,omitempty" mapstructure:"read-timeout" validate:"min=0"`
+	WriteTimeout          time.Duration     `json:"write-timeout,omitempty" mapstructure:"write-timeout" validate:"min=0"`
+	Params                map[string]string `json:"params,omitempty" mapstructure:"params"`
+}
+
+// NewMySQLOptions create a `zero` value instance.
+func NewMySQLOptions() *MySQLOptions {
+	return &MySQLOptions{
+		Addr:                  "127.0.0.1:3306",
+		Username:              "onex",
+		Password:              "onex(#)666",
+		Database:              "onex",
+		MaxIdleConnections:    100,
+		MaxOpenConnections:    100,
+		MaxConnectionLifeTime: time.Duration(10) * time.Second,
+		LogLevel:              1, // Silent
+		SlowThreshold:         200 * time.Millisecond,
+		Charset:               "utf8mb4",
+		TimeZone:              "Local",
+	}
+}
+
+// Validate verifies flags passed to MySQLOptions.
+func (o *MySQLOptions) Validate() []error {
+	errs := []error{}
+
+	if o.TimeZone != "" {
+		if _, err := time.LoadLocation(o.TimeZone); err != nil {
+			errs = append(errs, fmt.Errorf("mysql.time-zone %q is invalid: %w", o.TimeZone, err))
+		}
+	}
+	if _, err := db.NewReplicaPolicy(o.ReplicaPolicy); err != nil {
+		errs = append(errs, err)
+	}
+
+	return append(errs, validation.ValidateStruct(o, "mysql")...)
+}
+
+// AddFlags adds flags related to mysql storage for a specific APIServer to the specified FlagSet.
+func (o *MySQLOptions) AddFlags(fs *pflag.FlagSet, prefixes ...string) {
+	fs.StringVar(&o.Addr, join(prefixes...)+"mysql.addr", o.Addr, ""+
+		"MySQL service address. If left blank, the following related mysql options will be ignored.")
+	fs.StringVar(&o.Username, join(prefixes...)+"mysql.username", o.Username, "Username for access to mysql service.")
+	fs.StringVar(&o.Password, join(prefixes...)+"mysql.password", o.Password, ""+
+		"Password for access to mysql, should be used pair with password.")
+	fs.StringVar(&o.Database, join(prefixes...)+"mysql.database", o.Database, ""+
+		"Database name for the server to use.")
+	fs.IntVar(&o.MaxIdleConnections, join(prefixes...)+"mysql.max-idle-connections", o.MaxIdleConnections, ""+
+		"Maximum idle connections allowed to connect to mysql.")
+	fs.IntVar(&o.MaxOpenConnections, join(prefixes...)+"mysql.max-open-connections", o.MaxOpenConnections, ""+
+		"Maximum open connections allowed to connect to mysql.")
+	fs.DurationVar(&o.MaxConnectionLifeTime, join(prefixes...)+"mysql.max-connection-life-time", o.MaxConnectionLifeTime, ""+
+		"Maximum connection life time allowed to connect to mysql.")
+	fs.IntVar(&o.LogLevel, join(prefixes...)+"mysql.log-level", o.LogLevel, ""+
+		"Specify gorm log level.")
+	fs.DurationVar(&o.SlowThreshold, join(prefixes...)+"mysql.slow-threshold", o.SlowThreshold, ""+
+		"Queries slower than the threshold are logged as slow queries.")
+	fs.StringSliceVar(&o.Replicas, join(prefixes...)+"mysql.replicas", o.Replicas, ""+
+		"Comma-separated addresses of read replicas. Reads are routed to them, writes and transactions use the primary.")
+	fs.StringVar(&o.ReplicaPolicy, join(prefixes...)+"mysql.replica-policy", o.ReplicaPolicy, ""+
+		"Replica selection policy for reads. Possible values: random, round-robin, least-latency.")
+	fs.StringVar(&o.Charset, join(prefixes...)+"mysql.charset", o.Charset, "Connection character set.")
+	fs.StringVar(&o.TimeZone, join(prefixes...)+"mysql.time-zone", o.TimeZone, ""+
+		"Time zone used to parse DATETIME and TIMESTAMP values, e.g. Local, UTC or Asia/Shanghai.")
+	fs.StringVar(&o.TLSConfig, join(prefixes...)+"mysql.tls-config", o.TLSConfig, ""+
+		"TLS config name: true, false, skip-verify, preferred or a name registered with the mysql driver.")
+	fs.DurationVar(&o.ReadTimeout, join(prefixes...)+"mysql.read-timeout", o.ReadTimeout, "I/O read timeout.")
+	fs.DurationVar(&o.WriteTimeout, join(prefixes...)+"mysql.write-timeout", o.WriteTimeout, "I/O write timeout.")
+	fs.StringToStringVar(&o.Params, join(prefixes...)+"mysql.params", o.Params, ""+
+		"Extra DSN parameters in key=value form, e.g. collation=utf8mb4_general_ci,interpolateParams=true.")
+}
+
+// NewDB create mysql store with the given config.
+func (o *MySQLOptions) NewDB() (*gorm.DB, error) {
+	opts := &db.MySQLOptions{
+		Addr:                  o.Addr,
+		Username:              o.Username,
+		Password:              o.Password,
+		Database:              o.Database,
+		MaxIdleConnections:    o.MaxIdleConnections,
+		MaxOpenConnections:    o.MaxOpenConnections,
+		MaxConnectionLifeTime: o.MaxConnectionLifeTime,
+		Replicas:              o.Replicas,
+		ReplicaPolicy:         o.ReplicaPolicy,
+		Charset:               o.Charset,
+		TimeZone:              o.TimeZone,
+		TLSConfig:             o.TLSConfig,
+		ReadTimeout:           o.ReadTimeout,
+		WriteTimeout:          o.WriteTimeout,
+		Params:                o.Params,
+		SlowThreshold:         o.SlowThreshold,
+		Logger:                log.Default().LogMode(gormlogger.LogLevel(o.LogLevel)),
+	}
+
+	return db.NewMySQL(opts)
+}
+
+// ApplyPoolLimits applies the connection pool limits of o to a database
+// created by NewDB, e.g. after the configuration has been reloaded.
+func (o *MySQLOptions) ApplyPoolLimits(gdb *gorm.DB) error {
+	return db.SetPoolLimits(gdb, o.MaxIdleConnections, o.MaxOpenConnections, o.MaxConnectionLifeTime)
+}
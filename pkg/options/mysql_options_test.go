@@ -0,0 +1,51 @@
+package options
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+)
+
+// assertFlagPerKey asserts that every config key of opts has a flag of the
+// same name under prefix, so that both set the same setting.
+func assertFlagPerKey(t *testing.T, opts any, fs *pflag.FlagSet, prefix string) {
+	t.Helper()
+
+	typ := reflect.TypeOf(opts).Elem()
+	for i := range typ.NumField() {
+		key, _, _ := strings.Cut(typ.Field(i).Tag.Get("mapstructure"), ",")
+		if key == "" || key == "-" {
+			continue
+		}
+		assert.NotNil(t, fs.Lookup(prefix+"."+key), "missing flag for %s.%s", prefix, key)
+	}
+}
+
+func TestMySQLOptions_AddFlags(t *testing.T) {
+	opts := NewMySQLOptions()
+	opts.MaxIdleConnections = 10
+	opts.MaxOpenConnections = 20
+
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	opts.AddFlags(fs)
+
+	assertFlagPerKey(t, opts, fs, "mysql")
+	assert.Equal(t, "10", fs.Lookup("mysql.max-idle-connections").DefValue)
+	assert.Equal(t, "20", fs.Lookup("mysql.max-open-connections").DefValue)
+}
+
+func TestPostgreSQLOptions_AddFlags(t *testing.T) {
+	opts := NewPostgreSQLOptions()
+	opts.MaxIdleConnections = 10
+	opts.MaxOpenConnections = 20
+
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	opts.AddFlags(fs)
+
+	assertFlagPerKey(t, opts, fs, "postgresql")
+	assert.Equal(t, "10", fs.Lookup("postgresql.max-idle-connections").DefValue)
+	assert.Equal(t, "20", fs.Lookup("postgresql.max-open-connections").DefValue)
+}
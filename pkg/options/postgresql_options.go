@@ -9,20 +9,24 @@ import (
 
 	"chunyu/pkg/db"
 	"chunyu/pkg/log"
+	"chunyu/pkg/util/validation"
 )
 
 var _ IOptions = (*PostgreSQLOptions)(nil)
 
 // PostgreSQLOptions defines options for postgresql database.
 type PostgreSQLOptions struct {
-	Addr                  string        `json:"addr,omitempty" mapstructure:"addr"`
+	Addr                  string        `json:"addr,omitempty" mapstructure:"addr" validate:"address"`
 	Username              string        `json:"username,omitempty" mapstructure:"username"`
 	Password              string        `json:"-" mapstructure:"password"`
 	Database              string        `json:"database" mapstructure:"database"`
-	MaxIdleConnections    int           `json:"max-idle-connections,omitempty" mapstructure:"max-idle-connections,omitempty"`
-	MaxOpenConnections    int           `json:"max-open-connections,omitempty" mapstructure:"max-open-connections"`
-	MaxConnectionLifeTime time.Duration `json:"max-connection-life-time,omitempty" mapstructure:"max-connection-life-time"`
-	LogLevel              int           `json:"log-level" mapstructure:"log-level"`
+	MaxIdleConnections    int           `json:"max-idle-connections,omitempty" mapstructure:"max-idle-connections,omitempty" validate:"min=0"`
+	MaxOpenConnections    int           `json:"max-open-connections,omitempty" mapstructure:"max-open-connections" validate:"min=0"`
+	MaxConnectionLifeTime time.Duration `json:"max-connection-life-time,omitempty" mapstructure:"max-connection-life-time" validate:"min=0"`
+	LogLevel              int           `json:"log-level" mapstructure:"log-level" validate:"min=1,max=4"`
+	SlowThreshold         time.Duration `json:"slow-threshold" mapstructure:"slow-threshold" validate:"min=0"`
+	Replicas              []string      `json:"replicas,omitempty" mapstructure:"replicas" validate:"address"`
+	ReplicaPolicy         string        `json:"replica-policy,omitempty" mapstructure:"replica-policy"`
 }
 
 // NewPostgreSQLOptions create a `zero` value instance.
@@ -36,6 +40,7 @@ func NewPostgreSQLOptions() *PostgreSQLOptions {
 		MaxOpenConnections:    100,
 		MaxConnectionLifeTime: time.Duration(10) * time.Second,
 		LogLevel:              1, // Silent
+		SlowThreshold:         200 * time.Millisecond,
 	}
 }
 
@@ -43,7 +48,11 @@ func NewPostgreSQLOptions() *PostgreSQLOptions {
 func (o *PostgreSQLOptions) Validate() []error {
 	errs := []error{}
 
-	return errs
+	if _, err := db.NewReplicaPolicy(o.ReplicaPolicy); err != nil {
+		errs = append(errs, err)
+	}
+
+	return append(errs, validation.ValidateStruct(o, "postgresql")...)
 }
 
 // AddFlags adds flags related to postgresql storage for a specific APIServer to the specified FlagSet.
@@ -55,14 +64,20 @@ func (o *PostgreSQLOptions) AddFlags(fs *pflag.FlagSet, prefixes ...string) {
 		"Password for access to postgresql, should be used pair with password.")
 	fs.StringVar(&o.Database, join(prefixes...)+"postgresql.database", o.Database, ""+
 		"Database name for the server to use.")
-	fs.IntVar(&o.MaxIdleConnections, join(prefixes...)+"postgresql.max-idle-connections", o.MaxOpenConnections, ""+
+	fs.IntVar(&o.MaxIdleConnections, join(prefixes...)+"postgresql.max-idle-connections", o.MaxIdleConnections, ""+
 		"Maximum idle connections allowed to connect to postgresql.")
 	fs.IntVar(&o.MaxOpenConnections, join(prefixes...)+"postgresql.max-open-connections", o.MaxOpenConnections, ""+
 		"Maximum open connections allowed to connect to postgresql.")
 	fs.DurationVar(&o.MaxConnectionLifeTime, join(prefixes...)+"postgresql.max-connection-life-time", o.MaxConnectionLifeTime, ""+
 		"Maximum connection life time allowed to connect to postgresql.")
-	fs.IntVar(&o.LogLevel, join(prefixes...)+"postgresql.log-mode", o.LogLevel, ""+
+	fs.IntVar(&o.LogLevel, join(prefixes...)+"postgresql.log-level", o.LogLevel, ""+
 		"Specify gorm log level.")
+	fs.DurationVar(&o.SlowThreshold, join(prefixes...)+"postgresql.slow-threshold", o.SlowThreshold, ""+
+		"Queries slower than the threshold are logged as slow queries.")
+	fs.StringSliceVar(&o.Replicas, join(prefixes...)+"postgresql.replicas", o.Replicas, ""+
+		"Comma-separated addresses of read replicas. Reads are routed to them, writes and transactions use the primary.")
+	fs.StringVar(&o.ReplicaPolicy, join(prefixes...)+"postgresql.replica-policy", o.ReplicaPolicy, ""+
+		"Replica selection policy for reads. Possible values: random, round-robin, least-latency.")
 }
 
 // NewDB create postgresql store with the given config.
@@ -75,8 +90,17 @@ func (o *PostgreSQLOptions) NewDB() (*gorm.DB, error) {
 		MaxIdleConnections:    o.MaxIdleConnections,
 		MaxOpenConnections:    o.MaxOpenConnections,
 		MaxConnectionLifeTime: o.MaxConnectionLifeTime,
+		Replicas:              o.Replicas,
+		ReplicaPolicy:         o.ReplicaPolicy,
+		SlowThreshold:         o.SlowThreshold,
 		Logger:                log.Default().LogMode(gormlogger.LogLevel(o.LogLevel)),
 	}
 
 	return db.NewPostgreSQL(opts)
 }
+
+// ApplyPoolLimits applies the connection pool limits of o to a database
+// created by NewDB, e.g. after the configuration has been reloaded.
+func (o *PostgreSQLOptions) ApplyPoolLimits(gdb *gorm.DB) error {
+	return db.SetPoolLimits(gdb, o.MaxIdleConnections, o.MaxOpenConnections, o.MaxConnectionLifeTime)
+}
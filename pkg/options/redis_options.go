@@ -0,0 +1,140 @@
+package options
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/pflag"
+
+	"chunyu/pkg/db"
+	"chunyu/pkg/util/validation"
+)
+
+var _ IOptions = (*RedisOptions)(nil)
+
+// RedisOptions defines options for redis database.
+type RedisOptions struct {
+	// Mode is the deployment mode, one of single, sentinel, cluster.
+	Mode string `json:"mode" mapstructure:"mode"`
+	// Addr is the redis address in single mode.
+	Addr string `json:"addr" mapstructure:"addr"`
+	// Addrs are the sentinel addresses in sentinel mode, or the seed nodes in
+	// cluster mode.
+	Addrs []string `json:"addrs" mapstructure:"addrs"`
+	// MasterName is the name of the master monitored by the sentinels.
+	MasterName       string        `json:"master-name" mapstructure:"master-name"`
+	SentinelUsername string        `json:"sentinel-username" mapstructure:"sentinel-username"`
+	SentinelPassword string        `json:"-" mapstructure:"sentinel-password"`
+	Username         string        `json:"username" mapstructure:"username"`
+	Password         string        `json:"-" mapstructure:"password"`
+	Database         int           `json:"database" mapstructure:"database" validate:"min=0"`
+	MaxRetries       int           `json:"max-retries" mapstructure:"max-retries"`
+	MinIdleConns     int           `json:"min-idle-conns" mapstructure:"min-idle-conns" validate:"min=0"`
+	DialTimeout      time.Duration `json:"dial-timeout" mapstructure:"dial-timeout"`
+	ReadTimeout      time.Duration `json:"read-timeout" mapstructure:"read-timeout"`
+	WriteTimeout     time.Duration `json:"write-timeout" mapstructure:"write-timeout"`
+	PoolTimeout      time.Duration `json:"pool-timeout" mapstructure:"pool-timeout"`
+	PoolSize         int           `json:"pool-size" mapstructure:"pool-size" validate:"min=0"`
+}
+
+// NewRedisOptions create a `zero` value instance.
+func NewRedisOptions() *RedisOptions {
+	return &RedisOptions{
+		Mode:         db.RedisModeSingle,
+		Addr:         "127.0.0.1:6379",
+		Database:     0,
+		MaxRetries:   3,
+		MinIdleConns: 0,
+		DialTimeout:  5 * time.Second,
+		ReadTimeout:  3 * time.Second,
+		WriteTimeout: 3 * time.Second,
+		PoolTimeout:  4 * time.Second,
+		PoolSize:     10,
+	}
+}
+
+// Validate verifies flags passed to RedisOptions.
+func (o *RedisOptions) Validate() []error {
+	errs := []error{}
+
+	switch o.Mode {
+	case "", db.RedisModeSingle:
+		if _, _, err := net.SplitHostPort(o.Addr); err != nil {
+			errs = append(errs, fmt.Errorf("redis.addr %q is not in host:port format: %w", o.Addr, err))
+		}
+	case db.RedisModeSentinel:
+		if len(o.Addrs) == 0 {
+			errs = append(errs, fmt.Errorf("redis.addrs must list the sentinel addresses in sentinel mode"))
+		}
+		if o.MasterName == "" {
+			errs = append(errs, fmt.Errorf("redis.master-name must be set in sentinel mode"))
+		}
+	case db.RedisModeCluster:
+		if len(o.Addrs) == 0 {
+			errs = append(errs, fmt.Errorf("redis.addrs must list the seed nodes in cluster mode"))
+		}
+		if o.Database != 0 {
+			errs = append(errs, fmt.Errorf("redis.database must be 0 in cluster mode"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unsupported redis.mode %q, must be one of single, sentinel, cluster", o.Mode))
+	}
+
+	for _, addr := range o.Addrs {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			errs = append(errs, fmt.Errorf("redis.addrs entry %q is not in host:port format: %w", addr, err))
+		}
+	}
+
+	return append(errs, validation.ValidateStruct(o, "redis")...)
+}
+
+// AddFlags adds flags related to redis storage for a specific APIServer to the specified FlagSet.
+func (o *RedisOptions) AddFlags(fs *pflag.FlagSet, prefixes ...string) {
+	fs.StringVar(&o.Mode, join(prefixes...)+"redis.mode", o.Mode, "Redis deployment mode. Possible values: single, sentinel, cluster.")
+	fs.StringVar(&o.Addr, join(prefixes...)+"redis.addr", o.Addr, "Address of your Redis server in single mode.")
+	fs.StringSliceVar(&o.Addrs, join(prefixes...)+"redis.addrs", o.Addrs, ""+
+		"Sentinel addresses in sentinel mode, or seed node addresses in cluster mode.")
+	fs.StringVar(&o.MasterName, join(prefixes...)+"redis.master-name", o.MasterName, "Name of the master monitored by the sentinels.")
+	fs.StringVar(&o.SentinelUsername, join(prefixes...)+"redis.sentinel-username", o.SentinelUsername, "Username for access to the sentinels.")
+	fs.StringVar(&o.SentinelPassword, join(prefixes...)+"redis.sentinel-password", o.SentinelPassword, "Password for access to the sentinels.")
+	fs.StringVar(&o.Username, join(prefixes...)+"redis.username", o.Username, "Username for access to redis service.")
+	fs.StringVar(&o.Password, join(prefixes...)+"redis.password", o.Password, "Optional auth password for redis db.")
+	fs.IntVar(&o.Database, join(prefixes...)+"redis.database", o.Database, ""+
+		"By default, the database is 0. Setting the database is not supported with redis cluster.")
+	fs.IntVar(&o.MaxRetries, join(prefixes...)+"redis.max-retries", o.MaxRetries, "Maximum number of retries before giving up.")
+	fs.IntVar(&o.MinIdleConns, join(prefixes...)+"redis.min-idle-conns", o.MinIdleConns, ""+
+		"Minimum number of idle connections which is useful when establishing new connection is slow.")
+	fs.DurationVar(&o.DialTimeout, join(prefixes...)+"redis.dial-timeout", o.DialTimeout, "Dial timeout for establishing new connections.")
+	fs.DurationVar(&o.ReadTimeout, join(prefixes...)+"redis.read-timeout", o.ReadTimeout, "Timeout for socket reads.")
+	fs.DurationVar(&o.WriteTimeout, join(prefixes...)+"redis.write-timeout", o.WriteTimeout, "Timeout for socket writes.")
+	fs.DurationVar(&o.PoolTimeout, join(prefixes...)+"redis.pool-timeout", o.PoolTimeout, ""+
+		"Amount of time client waits for connection if all connections are busy before returning an error.")
+	fs.IntVar(&o.PoolSize, join(prefixes...)+"redis.pool-size", o.PoolSize, "Maximum number of socket connections.")
+}
+
+// NewClient create a redis client for the configured deployment mode.
+func (o *RedisOptions) NewClient() (redis.UniversalClient, error) {
+	opts := &db.RedisOptions{
+		Mode:             o.Mode,
+		Addr:             o.Addr,
+		Addrs:            o.Addrs,
+		MasterName:       o.MasterName,
+		SentinelUsername: o.SentinelUsername,
+		SentinelPassword: o.SentinelPassword,
+		Username:         o.Username,
+		Password:         o.Password,
+		Database:         o.Database,
+		MaxRetries:       o.MaxRetries,
+		MinIdleConns:     o.MinIdleConns,
+		DialTimeout:      o.DialTimeout,
+		ReadTimeout:      o.ReadTimeout,
+		WriteTimeout:     o.WriteTimeout,
+		PoolTimeout:      o.PoolTimeout,
+		PoolSize:         o.PoolSize,
+	}
+
+	return db.NewRedis(opts)
+}
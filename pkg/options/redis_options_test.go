@@ -0,0 +1,47 @@
+package options
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"chunyu/pkg/db"
+)
+
+func TestRedisOptions_Validate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(o *RedisOptions)
+		errs   int
+	}{
+		{"single", func(o *RedisOptions) {}, 0},
+		{"single without port", func(o *RedisOptions) { o.Addr = "127.0.0.1" }, 1},
+		{"sentinel", func(o *RedisOptions) {
+			o.Mode = db.RedisModeSentinel
+			o.Addrs = []string{"10.0.0.1:26379", "10.0.0.2:26379"}
+			o.MasterName = "mymaster"
+		}, 0},
+		{"sentinel without master", func(o *RedisOptions) {
+			o.Mode = db.RedisModeSentinel
+			o.Addrs = []string{"10.0.0.1:26379"}
+		}, 1},
+		{"cluster", func(o *RedisOptions) {
+			o.Mode = db.RedisModeCluster
+			o.Addrs = []string{"10.0.0.1:6379", "10.0.0.2:6379"}
+		}, 0},
+		{"cluster with database", func(o *RedisOptions) {
+			o.Mode = db.RedisModeCluster
+			o.Addrs = []string{"10.0.0.1:6379"}
+			o.Database = 1
+		}, 1},
+		{"unknown mode", func(o *RedisOptions) { o.Mode = "ring" }, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := NewRedisOptions()
+			tt.modify(o)
+			assert.Len(t, o.Validate(), tt.errs)
+		})
+	}
+}
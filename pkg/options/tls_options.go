@@ -0,0 +1,366 @@
+package options
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"chunyu/pkg/util/validation"
+)
+
+var _ IOptions = (*TLSOptions)(nil)
+
+// Supported values of TLSOptions.ClientAuth.
+const (
+	ClientAuthNone             = "none"
+	ClientAuthRequest          = "request"
+	ClientAuthRequire          = "require"
+	ClientAuthVerifyIfGiven    = "verify-if-given"
+	ClientAuthRequireAndVerify = "require-and-verify"
+)
+
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	ClientAuthNone:             tls.NoClientCert,
+	ClientAuthRequest:          tls.RequestClientCert,
+	ClientAuthRequire:          tls.RequireAnyClientCert,
+	ClientAuthVerifyIfGiven:    tls.VerifyClientCertIfGiven,
+	ClientAuthRequireAndVerify: tls.RequireAndVerifyClientCert,
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// TLSOptions contains configuration items related to TLS for HTTP and gRPC
+// servers. Certificates are read from disk and reloaded on change, so they can
+// be rotated without restarting the process.
+type TLSOptions struct {
+	// UseTLS enables TLS. When false all other fields are ignored.
+	UseTLS bool `json:"use-tls" mapstructure:"use-tls"`
+
+	// CertFile is the path to the PEM encoded server certificate.
+	CertFile string `json:"cert-file" mapstructure:"cert-file" validate:"file"`
+
+	// KeyFile is the path to the PEM encoded server private key.
+	KeyFile string `json:"key-file" mapstructure:"key-file" validate:"file"`
+
+	// CAFile is the path to the PEM encoded CA bundle used to verify client
+	// certificates.
+	CAFile string `json:"ca-file" mapstructure:"ca-file" validate:"file"`
+
+	// MinVersion is the minimum accepted TLS version, one of 1.0, 1.1, 1.2, 1.3.
+	MinVersion string `json:"min-version" mapstructure:"min-version"`
+
+	// CipherSuites restricts the cipher suites used for TLS 1.2 and below.
+	// Empty means the Go defaults.
+	CipherSuites []string `json:"cipher-suites" mapstructure:"cipher-suites"`
+
+	// ClientAuth is the client certificate policy, one of none, request,
+	// require, verify-if-given, require-and-verify.
+	ClientAuth string `json:"client-auth" mapstructure:"client-auth"`
+}
+
+// NewTLSOptions creates a TLSOptions object with default parameters.
+func NewTLSOptions() *TLSOptions {
+	return &TLSOptions{
+		UseTLS:     false,
+		MinVersion: "1.2",
+		ClientAuth: ClientAuthNone,
+	}
+}
+
+// Validate is used to parse and validate the parameters entered by the user at
+// the command line when the program starts.
+func (o *TLSOptions) Validate() []error {
+	if o == nil || !o.UseTLS {
+		return nil
+	}
+
+	errs := []error{}
+
+	if o.CertFile == "" || o.KeyFile == "" {
+		errs = append(errs, fmt.Errorf("tls.cert-file and tls.key-file must be set when TLS is enabled"))
+	}
+	errs = append(errs, validation.ValidateStruct(o, "tls")...)
+
+	if _, err := o.minVersion(); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := o.cipherSuites(); err != nil {
+		errs = append(errs, err)
+	}
+
+	clientAuth, err := o.clientAuth()
+	if err != nil {
+		errs = append(errs, err)
+	} else if clientAuth >= tls.VerifyClientCertIfGiven && o.CAFile == "" {
+		errs = append(errs, fmt.Errorf("tls.ca-file must be set when tls.client-auth is %q", o.ClientAuth))
+	}
+
+	return errs
+}
+
+// AddFlags adds flags related to TLS to the specified FlagSet.
+func (o *TLSOptions) AddFlags(fs *pflag.FlagSet, prefixes ...string) {
+	fs.BoolVar(&o.UseTLS, join(prefixes...)+"tls.use-tls", o.UseTLS, "Use TLS transport.")
+	fs.StringVar(&o.CertFile, join(prefixes...)+"tls.cert-file", o.CertFile, "Path to the PEM encoded server certificate.")
+	fs.StringVar(&o.KeyFile, join(prefixes...)+"tls.key-file", o.KeyFile, "Path to the PEM encoded server private key.")
+	fs.StringVar(&o.CAFile, join(prefixes...)+"tls.ca-file", o.CAFile, "Path to the PEM encoded CA bundle used to verify client certificates.")
+	fs.StringVar(&o.MinVersion, join(prefixes...)+"tls.min-version", o.MinVersion, "Minimum TLS version supported. Possible values: 1.0, 1.1, 1.2, 1.3.")
+	fs.StringSliceVar(&o.CipherSuites, join(prefixes...)+"tls.cipher-suites", o.CipherSuites, ""+
+		"Comma-separated list of cipher suites for TLS 1.2 and below. If omitted, the default Go cipher suites will be used.")
+	fs.StringVar(&o.ClientAuth, join(prefixes...)+"tls.client-auth", o.ClientAuth, ""+
+		"Client certificate policy. Possible values: none, request, require, verify-if-given, require-and-verify.")
+}
+
+// TLSConfig returns a server side *tls.Config built from the options, or nil
+// if TLS is disabled. During handshakes the certificate, key and CA files are
+// checked for changes at most once per second and reloaded when modified. A
+// failed reload keeps the previously loaded files in use.
+//
+// The returned config can be passed to server.WithTLSConfig for HTTP servers
+// and server.WithGRPCTLSConfig for gRPC servers.
+func (o *TLSOptions) TLSConfig() (*tls.Config, error) {
+	if o == nil || !o.UseTLS {
+		return nil, nil
+	}
+
+	minVersion, err := o.minVersion()
+	if err != nil {
+		return nil, err
+	}
+	cipherSuites, err := o.cipherSuites()
+	if err != nil {
+		return nil, err
+	}
+	clientAuth, err := o.clientAuth()
+	if err != nil {
+		return nil, err
+	}
+
+	r := &certReloader{certFile: o.CertFile, keyFile: o.KeyFile, caFile: o.CAFile}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+
+	base := &tls.Config{
+		MinVersion:   minVersion,
+		CipherSuites: cipherSuites,
+		ClientAuth:   clientAuth,
+		// Advertise both protocols so that the per-handshake config returned
+		// by GetConfigForClient keeps ALPN working for HTTP/2 and gRPC.
+		NextProtos: []string{"h2", "http/1.1"},
+	}
+
+	cfg := base.Clone()
+	cfg.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		r.reloadIfChanged()
+
+		cert, pool := r.get()
+		c := base.Clone()
+		c.Certificates = []tls.Certificate{*cert}
+		c.ClientCAs = pool
+		return c, nil
+	}
+	// GetCertificate is only a fallback for callers that bypass
+	// GetConfigForClient; it also satisfies http.Server.ServeTLS which requires
+	// a certificate source to be set.
+	cfg.GetCertificate = func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+		r.reloadIfChanged()
+
+		cert, _ := r.get()
+		return cert, nil
+	}
+
+	return cfg, nil
+}
+
+func (o *TLSOptions) minVersion() (uint16, error) {
+	if o.MinVersion == "" {
+		return tls.VersionTLS12, nil
+	}
+
+	v, ok := tlsVersions[o.MinVersion]
+	if !ok {
+		return 0, fmt.Errorf("unsupported tls.min-version %q, must be one of 1.0, 1.1, 1.2, 1.3", o.MinVersion)
+	}
+
+	return v, nil
+}
+
+func (o *TLSOptions) cipherSuites() ([]uint16, error) {
+	if len(o.CipherSuites) == 0 {
+		return nil, nil
+	}
+
+	known := make(map[string]uint16)
+	for _, cs := range tls.CipherSuites() {
+		known[cs.Name] = cs.ID
+	}
+
+	ids := make([]uint16, 0, len(o.CipherSuites))
+	for _, name := range o.CipherSuites {
+		id, ok := known[strings.TrimSpace(name)]
+		if !ok {
+			return nil, fmt.Errorf("unsupported or insecure cipher suite %q", name)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+func (o *TLSOptions) clientAuth() (tls.ClientAuthType, error) {
+	if o.ClientAuth == "" {
+		return tls.NoClientCert, nil
+	}
+
+	t, ok := clientAuthTypes[o.ClientAuth]
+	if !ok {
+		names := make([]string, 0, len(clientAuthTypes))
+		for name := range clientAuthTypes {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		return 0, fmt.Errorf("unsupported tls.client-auth %q, must be one of %s", o.ClientAuth, strings.Join(names, ", "))
+	}
+
+	return t, nil
+}
+
+// certReloader holds the key pair and CA pool loaded from disk and reloads
+// them when the modification time of any of the files changes.
+type certReloader struct {
+	certFile string
+	keyFile  string
+	caFile   string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	pool    *x509.CertPool
+	modTime map[string]time.Time
+
+	// checkMu guards the fields below. It is only acquired with TryLock, so
+	// handshakes never wait for another handshake checking the files.
+	checkMu   sync.Mutex
+	lastCheck time.Time
+	// failedModTime holds the modification times of the files the last
+	// reload failed with, and statErr the last stat error, so that a failure
+	// is logged once until the files change again.
+	failedModTime map[string]time.Time
+	statErr       string
+}
+
+// certCheckInterval is the minimum interval between two checks of the
+// modification times of the files.
+var certCheckInterval = time.Second
+
+// load reads all files unconditionally.
+func (r *certReloader) load() error {
+	modTime, err := r.stat()
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load key pair: %w", err)
+	}
+
+	var pool *x509.CertPool
+	if r.caFile != "" {
+		pem, err := os.ReadFile(r.caFile)
+		if err != nil {
+			return fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no valid certificates found in CA file %q", r.caFile)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.cert = &cert
+	r.pool = pool
+	r.modTime = modTime
+
+	return nil
+}
+
+// reloadIfChanged reloads the files if any of them was modified since the
+// last successful load. The files are checked at most once per
+// certCheckInterval.
+func (r *certReloader) reloadIfChanged() {
+	if !r.checkMu.TryLock() {
+		return
+	}
+	defer r.checkMu.Unlock()
+
+	now := time.Now()
+	if now.Sub(r.lastCheck) < certCheckInterval {
+		return
+	}
+	r.lastCheck = now
+
+	modTime, err := r.stat()
+	if err != nil {
+		if err.Error() != r.statErr {
+			r.statErr = err.Error()
+			slog.Warn("Failed to stat TLS files, keeping current certificates", "err", err)
+		}
+		return
+	}
+	r.statErr = ""
+
+	r.mu.RLock()
+	changed := !maps.Equal(modTime, r.modTime)
+	r.mu.RUnlock()
+	if !changed || maps.Equal(modTime, r.failedModTime) {
+		return
+	}
+
+	if err := r.load(); err != nil {
+		r.failedModTime = modTime
+		slog.Warn("Failed to reload TLS files, keeping current certificates", "err", err)
+		return
+	}
+	r.failedModTime = nil
+	slog.Info("Reloaded TLS certificates", "cert", r.certFile, "key", r.keyFile, "ca", r.caFile)
+}
+
+func (r *certReloader) get() (*tls.Certificate, *x509.CertPool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.cert, r.pool
+}
+
+func (r *certReloader) stat() (map[string]time.Time, error) {
+	modTime := make(map[string]time.Time, 3)
+	for _, file := range []string{r.certFile, r.keyFile, r.caFile} {
+		if file == "" {
+			continue
+		}
+		fi, err := os.Stat(file)
+		if err != nil {
+			return nil, err
+		}
+		modTime[file] = fi.ModTime()
+	}
+
+	return modTime, nil
+}
@@ -0,0 +1,233 @@
+package options
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"log/slog"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// newTestCert issues a certificate signed by parent, or a self-signed CA if
+// parent is nil.
+func newTestCert(t *testing.T, serial int64, parent *testCert) *testCert {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "chunyu-test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	signer, signerKey := tmpl, key
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return &testCert{cert: cert, key: key}
+}
+
+func (c *testCert) write(t *testing.T, certFile, keyFile string) {
+	t.Helper()
+
+	keyDER, err := x509.MarshalECPrivateKey(c.key)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.cert.Raw}), 0o600))
+	if keyFile != "" {
+		require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	}
+}
+
+func (c *testCert) tlsCertificate() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.cert.Raw}, PrivateKey: c.key}
+}
+
+// handshake serves a single TLS connection with cfg and returns the serial
+// number of the certificate presented by the server.
+func handshake(t *testing.T, cfg *tls.Config, client *tls.Config) (*big.Int, error) {
+	t.Helper()
+
+	lis, err := tls.Listen("tcp", "127.0.0.1:0", cfg)
+	require.NoError(t, err)
+	defer lis.Close()
+
+	go func() {
+		conn, err := lis.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_ = conn.(*tls.Conn).Handshake()
+		_, _ = conn.Read(make([]byte, 1))
+	}()
+
+	conn, err := tls.Dial("tcp", lis.Addr().String(), client)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	// With TLS 1.3 client certificate errors are only reported on read.
+	_ = conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if _, err := conn.Read(make([]byte, 1)); err != nil {
+		if ne, ok := err.(net.Error); !ok || !ne.Timeout() {
+			return nil, err
+		}
+	}
+
+	return conn.ConnectionState().PeerCertificates[0].SerialNumber, nil
+}
+
+// checkCertsEveryTime disables the rate limit of the certificate checks.
+func checkCertsEveryTime(t *testing.T) {
+	t.Helper()
+
+	old := certCheckInterval
+	certCheckInterval = 0
+	t.Cleanup(func() { certCheckInterval = old })
+}
+
+func TestTLSOptions_MutualTLSAndReload(t *testing.T) {
+	checkCertsEveryTime(t)
+
+	dir := t.TempDir()
+	ca := newTestCert(t, 1, nil)
+	server := newTestCert(t, 2, ca)
+	client := newTestCert(t, 3, ca)
+
+	opts := NewTLSOptions()
+	opts.UseTLS = true
+	opts.CertFile = filepath.Join(dir, "tls.crt")
+	opts.KeyFile = filepath.Join(dir, "tls.key")
+	opts.CAFile = filepath.Join(dir, "ca.crt")
+	opts.ClientAuth = ClientAuthRequireAndVerify
+
+	server.write(t, opts.CertFile, opts.KeyFile)
+	ca.write(t, opts.CAFile, "")
+	require.Empty(t, opts.Validate())
+
+	cfg, err := opts.TLSConfig()
+	require.NoError(t, err)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	clientCfg := &tls.Config{RootCAs: roots, Certificates: []tls.Certificate{client.tlsCertificate()}}
+
+	serial, err := handshake(t, cfg, clientCfg)
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, serial.Int64())
+
+	// Without a client certificate the handshake must fail.
+	_, err = handshake(t, cfg, &tls.Config{RootCAs: roots})
+	assert.Error(t, err)
+
+	// Rotate the server certificate on disk; the next handshake picks it up.
+	rotated := newTestCert(t, 4, ca)
+	rotated.write(t, opts.CertFile, opts.KeyFile)
+	future := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(opts.CertFile, future, future))
+
+	serial, err = handshake(t, cfg, clientCfg)
+	require.NoError(t, err)
+	assert.EqualValues(t, 4, serial.Int64())
+}
+
+func TestCertReloader_FailedReload(t *testing.T) {
+	checkCertsEveryTime(t)
+
+	var logs bytes.Buffer
+	old := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(old) })
+
+	dir := t.TempDir()
+	r := &certReloader{certFile: filepath.Join(dir, "tls.crt"), keyFile: filepath.Join(dir, "tls.key")}
+	newTestCert(t, 1, nil).write(t, r.certFile, r.keyFile)
+	require.NoError(t, r.load())
+
+	// A half written certificate is reported once, however many handshakes
+	// happen until the file changes again.
+	touch := func(d time.Duration) {
+		ts := time.Now().Add(d)
+		require.NoError(t, os.Chtimes(r.certFile, ts, ts))
+	}
+	require.NoError(t, os.WriteFile(r.certFile, []byte("-----BEGIN CERTIFICATE-----"), 0o600))
+	touch(time.Minute)
+	for range 3 {
+		r.reloadIfChanged()
+	}
+	assert.Equal(t, 1, strings.Count(logs.String(), "Failed to reload TLS files"))
+
+	cert, _ := r.get()
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, leaf.SerialNumber.Int64(), "the current certificate must be kept")
+
+	// Once the rotation completes the new certificate is loaded.
+	newTestCert(t, 2, nil).write(t, r.certFile, r.keyFile)
+	touch(2 * time.Minute)
+	r.reloadIfChanged()
+	cert, _ = r.get()
+	leaf, err = x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, leaf.SerialNumber.Int64())
+
+	// The files are not checked again within certCheckInterval.
+	certCheckInterval = time.Hour
+	newTestCert(t, 3, nil).write(t, r.certFile, r.keyFile)
+	touch(3 * time.Minute)
+	r.reloadIfChanged()
+	r.reloadIfChanged()
+	cert, _ = r.get()
+	leaf, err = x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, leaf.SerialNumber.Int64())
+}
+
+func TestTLSOptions_Validate(t *testing.T) {
+	opts := NewTLSOptions()
+	assert.Empty(t, opts.Validate())
+
+	opts.UseTLS = true
+	opts.MinVersion = "1.4"
+	opts.CipherSuites = []string{"TLS_RSA_WITH_RC4_128_SHA"}
+	opts.ClientAuth = ClientAuthRequireAndVerify
+
+	errs := opts.Validate()
+	assert.Len(t, errs, 4)
+}
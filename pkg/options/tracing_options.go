@@ -0,0 +1,84 @@
+package options
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/pflag"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+
+	"chunyu/pkg/tracing"
+	"chunyu/pkg/util/validation"
+)
+
+var _ IOptions = (*TracingOptions)(nil)
+
+// TracingOptions defines options for OpenTelemetry tracing.
+type TracingOptions struct {
+	// Exporter is one of otlp, stdout, file, none.
+	Exporter string `json:"exporter" mapstructure:"exporter" validate:"oneof=otlp stdout file none"`
+	// Endpoint is the host:port of the OTLP collector, only used by the otlp exporter.
+	Endpoint string            `json:"endpoint" mapstructure:"endpoint"`
+	Insecure bool              `json:"insecure" mapstructure:"insecure"`
+	Headers  map[string]string `json:"-" mapstructure:"headers"`
+	// File is the path spans are written to with the file exporter.
+	File        string  `json:"file" mapstructure:"file"`
+	ServiceName string  `json:"service-name" mapstructure:"service-name"`
+	SampleRatio float64 `json:"sample-ratio" mapstructure:"sample-ratio" validate:"min=0,max=1"`
+}
+
+// NewTracingOptions create a `zero` value instance.
+func NewTracingOptions() *TracingOptions {
+	return &TracingOptions{
+		Exporter:    tracing.ExporterNone,
+		Endpoint:    "127.0.0.1:4317",
+		Insecure:    true,
+		ServiceName: "chunyu",
+		SampleRatio: 1,
+	}
+}
+
+// Validate verifies flags passed to TracingOptions.
+func (o *TracingOptions) Validate() []error {
+	errs := validation.ValidateStruct(o, "tracing")
+	switch o.Exporter {
+	case tracing.ExporterOTLP:
+		// The endpoint is only validated when it is used.
+		endpoint := struct {
+			Endpoint string `mapstructure:"endpoint" validate:"required,address"`
+		}{o.Endpoint}
+		errs = append(errs, validation.ValidateStruct(endpoint, "tracing")...)
+	case tracing.ExporterFile:
+		if o.File == "" {
+			errs = append(errs, fmt.Errorf("tracing.file must be set with the file exporter"))
+		}
+	}
+
+	return errs
+}
+
+// AddFlags adds flags related to tracing to the specified FlagSet.
+func (o *TracingOptions) AddFlags(fs *pflag.FlagSet, prefixes ...string) {
+	fs.StringVar(&o.Exporter, join(prefixes...)+"tracing.exporter", o.Exporter, "Span exporter. Possible values: otlp, stdout, file, none.")
+	fs.StringVar(&o.Endpoint, join(prefixes...)+"tracing.endpoint", o.Endpoint, "Address of the OTLP gRPC collector.")
+	fs.BoolVar(&o.Insecure, join(prefixes...)+"tracing.insecure", o.Insecure, "Connect to the OTLP collector without TLS.")
+	fs.StringToStringVar(&o.Headers, join(prefixes...)+"tracing.headers", o.Headers, "Headers sent with every OTLP export, e.g. authorization=token.")
+	fs.StringVar(&o.File, join(prefixes...)+"tracing.file", o.File, "File spans are appended to with the file exporter.")
+	fs.StringVar(&o.ServiceName, join(prefixes...)+"tracing.service-name", o.ServiceName, "Service name reported in the spans.")
+	fs.Float64Var(&o.SampleRatio, join(prefixes...)+"tracing.sample-ratio", o.SampleRatio, "Ratio of traces sampled, from 0 to 1.")
+}
+
+// NewTracerProvider creates the tracer provider and installs it as the global
+// provider. It must be shut down on exit to flush the pending spans.
+func (o *TracingOptions) NewTracerProvider(ctx context.Context, version string) (*sdktrace.TracerProvider, error) {
+	return tracing.NewTracerProvider(ctx, &tracing.Options{
+		Exporter:       o.Exporter,
+		Endpoint:       o.Endpoint,
+		Insecure:       o.Insecure,
+		Headers:        o.Headers,
+		File:           o.File,
+		ServiceName:    o.ServiceName,
+		SampleRatio:    o.SampleRatio,
+		ServiceVersion: version,
+	})
+}
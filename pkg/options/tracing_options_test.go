@@ -0,0 +1,34 @@
+package options
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"chunyu/pkg/tracing"
+)
+
+func TestTracingOptions_Validate(t *testing.T) {
+	o := NewTracingOptions()
+	assert.Empty(t, o.Validate())
+
+	// The endpoint is only used, and validated, by the otlp exporter.
+	o.Endpoint = "not an address"
+	for _, exporter := range []string{tracing.ExporterNone, tracing.ExporterStdout} {
+		o.Exporter = exporter
+		assert.Empty(t, o.Validate(), exporter)
+	}
+
+	o.Exporter = tracing.ExporterOTLP
+	errs := o.Validate()
+	assert.Len(t, errs, 1)
+	assert.ErrorContains(t, errs[0], "tracing.endpoint")
+
+	o.Endpoint = ""
+	assert.Len(t, o.Validate(), 1)
+
+	o.Exporter = tracing.ExporterFile
+	assert.Len(t, o.Validate(), 1)
+	o.File = "spans.json"
+	assert.Empty(t, o.Validate())
+}
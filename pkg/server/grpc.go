@@ -0,0 +1,184 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"log/slog"
+	"net"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+
+	"chunyu/pkg/options"
+)
+
+var _ Server = (*GRPCServer)(nil)
+
+// GRPCServer is a gRPC server built from options.GRPCOptions.
+type GRPCServer struct {
+	opts   *options.GRPCOptions
+	srv    *grpc.Server
+	health *health.Server
+	lis    net.Listener
+	errCh  chan error
+
+	unaryInterceptors  []grpc.UnaryServerInterceptor
+	streamInterceptors []grpc.StreamServerInterceptor
+	serverOptions      []grpc.ServerOption
+}
+
+// GRPCServerOption defines optional parameters for initializing the gRPC server.
+type GRPCServerOption func(*GRPCServer)
+
+// WithUnaryInterceptors appends unary interceptors. They run after the
+// built-in recovery, error conversion and timeout interceptors.
+func WithUnaryInterceptors(interceptors ...grpc.UnaryServerInterceptor) GRPCServerOption {
+	return func(s *GRPCServer) {
+		s.unaryInterceptors = append(s.unaryInterceptors, interceptors...)
+	}
+}
+
+// WithStreamInterceptors appends stream interceptors. They run after the
+// built-in recovery, error conversion and timeout interceptors.
+func WithStreamInterceptors(interceptors ...grpc.StreamServerInterceptor) GRPCServerOption {
+	return func(s *GRPCServer) {
+		s.streamInterceptors = append(s.streamInterceptors, interceptors...)
+	}
+}
+
+// WithGRPCServerOptions appends raw grpc.ServerOption values.
+func WithGRPCServerOptions(opts ...grpc.ServerOption) GRPCServerOption {
+	return func(s *GRPCServer) {
+		s.serverOptions = append(s.serverOptions, opts...)
+	}
+}
+
+// WithGRPCTLSConfig serves gRPC over TLS using the given config, typically
+// obtained from options.TLSOptions.TLSConfig. A nil config leaves TLS disabled.
+func WithGRPCTLSConfig(cfg *tls.Config) GRPCServerOption {
+	return func(s *GRPCServer) {
+		if cfg != nil {
+			s.serverOptions = append(s.serverOptions, grpc.Creds(credentials.NewTLS(cfg)))
+		}
+	}
+}
+
+// NewGRPCServer creates a gRPC server from the given options.
+// The server traces calls, propagates request IDs and writes access logs,
+// records call metrics, recovers panics into errorsx.ErrInternal, converts
+// returned *errorsx.ErrorX values into gRPC status errors, enforces
+// opts.Timeout as the per-call deadline, and registers the gRPC health and
+// reflection services.
+func NewGRPCServer(opts *options.GRPCOptions, serverOpts ...GRPCServerOption) *GRPCServer {
+	s := &GRPCServer{
+		opts:   opts,
+		health: health.NewServer(),
+		errCh:  make(chan error, 1),
+	}
+
+	for _, o := range serverOpts {
+		o(s)
+	}
+
+	unary := append([]grpc.UnaryServerInterceptor{
+		UnaryTracingInterceptor(),
+		UnaryRequestContextInterceptor(),
+		UnaryMetricsInterceptor(),
+		UnaryRecoveryInterceptor(),
+		UnaryErrorInterceptor(),
+		UnaryTimeoutInterceptor(opts.Timeout),
+	}, s.unaryInterceptors...)
+	stream := append([]grpc.StreamServerInterceptor{
+		StreamTracingInterceptor(),
+		StreamRequestContextInterceptor(),
+		StreamMetricsInterceptor(),
+		StreamRecoveryInterceptor(),
+		StreamErrorInterceptor(),
+		StreamTimeoutInterceptor(opts.Timeout),
+	}, s.streamInterceptors...)
+
+	grpcOpts := append([]grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(unary...),
+		grpc.ChainStreamInterceptor(stream...),
+	}, s.serverOptions...)
+
+	s.srv = grpc.NewServer(grpcOpts...)
+	healthpb.RegisterHealthServer(s.srv, s.health)
+	reflection.Register(s.srv)
+
+	return s
+}
+
+// GRPCServer returns the underlying *grpc.Server used to register services.
+func (s *GRPCServer) GRPCServer() *grpc.Server {
+	return s.srv
+}
+
+// Health returns the health server, which can be used to report the serving
+// status of individual services.
+func (s *GRPCServer) Health() *health.Server {
+	return s.health
+}
+
+// Addr returns the address the server is listening on. It is only valid after
+// Start returned successfully.
+func (s *GRPCServer) Addr() net.Addr {
+	if s.lis == nil {
+		return nil
+	}
+	return s.lis.Addr()
+}
+
+// Start listens on the configured network and address and serves requests in
+// the background.
+func (s *GRPCServer) Start(ctx context.Context) error {
+	lis, err := listen(s.opts.Network, s.opts.Addr)
+	if err != nil {
+		return err
+	}
+	s.lis = lis
+
+	s.health.Resume()
+
+	slog.InfoContext(ctx, "Starting gRPC server", "network", lis.Addr().Network(), "addr", lis.Addr().String())
+	go func() {
+		if err := s.srv.Serve(lis); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+			slog.Error("gRPC server stopped unexpectedly", "err", err)
+			s.errCh <- err
+		}
+	}()
+
+	return nil
+}
+
+// Stop marks all services as not serving and gracefully stops the server. If
+// ctx expires before all pending RPCs are finished, the server is stopped
+// forcefully.
+func (s *GRPCServer) Stop(ctx context.Context) error {
+	slog.InfoContext(ctx, "Shutting down gRPC server")
+	s.health.Shutdown()
+
+	done := make(chan struct{})
+	go func() {
+		s.srv.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.srv.Stop()
+		return ctx.Err()
+	}
+}
+
+// Run starts the server and blocks until ctx is cancelled, then shuts the
+// server down gracefully.
+func (s *GRPCServer) Run(ctx context.Context) error {
+	return run(ctx, s, s.errCh)
+}
@@ -0,0 +1,253 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"chunyu/pkg/core"
+	"chunyu/pkg/errorsx"
+	"chunyu/pkg/metrics"
+	"chunyu/pkg/tracing"
+)
+
+// UnaryErrorInterceptor converts errors returned by handlers into gRPC status
+// errors. *errorsx.ErrorX values (including wrapped ones) are converted via
+// GRPCStatus so that the reason and metadata reach the client. The cause chain
+// and stack of the error are logged, but never sent to the client. Errors
+// declared in errorsx.DefaultRegistry get an errdetails.LocalizedMessage in the
+// language negotiated from the accept-language metadata.
+func UnaryErrorInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		resp, err := handler(ctx, req)
+		core.LogError(ctx, err)
+		return resp, toStatusError(ctx, err)
+	}
+}
+
+// StreamErrorInterceptor is the stream counterpart of UnaryErrorInterceptor.
+func StreamErrorInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		err := handler(srv, ss)
+		core.LogError(ss.Context(), err)
+		return toStatusError(ss.Context(), err)
+	}
+}
+
+// UnaryRecoveryInterceptor recovers panics in handlers and returns
+// errorsx.ErrInternal instead of crashing the process.
+func UnaryRecoveryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = recovered(ctx, info.FullMethod, r)
+			}
+		}()
+
+		return handler(ctx, req)
+	}
+}
+
+// StreamRecoveryInterceptor is the stream counterpart of UnaryRecoveryInterceptor.
+func StreamRecoveryInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = recovered(ss.Context(), info.FullMethod, r)
+			}
+		}()
+
+		return handler(srv, ss)
+	}
+}
+
+// UnaryTimeoutInterceptor enforces timeout as the deadline of every call. A
+// shorter deadline set by the client is kept. Zero disables the interceptor.
+func UnaryTimeoutInterceptor(timeout time.Duration) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if timeout <= 0 {
+			return handler(ctx, req)
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		return handler(ctx, req)
+	}
+}
+
+// StreamTimeoutInterceptor is the stream counterpart of UnaryTimeoutInterceptor.
+func StreamTimeoutInterceptor(timeout time.Duration) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if timeout <= 0 {
+			return handler(srv, ss)
+		}
+
+		ctx, cancel := context.WithTimeout(ss.Context(), timeout)
+		defer cancel()
+
+		return handler(srv, &wrappedStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// wrappedStream overrides the context of a grpc.ServerStream.
+type wrappedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context returns the overridden context.
+func (w *wrappedStream) Context() context.Context {
+	return w.ctx
+}
+
+// toStatusError converts err to a gRPC status error.
+func toStatusError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var errx *errorsx.ErrorX
+	if errorsx.As(err, &errx) {
+		// The message is left untouched, the localized message is sent as a
+		// detail for the clients displaying it to users.
+		if localized, locale := errorsx.Localize(errx, acceptLanguages(ctx)...); locale != "" {
+			errx = errx.WithLocalizedMessage(locale, localized.Message)
+		}
+		return errx.GRPCStatus().Err()
+	}
+
+	return err
+}
+
+// acceptLanguages returns the language preferences of the client, sent in the
+// accept-language metadata, or forwarded by grpc-gateway.
+func acceptLanguages(ctx context.Context) []string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	key := strings.ToLower(core.HeaderAcceptLanguage)
+	return append(md.Get(key), md.Get("grpcgateway-"+key)...)
+}
+
+// recovered logs a recovered panic and returns the error sent to the client.
+func recovered(ctx context.Context, method string, r any) error {
+	slog.ErrorContext(ctx, "Recovered from panic in gRPC handler", "panic", r, "method", method, "stack", string(debug.Stack()))
+	return errorsx.ErrInternal.GRPCStatus().Err()
+}
+
+// UnaryMetricsInterceptor records the duration and status code of every call
+// in the metrics package.
+func UnaryMetricsInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		metrics.ObserveGRPCCall(info.FullMethod, "unary", status.Code(err).String(), time.Since(start))
+
+		return resp, err
+	}
+}
+
+// StreamMetricsInterceptor is the stream counterpart of UnaryMetricsInterceptor.
+func StreamMetricsInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		metrics.ObserveGRPCCall(info.FullMethod, "stream", status.Code(err).String(), time.Since(start))
+
+		return err
+	}
+}
+
+// UnaryTracingInterceptor creates a server span for every call, continuing the
+// trace propagated in the incoming metadata.
+func UnaryTracingInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, span := startGRPCSpan(ctx, info.FullMethod)
+		defer span.End()
+
+		resp, err := handler(ctx, req)
+		endGRPCSpan(span, err)
+
+		return resp, err
+	}
+}
+
+// StreamTracingInterceptor is the stream counterpart of UnaryTracingInterceptor.
+func StreamTracingInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, span := startGRPCSpan(ss.Context(), info.FullMethod)
+		defer span.End()
+
+		err := handler(srv, &wrappedStream{ServerStream: ss, ctx: ctx})
+		endGRPCSpan(span, err)
+
+		return err
+	}
+}
+
+// startGRPCSpan starts the server span of a call to method, named
+// package.Service/Method like the rpc.method attribute.
+func startGRPCSpan(ctx context.Context, method string) (context.Context, trace.Span) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	ctx = otel.GetTextMapPropagator().Extract(ctx, metadataCarrier(md))
+
+	service, name, _ := strings.Cut(strings.TrimPrefix(method, "/"), "/")
+	return tracing.Tracer().Start(ctx, strings.TrimPrefix(method, "/"),
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(semconv.RPCSystemGRPC, semconv.RPCService(service), semconv.RPCMethod(name)),
+	)
+}
+
+// endGRPCSpan records the status of a call in its span.
+func endGRPCSpan(span trace.Span, err error) {
+	code := status.Code(err)
+	span.SetAttributes(semconv.RPCGRPCStatusCodeKey.Int(int(code)))
+	if err != nil {
+		span.RecordError(err)
+	}
+	// Like HTTP 5xx, only server side failures mark the span as failed.
+	switch code {
+	case codes.Unknown, codes.DeadlineExceeded, codes.Unimplemented, codes.Internal,
+		codes.Unavailable, codes.DataLoss:
+		span.SetStatus(otelcodes.Error, status.Convert(err).Message())
+	}
+}
+
+// metadataCarrier adapts gRPC metadata to propagation.TextMapCarrier.
+type metadataCarrier metadata.MD
+
+// Get implements propagation.TextMapCarrier.
+func (c metadataCarrier) Get(key string) string {
+	if values := metadata.MD(c).Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// Set implements propagation.TextMapCarrier.
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+// Keys implements propagation.TextMapCarrier.
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
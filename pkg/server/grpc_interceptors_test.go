@@ -0,0 +1,76 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"chunyu/pkg/errorsx"
+)
+
+var unaryInfo = &grpc.UnaryServerInfo{FullMethod: "/test.Service/Method"}
+
+func TestUnaryErrorInterceptor(t *testing.T) {
+	handler := func(context.Context, any) (any, error) {
+		return nil, fmt.Errorf("wrapped: %w", errorsx.New(404, "NotFound.User", "user not found"))
+	}
+
+	_, err := UnaryErrorInterceptor()(context.Background(), nil, unaryInfo, handler)
+
+	st, ok := status.FromError(err)
+	assert.True(t, ok)
+	assert.Equal(t, codes.NotFound, st.Code())
+	assert.Equal(t, "user not found", st.Message())
+	if assert.Len(t, st.Details(), 1) {
+		assert.Equal(t, "NotFound.User", st.Details()[0].(*errdetails.ErrorInfo).Reason)
+	}
+}
+
+func TestUnaryErrorInterceptor_Localized(t *testing.T) {
+	handler := func(context.Context, any) (any, error) {
+		return nil, errorsx.ErrNotFound
+	}
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("accept-language", "zh-CN,zh;q=0.9"))
+	_, err := UnaryErrorInterceptor()(ctx, nil, unaryInfo, handler)
+
+	st, _ := status.FromError(err)
+	assert.Equal(t, errorsx.ErrNotFound.Message, st.Message())
+	msg, ok := errorsx.Detail[*errdetails.LocalizedMessage](err)
+	if assert.True(t, ok) {
+		assert.Equal(t, "zh-CN", msg.GetLocale())
+		assert.Equal(t, "资源不存在.", msg.GetMessage())
+	}
+}
+
+func TestUnaryRecoveryInterceptor(t *testing.T) {
+	handler := func(context.Context, any) (any, error) {
+		panic("boom")
+	}
+
+	_, err := UnaryRecoveryInterceptor()(context.Background(), nil, unaryInfo, handler)
+
+	st, _ := status.FromError(err)
+	assert.Equal(t, codes.Internal, st.Code())
+	assert.Equal(t, errorsx.ErrInternal.Message, st.Message())
+}
+
+func TestUnaryTimeoutInterceptor(t *testing.T) {
+	handler := func(ctx context.Context, _ any) (any, error) {
+		deadline, ok := ctx.Deadline()
+		assert.True(t, ok)
+		assert.WithinDuration(t, time.Now().Add(time.Second), deadline, 100*time.Millisecond)
+		return nil, nil
+	}
+
+	_, err := UnaryTimeoutInterceptor(time.Second)(context.Background(), nil, unaryInfo, handler)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+
+	"chunyu/pkg/health"
+	"chunyu/pkg/options"
+)
+
+var _ Server = (*HealthServer)(nil)
+
+// HealthServer serves the liveness and readiness checks of a health.Registry
+// on the dedicated health check address.
+type HealthServer struct {
+	opts     *options.HealthOptions
+	registry *health.Registry
+	srv      *http.Server
+	lis      net.Listener
+	errCh    chan error
+}
+
+// NewHealthServer creates a health check server from the given options.
+func NewHealthServer(opts *options.HealthOptions, registry *health.Registry) *HealthServer {
+	return &HealthServer{
+		opts:     opts,
+		registry: registry,
+		srv:      &http.Server{Handler: opts.Handler(registry), ReadHeaderTimeout: opts.CheckTimeout},
+		errCh:    make(chan error, 1),
+	}
+}
+
+// Registry returns the registry of the checks served.
+func (s *HealthServer) Registry() *health.Registry {
+	return s.registry
+}
+
+// Addr returns the address the server is listening on. It is only valid after
+// Start returned successfully.
+func (s *HealthServer) Addr() net.Addr {
+	if s.lis == nil {
+		return nil
+	}
+	return s.lis.Addr()
+}
+
+// Start listens on the health check address and serves requests in the
+// background.
+func (s *HealthServer) Start(ctx context.Context) error {
+	lis, err := listen("tcp", s.opts.HealthCheckAddress)
+	if err != nil {
+		return err
+	}
+	s.lis = lis
+
+	slog.InfoContext(ctx, "Starting health check server", "addr", lis.Addr().String(), "livez", s.opts.LivenessPath, "readyz", s.opts.ReadinessPath)
+	go func() {
+		if err := s.srv.Serve(lis); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Health check server stopped unexpectedly", "err", err)
+			s.errCh <- err
+		}
+	}()
+
+	return nil
+}
+
+// Stop gracefully shuts the server down.
+func (s *HealthServer) Stop(ctx context.Context) error {
+	slog.InfoContext(ctx, "Shutting down health check server")
+	if err := s.srv.Shutdown(ctx); err != nil {
+		_ = s.srv.Close()
+		return err
+	}
+
+	return nil
+}
+
+// Run starts the server and blocks until ctx is cancelled, then shuts the
+// server down gracefully.
+func (s *HealthServer) Run(ctx context.Context) error {
+	return run(ctx, s, s.errCh)
+}
@@ -0,0 +1,176 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"chunyu/pkg/core"
+	"chunyu/pkg/errorsx"
+	"chunyu/pkg/metrics"
+	"chunyu/pkg/options"
+)
+
+var _ Server = (*HTTPServer)(nil)
+
+// HTTPServer is a gin based HTTP server built from options.HTTPOptions.
+type HTTPServer struct {
+	opts   *options.HTTPOptions
+	engine *gin.Engine
+	srv    *http.Server
+	lis    net.Listener
+	errCh  chan error
+
+	tlsConfig *tls.Config
+}
+
+// HTTPServerOption defines optional parameters for initializing the HTTP server.
+type HTTPServerOption func(*HTTPServer)
+
+// WithMiddlewares adds global middlewares to the gin engine. They run after the
+// built-in recovery middleware.
+func WithMiddlewares(mws ...gin.HandlerFunc) HTTPServerOption {
+	return func(s *HTTPServer) {
+		s.engine.Use(mws...)
+	}
+}
+
+// WithTLSConfig serves HTTPS using the given TLS config, typically obtained
+// from options.TLSOptions.TLSConfig. A nil config leaves TLS disabled.
+func WithTLSConfig(cfg *tls.Config) HTTPServerOption {
+	return func(s *HTTPServer) {
+		s.tlsConfig = cfg
+	}
+}
+
+// NewHTTPServer creates a HTTP server from the given options.
+// Requests are recorded in the metrics package, labelled with their route, and
+// logged with their X-Request-ID, which is generated when missing and sent
+// back in the response.
+// Panics in handlers are recovered into errorsx.ErrInternal, and unknown routes
+// or methods are answered with errorsx.ErrNotFound, both rendered through
+// core.WriteResponse so that they share the response format of handlers
+// registered with core.HandleJSONRequest and friends.
+func NewHTTPServer(opts *options.HTTPOptions, serverOpts ...HTTPServerOption) *HTTPServer {
+	engine := gin.New()
+	engine.Use(requestContext(), httpMetrics(), gin.CustomRecovery(func(c *gin.Context, recovered any) {
+		slog.ErrorContext(c.Request.Context(), "Recovered from panic in HTTP handler", "panic", recovered, "path", c.Request.URL.Path)
+		core.WriteResponse(c, nil, errorsx.ErrInternal)
+	}))
+	engine.NoRoute(func(c *gin.Context) {
+		core.WriteResponse(c, nil, errorsx.ErrNotFound)
+	})
+	engine.NoMethod(func(c *gin.Context) {
+		core.WriteResponse(c, nil, errorsx.ErrNotFound)
+	})
+
+	s := &HTTPServer{
+		opts:   opts,
+		engine: engine,
+		errCh:  make(chan error, 1),
+	}
+
+	for _, o := range serverOpts {
+		o(s)
+	}
+
+	readTimeout := opts.ReadTimeout
+	if readTimeout == 0 {
+		readTimeout = opts.Timeout
+	}
+	writeTimeout := opts.WriteTimeout
+	if writeTimeout == 0 {
+		writeTimeout = opts.Timeout
+	}
+
+	s.srv = &http.Server{
+		Handler:           engine,
+		ReadTimeout:       readTimeout,
+		ReadHeaderTimeout: readTimeout,
+		WriteTimeout:      writeTimeout,
+		IdleTimeout:       opts.IdleTimeout,
+		TLSConfig:         s.tlsConfig,
+	}
+
+	return s
+}
+
+// httpMetrics records the duration and status code of every request. It runs
+// before the recovery middleware so that recovered panics are counted as 500.
+func httpMetrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		done := metrics.HTTPRequestStarted()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			// Keep the cardinality bounded for unknown paths.
+			route = "unmatched"
+		}
+		done(c.Request.Method, route, c.Writer.Status())
+	}
+}
+
+// Engine returns the gin engine used to register routes.
+func (s *HTTPServer) Engine() *gin.Engine {
+	return s.engine
+}
+
+// Addr returns the address the server is listening on. It is only valid after
+// Start returned successfully.
+func (s *HTTPServer) Addr() net.Addr {
+	if s.lis == nil {
+		return nil
+	}
+	return s.lis.Addr()
+}
+
+// Start listens on the configured network and address and serves requests in
+// the background.
+func (s *HTTPServer) Start(ctx context.Context) error {
+	lis, err := listen(s.opts.Network, s.opts.Addr)
+	if err != nil {
+		return err
+	}
+	s.lis = lis
+
+	slog.InfoContext(ctx, "Starting HTTP server", "network", lis.Addr().Network(), "addr", lis.Addr().String(), "tls", s.tlsConfig != nil)
+	go func() {
+		var err error
+		if s.tlsConfig != nil {
+			// Certificates are provided by TLSConfig.
+			err = s.srv.ServeTLS(lis, "", "")
+		} else {
+			err = s.srv.Serve(lis)
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("HTTP server stopped unexpectedly", "err", err)
+			s.errCh <- err
+		}
+	}()
+
+	return nil
+}
+
+// Stop gracefully shuts the server down. If ctx expires before all in-flight
+// requests are finished, the remaining connections are closed.
+func (s *HTTPServer) Stop(ctx context.Context) error {
+	slog.InfoContext(ctx, "Shutting down HTTP server")
+	if err := s.srv.Shutdown(ctx); err != nil {
+		_ = s.srv.Close()
+		return err
+	}
+
+	return nil
+}
+
+// Run starts the server and blocks until ctx is cancelled, then shuts the
+// server down gracefully.
+func (s *HTTPServer) Run(ctx context.Context) error {
+	return run(ctx, s, s.errCh)
+}
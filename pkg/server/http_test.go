@@ -0,0 +1,103 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"chunyu/pkg/core"
+	"chunyu/pkg/options"
+)
+
+type echoRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+type echoResponse struct {
+	Greeting string `json:"greeting"`
+}
+
+func TestHTTPServer_UnixSocket(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	opts := options.NewHTTPOptions()
+	opts.Network = "unix"
+	opts.Addr = filepath.Join(t.TempDir(), "http.sock")
+
+	s := NewHTTPServer(opts)
+	s.Engine().POST("/echo", func(c *gin.Context) {
+		core.HandleJSONRequest(c, func(ctx context.Context, rq *echoRequest) (*echoResponse, error) {
+			return &echoResponse{Greeting: "hello " + rq.Name}, nil
+		})
+	})
+	s.Engine().GET("/panic", func(c *gin.Context) { panic("boom") })
+
+	require.NoError(t, s.Start(context.Background()))
+	defer func() { assert.NoError(t, s.Stop(context.Background())) }()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", opts.Addr)
+		},
+	}}
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		code   int
+		want   string
+	}{
+		{http.MethodPost, "/echo", `{"name":"chunyu"}`, http.StatusOK, `{"greeting":"hello chunyu"}`},
+		{http.MethodPost, "/echo", `{}`, http.StatusBadRequest, `"reason":"BindError"`},
+		{http.MethodGet, "/missing", "", http.StatusNotFound, `"reason":"NotFound"`},
+		{http.MethodGet, "/panic", "", http.StatusInternalServerError, `"reason":"InternalError"`},
+	}
+
+	for _, tt := range tests {
+		req, err := http.NewRequest(tt.method, "http://unix"+tt.path, strings.NewReader(tt.body))
+		require.NoError(t, err)
+
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		assert.Equal(t, tt.code, resp.StatusCode, tt.path)
+		assert.Contains(t, string(body), tt.want, tt.path)
+	}
+}
+
+func TestListen_StaleUnixSocket(t *testing.T) {
+	dir := t.TempDir()
+
+	// A socket left behind by a previous run is replaced.
+	sock := filepath.Join(dir, "stale.sock")
+	stale, err := net.Listen("unix", sock)
+	require.NoError(t, err)
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	require.NoError(t, stale.Close())
+
+	lis, err := listen("unix", sock)
+	require.NoError(t, err)
+	require.NoError(t, lis.Close())
+
+	// Any other file is kept.
+	file := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(file, []byte("keep"), 0o600))
+
+	_, err = listen("unix", file)
+	assert.ErrorContains(t, err, "not a unix socket")
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, "keep", string(data))
+}
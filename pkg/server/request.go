@@ -0,0 +1,201 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/anypb"
+
+	"chunyu/pkg/core"
+	"chunyu/pkg/errorsx"
+	"chunyu/pkg/log"
+)
+
+// requestIDHeader is the gRPC metadata key carrying the request ID.
+const requestIDHeader = "x-request-id"
+
+// maxRequestIDLength is the maximum length of the request IDs accepted from
+// clients.
+const maxRequestIDLength = 128
+
+// validRequestID reports whether a request ID received from a client can be
+// echoed and logged as is: it must not be empty, be at most
+// maxRequestIDLength long and only contain [A-Za-z0-9._-].
+func validRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range []byte(requestID) {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9', c == '.', c == '_', c == '-':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// requestContext returns a middleware which propagates the X-Request-ID header,
+// or generates one if it is missing or invalid, see validRequestID, and stores
+// the request ID and the peer address in the request context for clog and
+// slog. Once the request is handled it writes an access log line.
+func requestContext() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		requestID := c.GetHeader(core.HeaderRequestID)
+		if !validRequestID(requestID) {
+			requestID = uuid.NewString()
+		}
+		c.Header(core.HeaderRequestID, requestID)
+
+		ctx := core.WithRequestID(c.Request.Context(), requestID)
+		ctx = core.TrackUsername(log.WithAttr(ctx, slog.String("peer", c.ClientIP())))
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		// Handlers may have added the username to the context.
+		ctx = withTrackedUsername(c.Request.Context())
+		level := slog.LevelInfo
+		if c.Writer.Status() >= 500 {
+			level = slog.LevelError
+		}
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.String("route", c.FullPath()),
+			slog.Int("status", c.Writer.Status()),
+			slog.Int("size", c.Writer.Size()),
+			slog.Duration("duration", time.Since(start)),
+		}
+		slog.LogAttrs(ctx, level, "HTTP request", attrs...)
+	}
+}
+
+// UnaryRequestContextInterceptor propagates the x-request-id metadata, or
+// generates one if it is missing or invalid, and stores the request ID and the
+// peer address in the call context for clog and slog. The request ID is sent
+// back in the response header and added to the metadata of errorsx errors.
+// Once the call is handled it writes an access log line.
+func UnaryRequestContextInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		ctx, requestID := grpcRequestContext(ctx)
+		_ = grpc.SetHeader(ctx, metadata.Pairs(requestIDHeader, requestID))
+
+		resp, err := handler(ctx, req)
+		err = withRequestID(err, requestID)
+		logCall(ctx, info.FullMethod, err, start)
+
+		return resp, err
+	}
+}
+
+// StreamRequestContextInterceptor is the stream counterpart of
+// UnaryRequestContextInterceptor.
+func StreamRequestContextInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		ctx, requestID := grpcRequestContext(ss.Context())
+		_ = ss.SetHeader(metadata.Pairs(requestIDHeader, requestID))
+
+		err := handler(srv, &wrappedStream{ServerStream: ss, ctx: ctx})
+		err = withRequestID(err, requestID)
+		logCall(ctx, info.FullMethod, err, start)
+
+		return err
+	}
+}
+
+// grpcRequestContext stores the request ID and the peer address in ctx, and
+// tracks the username set by the handler for the access log.
+func grpcRequestContext(ctx context.Context) (context.Context, string) {
+	var requestID string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(requestIDHeader); len(values) > 0 {
+			requestID = values[0]
+		}
+	}
+	if !validRequestID(requestID) {
+		requestID = uuid.NewString()
+	}
+
+	ctx = core.WithRequestID(ctx, requestID)
+	if p, ok := peer.FromContext(ctx); ok {
+		ctx = log.WithAttr(ctx, slog.String("peer", p.Addr.String()))
+	}
+
+	return core.TrackUsername(ctx), requestID
+}
+
+// withTrackedUsername adds the username set by the handler on a derived
+// context to ctx, unless ctx already carries it, so that the access log
+// records it once.
+func withTrackedUsername(ctx context.Context) context.Context {
+	if username := core.TrackedUsername(ctx); username != "" && core.Username(ctx) == "" {
+		return core.WithUsername(ctx, username)
+	}
+	return ctx
+}
+
+// withRequestID adds the request ID to the metadata of err if it carries an
+// errdetails.ErrorInfo, as errors converted from errorsx do. The other details
+// of err are kept.
+func withRequestID(err error, requestID string) error {
+	s, ok := status.FromError(err)
+	if !ok || err == nil {
+		return err
+	}
+
+	p := s.Proto()
+	for i, detail := range p.GetDetails() {
+		info := &errdetails.ErrorInfo{}
+		if !detail.MessageIs(info) || detail.UnmarshalTo(info) != nil {
+			continue
+		}
+
+		if info.Metadata == nil {
+			info.Metadata = map[string]string{}
+		}
+		info.Metadata[core.HeaderRequestID] = requestID
+
+		packed, anyErr := anypb.New(info)
+		if anyErr != nil {
+			return err
+		}
+		p.Details[i] = packed
+		return status.ErrorProto(p)
+	}
+
+	return err
+}
+
+// logCall writes the access log line of a gRPC call.
+func logCall(ctx context.Context, method string, err error, start time.Time) {
+	ctx = withTrackedUsername(ctx)
+	code := status.Code(err)
+	level := slog.LevelInfo
+	if err != nil && errorsx.Code(err) >= 500 {
+		level = slog.LevelError
+	}
+
+	attrs := []slog.Attr{
+		slog.String("method", method),
+		slog.String("code", code.String()),
+		slog.Duration("duration", time.Since(start)),
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("err", status.Convert(err).Message()))
+	}
+	slog.LogAttrs(ctx, level, "gRPC call", attrs...)
+}
@@ -0,0 +1,140 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"chunyu/pkg/core"
+	"chunyu/pkg/errorsx"
+	"chunyu/pkg/log"
+	"chunyu/pkg/options"
+)
+
+// contextHandler records the attributes stored in the context like log.Slog.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if attrs, ok := ctx.Value(log.SlogFieldsKey).([]slog.Attr); ok {
+		record.AddAttrs(attrs...)
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+
+	var buf bytes.Buffer
+	old := slog.Default()
+	slog.SetDefault(slog.New(contextHandler{slog.NewTextHandler(&buf, nil)}))
+	t.Cleanup(func() { slog.SetDefault(old) })
+
+	return &buf
+}
+
+func TestRequestContext_HTTP(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logs := captureLogs(t)
+
+	s := NewHTTPServer(options.NewHTTPOptions())
+	s.Engine().GET("/users/:id", func(c *gin.Context) {
+		c.Request = c.Request.WithContext(core.WithUsername(c.Request.Context(), "alice"))
+		assert.Equal(t, "req-1", core.RequestID(c.Request.Context()))
+		core.WriteResponse(c, nil, errorsx.ErrNotFound.WithFieldViolation("id", "unknown user"))
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+	req.Header.Set(core.HeaderRequestID, "req-1")
+	rec := httptest.NewRecorder()
+	s.Engine().ServeHTTP(rec, req)
+
+	assert.Equal(t, "req-1", rec.Header().Get(core.HeaderRequestID))
+	assert.Contains(t, rec.Body.String(), `"X-Request-ID":"req-1"`)
+	assert.Contains(t, rec.Body.String(), `"details":[{"@type":"type.googleapis.com/google.rpc.BadRequest"`)
+	assert.Empty(t, errorsx.ErrNotFound.Metadata, "predefined errors must not be modified")
+	assert.Contains(t, logs.String(), `msg="HTTP request" method=GET path=/users/1 route=/users/:id status=404`)
+	assert.Equal(t, 1, strings.Count(logs.String(), "username=alice"))
+
+	// The error message is localized from the Accept-Language header.
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/users/1", nil)
+	req.Header.Set(core.HeaderRequestID, "req-1")
+	req.Header.Set(core.HeaderAcceptLanguage, "zh-CN,zh;q=0.9,en;q=0.8")
+	s.Engine().ServeHTTP(rec, req)
+	assert.Equal(t, "zh-CN", rec.Header().Get(core.HeaderContentLanguage))
+	assert.Contains(t, rec.Body.String(), `"message":"资源不存在."`)
+
+	// A request ID is generated when missing.
+	rec = httptest.NewRecorder()
+	s.Engine().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/missing", nil))
+	assert.Len(t, rec.Header().Get(core.HeaderRequestID), 36)
+
+	// Or when it could inject content into the logs.
+	for _, requestID := range []string{"req-1 msg=forged", strings.Repeat("a", maxRequestIDLength+1)} {
+		rec = httptest.NewRecorder()
+		req = httptest.NewRequest(http.MethodGet, "/missing", nil)
+		req.Header.Set(core.HeaderRequestID, requestID)
+		s.Engine().ServeHTTP(rec, req)
+		assert.Len(t, rec.Header().Get(core.HeaderRequestID), 36)
+	}
+	assert.NotContains(t, logs.String(), "forged")
+}
+
+func TestValidRequestID(t *testing.T) {
+	assert.True(t, validRequestID("0f8fad5b-d9cb-469f-a165-70867728950e"))
+	assert.True(t, validRequestID("trace_1.2"))
+	assert.True(t, validRequestID(strings.Repeat("a", maxRequestIDLength)))
+	assert.False(t, validRequestID(""))
+	assert.False(t, validRequestID(strings.Repeat("a", maxRequestIDLength+1)))
+	assert.False(t, validRequestID("a\nb"))
+	assert.False(t, validRequestID("a b"))
+	assert.False(t, validRequestID("a=b"))
+	assert.False(t, validRequestID("ünicode"))
+}
+
+func TestUnaryRequestContextInterceptor(t *testing.T) {
+	logs := captureLogs(t)
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(requestIDHeader, "req-2"))
+	handler := func(ctx context.Context, _ any) (any, error) {
+		assert.Equal(t, "req-2", core.RequestID(ctx))
+		_ = core.WithUsername(ctx, "bob") // e.g. set by an authentication interceptor
+		return nil, errorsx.New(404, "NotFound.User", "user not found").WithRetryDelay(time.Second).GRPCStatus().Err()
+	}
+
+	_, err := UnaryRequestContextInterceptor()(ctx, nil, unaryInfo, handler)
+
+	st, _ := status.FromError(err)
+	assert.Equal(t, codes.NotFound, st.Code())
+	require.Len(t, st.Details(), 2)
+	info := st.Details()[0].(*errdetails.ErrorInfo)
+	assert.Equal(t, "NotFound.User", info.Reason)
+	assert.Equal(t, "req-2", info.Metadata[core.HeaderRequestID])
+	assert.IsType(t, &errdetails.RetryInfo{}, st.Details()[1], "other details must be kept")
+	assert.Contains(t, logs.String(), `msg="gRPC call" method=/test.Service/Method code=NotFound`)
+	// The username set by the handler is logged once.
+	assert.Equal(t, 1, strings.Count(logs.String(), "username=bob"))
+
+	// An invalid request ID is replaced.
+	ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs(requestIDHeader, "req-3\nforged"))
+	_, _ = UnaryRequestContextInterceptor()(ctx, nil, unaryInfo, func(ctx context.Context, _ any) (any, error) {
+		assert.Len(t, core.RequestID(ctx), 36)
+		return nil, nil
+	})
+	assert.NotContains(t, logs.String(), "forged")
+}
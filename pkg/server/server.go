@@ -0,0 +1,89 @@
+// Package server provides HTTP and gRPC servers built from the generic
+// options in chunyu/pkg/options. Servers expose Start and Stop methods which
+// can be registered as app.Lifecycle hooks, and a blocking Run method which
+// shuts the server down gracefully when its context is cancelled.
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+)
+
+// defaultShutdownTimeout bounds graceful shutdown in Run.
+const defaultShutdownTimeout = 30 * time.Second
+
+// Server is implemented by every server in this package.
+type Server interface {
+	// Start begins listening and serving in the background. It does not block.
+	Start(ctx context.Context) error
+
+	// Stop gracefully shuts the server down. It waits for in-flight requests
+	// until ctx is done.
+	Stop(ctx context.Context) error
+}
+
+// listen creates a listener for the given network and address. For unix
+// sockets, a stale socket file left behind by a previous run is removed first.
+// Any other file at addr is left untouched and reported as an error.
+func listen(network, addr string) (net.Listener, error) {
+	if network == "" {
+		network = "tcp"
+	}
+
+	if network == "unix" || network == "unixpacket" {
+		if err := removeStaleSocket(addr); err != nil {
+			return nil, err
+		}
+	}
+
+	lis, err := net.Listen(network, addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s://%s: %w", network, addr, err)
+	}
+
+	return lis, nil
+}
+
+// removeStaleSocket removes the unix socket at path, if any. It refuses to
+// remove files which are not sockets.
+func removeStaleSocket(path string) error {
+	fi, err := os.Lstat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to stat unix socket %q: %w", path, err)
+	}
+	if fi.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("refusing to remove %q: not a unix socket", path)
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove stale unix socket %q: %w", path, err)
+	}
+	return nil
+}
+
+// run starts srv, blocks until ctx is done or serving fails, and then stops
+// srv gracefully.
+func run(ctx context.Context, srv Server, errCh <-chan error) error {
+	if err := srv.Start(ctx); err != nil {
+		return err
+	}
+
+	select {
+	case <-ctx.Done():
+	case err := <-errCh:
+		return err
+	}
+
+	// Use a fresh context: ctx is already cancelled at this point.
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), defaultShutdownTimeout)
+	defer cancel()
+
+	return srv.Stop(shutdownCtx)
+}
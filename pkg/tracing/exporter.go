@@ -0,0 +1,107 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// writerExporter writes spans as JSON lines.
+type writerExporter struct {
+	mu  sync.Mutex
+	w   io.Writer
+	enc *json.Encoder
+}
+
+var _ sdktrace.SpanExporter = (*writerExporter)(nil)
+
+// NewWriterExporter returns an exporter writing every span as a JSON line to
+// w. w is closed on shutdown if it implements io.Closer, unless it is stdout
+// or stderr.
+func NewWriterExporter(w io.Writer) sdktrace.SpanExporter {
+	return &writerExporter{w: w, enc: json.NewEncoder(w)}
+}
+
+// span is the JSON representation of an exported span.
+type span struct {
+	Name         string            `json:"name"`
+	Kind         string            `json:"kind"`
+	TraceID      string            `json:"trace_id"`
+	SpanID       string            `json:"span_id"`
+	ParentSpanID string            `json:"parent_span_id,omitempty"`
+	Start        time.Time         `json:"start"`
+	Duration     string            `json:"duration"`
+	Status       string            `json:"status"`
+	Description  string            `json:"description,omitempty"`
+	Attributes   map[string]any    `json:"attributes,omitempty"`
+	Events       []event           `json:"events,omitempty"`
+	Resource     map[string]string `json:"resource,omitempty"`
+}
+
+type event struct {
+	Name       string         `json:"name"`
+	Time       time.Time      `json:"time"`
+	Attributes map[string]any `json:"attributes,omitempty"`
+}
+
+// ExportSpans implements sdktrace.SpanExporter.
+func (e *writerExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, s := range spans {
+		out := span{
+			Name:        s.Name(),
+			Kind:        s.SpanKind().String(),
+			TraceID:     s.SpanContext().TraceID().String(),
+			SpanID:      s.SpanContext().SpanID().String(),
+			Start:       s.StartTime(),
+			Duration:    s.EndTime().Sub(s.StartTime()).String(),
+			Status:      s.Status().Code.String(),
+			Description: s.Status().Description,
+			Attributes:  map[string]any{},
+			Resource:    map[string]string{},
+		}
+		if s.Parent().IsValid() {
+			out.ParentSpanID = s.Parent().SpanID().String()
+		}
+		for _, kv := range s.Attributes() {
+			out.Attributes[string(kv.Key)] = kv.Value.AsInterface()
+		}
+		for _, ev := range s.Events() {
+			attrs := map[string]any{}
+			for _, kv := range ev.Attributes {
+				attrs[string(kv.Key)] = kv.Value.AsInterface()
+			}
+			out.Events = append(out.Events, event{Name: ev.Name, Time: ev.Time, Attributes: attrs})
+		}
+		for _, kv := range s.Resource().Attributes() {
+			out.Resource[string(kv.Key)] = kv.Value.Emit()
+		}
+
+		if err := e.enc.Encode(out); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Shutdown implements sdktrace.SpanExporter.
+func (e *writerExporter) Shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if f, ok := e.w.(interface{ Fd() uintptr }); ok && f.Fd() <= 2 {
+		return nil
+	}
+	if closer, ok := e.w.(io.Closer); ok {
+		return closer.Close()
+	}
+
+	return nil
+}
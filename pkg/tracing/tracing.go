@@ -0,0 +1,119 @@
+// Package tracing configures OpenTelemetry tracing for the framework.
+//
+// NewTracerProvider installs a global tracer provider exporting spans with
+// OTLP, or as JSON lines to stdout or a file for offline use. The HTTP and
+// gRPC servers, the GORM TracePlugin and the redis clients of the framework
+// create their spans with Tracer, so they are no-ops until a provider is
+// installed.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Supported span exporters.
+const (
+	// ExporterOTLP sends spans to an OTLP collector over gRPC.
+	ExporterOTLP = "otlp"
+	// ExporterStdout writes spans as JSON lines to stdout.
+	ExporterStdout = "stdout"
+	// ExporterFile writes spans as JSON lines to a file.
+	ExporterFile = "file"
+	// ExporterNone disables tracing.
+	ExporterNone = "none"
+)
+
+// instrumentationName is the name of the tracer used by the framework.
+const instrumentationName = "chunyu"
+
+// Options defines options for the tracer provider.
+type Options struct {
+	// Exporter is one of ExporterOTLP, ExporterStdout, ExporterFile or
+	// ExporterNone.
+	Exporter string
+	// Endpoint is the host:port of the OTLP collector.
+	Endpoint string
+	// Insecure disables TLS towards the OTLP collector.
+	Insecure bool
+	// Headers are sent with every OTLP export, e.g. for authentication.
+	Headers map[string]string
+	// File is the path spans are appended to with ExporterFile.
+	File string
+	// ServiceName and ServiceVersion identify the application in the spans.
+	ServiceName    string
+	ServiceVersion string
+	// SampleRatio is the ratio of traces sampled, from 0 to 1. Traces whose
+	// parent was sampled are always sampled.
+	SampleRatio float64
+}
+
+// NewTracerProvider creates a tracer provider from opts and installs it, with
+// the W3C trace context and baggage propagators, as the global provider. The
+// provider must be shut down on exit to flush the pending spans.
+func NewTracerProvider(ctx context.Context, opts *Options) (*sdktrace.TracerProvider, error) {
+	var spanProcessor sdktrace.SpanProcessor
+	switch opts.Exporter {
+	case ExporterOTLP:
+		clientOpts := []otlptracegrpc.Option{
+			otlptracegrpc.WithEndpoint(opts.Endpoint),
+			otlptracegrpc.WithHeaders(opts.Headers),
+		}
+		if opts.Insecure {
+			clientOpts = append(clientOpts, otlptracegrpc.WithInsecure())
+		}
+		exporter, err := otlptracegrpc.New(ctx, clientOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+		}
+		spanProcessor = sdktrace.NewBatchSpanProcessor(exporter)
+	case ExporterStdout:
+		// Export synchronously so that spans show up as they end.
+		spanProcessor = sdktrace.NewSimpleSpanProcessor(NewWriterExporter(os.Stdout))
+	case ExporterFile:
+		f, err := os.OpenFile(opts.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open trace file: %w", err)
+		}
+		spanProcessor = sdktrace.NewSimpleSpanProcessor(NewWriterExporter(f))
+	case "", ExporterNone:
+	default:
+		return nil, fmt.Errorf("unsupported trace exporter %q", opts.Exporter)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		semconv.ServiceName(opts.ServiceName),
+		semconv.ServiceVersion(opts.ServiceVersion),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	providerOpts := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.SampleRatio))),
+	}
+	if spanProcessor != nil {
+		providerOpts = append(providerOpts, sdktrace.WithSpanProcessor(spanProcessor))
+	}
+
+	tp := sdktrace.NewTracerProvider(providerOpts...)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	return tp, nil
+}
+
+// Tracer returns the tracer of the framework from the global provider.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
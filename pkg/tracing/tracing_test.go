@@ -0,0 +1,82 @@
+package tracing_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"chunyu/pkg/core"
+	"chunyu/pkg/errorsx"
+	"chunyu/pkg/tracing"
+)
+
+type request struct {
+	ID string `uri:"id"`
+}
+
+func TestNewTracerProvider_File(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	file := filepath.Join(t.TempDir(), "spans.json")
+
+	tp, err := tracing.NewTracerProvider(context.Background(), &tracing.Options{
+		Exporter:    tracing.ExporterFile,
+		File:        file,
+		ServiceName: "test",
+		SampleRatio: 1,
+	})
+	require.NoError(t, err)
+
+	engine := gin.New()
+	engine.GET("/users/:id", func(c *gin.Context) {
+		core.HandleUriRequest(c, func(ctx context.Context, rq *request) (any, error) {
+			_, span := tracing.Tracer().Start(ctx, "lookup")
+			span.End()
+			return nil, errorsx.ErrNotFound
+		})
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	engine.ServeHTTP(httptest.NewRecorder(), req)
+	require.NoError(t, tp.Shutdown(context.Background()))
+
+	f, err := os.Open(file)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var spans []map[string]any
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var span map[string]any
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &span))
+		spans = append(spans, span)
+	}
+	require.Len(t, spans, 2)
+
+	lookup, server := spans[0], spans[1]
+	assert.Equal(t, "lookup", lookup["name"])
+	assert.Equal(t, server["span_id"], lookup["parent_span_id"])
+
+	assert.Equal(t, "GET /users/:id", server["name"])
+	assert.Equal(t, "server", server["kind"])
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", server["trace_id"])
+	assert.Equal(t, "00f067aa0ba902b7", server["parent_span_id"])
+	assert.Equal(t, "Unset", server["status"], "4xx are not server failures")
+	attrs := server["attributes"].(map[string]any)
+	assert.Equal(t, float64(http.StatusNotFound), attrs["http.response.status_code"])
+	assert.Equal(t, "test", server["resource"].(map[string]any)["service.name"])
+}
+
+func TestNewTracerProvider_Unsupported(t *testing.T) {
+	_, err := tracing.NewTracerProvider(context.Background(), &tracing.Options{Exporter: "jaeger"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,261 @@
+// Package validation validates options structs against the rules declared in
+// their `validate` struct tags.
+//
+// Rules are separated by commas:
+//
+//	required        the value must not be the zero value
+//	min=N, max=N    bounds of numbers and durations (e.g. min=1s), or of the
+//	                length of strings, slices and maps
+//	oneof=a b c     the value must be one of the space separated values
+//	address         the value must be a host:port address
+//	file            the value must be the path of an existing regular file
+//
+// oneof, address and file apply to every element of a string slice and skip
+// empty values; combine them with required to reject empty values.
+//
+// Nested structs are validated recursively. Field paths are built from the
+// mapstructure tags, falling back to the json tags, like the configuration
+// keys, e.g. mysql.max-open-connections.
+package validation
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	netutils "k8s.io/utils/net"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// ValidateStruct validates obj, a struct or a pointer to one, and returns one
+// error per violated rule. prefix is the path of obj, e.g. mysql. The result
+// can be returned as is from IOptions.Validate.
+func ValidateStruct(obj any, prefix string) []error {
+	var path *field.Path
+	if prefix != "" {
+		path = field.NewPath(prefix)
+	}
+
+	var errs []error
+	for _, err := range validateStruct(path, reflect.ValueOf(obj)) {
+		errs = append(errs, err)
+	}
+
+	return errs
+}
+
+// Validate is like ValidateStruct but aggregates the errors into a single
+// error, or returns nil if obj is valid.
+func Validate(obj any, prefix string) error {
+	return utilerrors.NewAggregate(ValidateStruct(obj, prefix))
+}
+
+func validateStruct(path *field.Path, v reflect.Value) field.ErrorList {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var errs field.ErrorList
+	for i := range v.NumField() {
+		f := v.Type().Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		name, squash, ok := fieldName(f)
+		if !ok {
+			continue
+		}
+		child := path
+		if !squash {
+			child = path.Child(name)
+		}
+
+		if rules := f.Tag.Get("validate"); rules != "" {
+			errs = append(errs, validateField(child, v.Field(i), rules)...)
+		}
+		if f.Type != durationType {
+			errs = append(errs, validateStruct(child, v.Field(i))...)
+		}
+	}
+
+	return errs
+}
+
+// fieldName returns the path segment of a struct field, whether the field is
+// squashed into its parent, and false if the field is skipped.
+func fieldName(f reflect.StructField) (string, bool, bool) {
+	name, opts, _ := strings.Cut(f.Tag.Get("mapstructure"), ",")
+	if name == "-" {
+		return "", false, false
+	}
+	squash := strings.Contains(opts, "squash")
+	if name == "" && !squash {
+		name, _, _ = strings.Cut(f.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			name = strings.ToLower(f.Name)
+		}
+	}
+
+	return name, squash, true
+}
+
+func validateField(path *field.Path, v reflect.Value, rules string) field.ErrorList {
+	var errs field.ErrorList
+	for _, rule := range strings.Split(rules, ",") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		switch name {
+		case "required":
+			if v.IsZero() {
+				errs = append(errs, field.Required(path, ""))
+			}
+		case "min", "max":
+			if err := validateBound(path, v, name, arg); err != nil {
+				errs = append(errs, err)
+			}
+		case "oneof":
+			allowed := strings.Fields(arg)
+			for _, s := range stringValues(v) {
+				if !slices.Contains(allowed, s) {
+					errs = append(errs, field.NotSupported(path, s, allowed))
+				}
+			}
+		case "address":
+			for _, s := range stringValues(v) {
+				if err := validateAddress(s); err != nil {
+					errs = append(errs, field.Invalid(path, s, err.Error()))
+				}
+			}
+		case "file":
+			for _, s := range stringValues(v) {
+				if info, err := os.Stat(s); err != nil {
+					errs = append(errs, field.Invalid(path, s, err.Error()))
+				} else if !info.Mode().IsRegular() {
+					errs = append(errs, field.Invalid(path, s, "must be a regular file"))
+				}
+			}
+		default:
+			errs = append(errs, field.InternalError(path, fmt.Errorf("unknown validation rule %q", name)))
+		}
+	}
+
+	return errs
+}
+
+// validateBound checks the min or max rule against v.
+func validateBound(path *field.Path, v reflect.Value, rule, arg string) *field.Error {
+	var cmp int
+	var value any = v.Interface()
+
+	switch {
+	case v.Type() == durationType:
+		bound, err := time.ParseDuration(arg)
+		if err != nil {
+			return field.InternalError(path, fmt.Errorf("invalid %s bound %q: %w", rule, arg, err))
+		}
+		d := time.Duration(v.Int())
+		cmp, value = compare(d, bound), d.String()
+	case v.CanInt():
+		bound, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			return field.InternalError(path, fmt.Errorf("invalid %s bound %q: %w", rule, arg, err))
+		}
+		cmp = compare(v.Int(), bound)
+	case v.CanUint():
+		bound, err := strconv.ParseUint(arg, 10, 64)
+		if err != nil {
+			return field.InternalError(path, fmt.Errorf("invalid %s bound %q: %w", rule, arg, err))
+		}
+		cmp = compare(v.Uint(), bound)
+	case v.CanFloat():
+		bound, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return field.InternalError(path, fmt.Errorf("invalid %s bound %q: %w", rule, arg, err))
+		}
+		cmp = compare(v.Float(), bound)
+	case v.Kind() == reflect.String || v.Kind() == reflect.Slice || v.Kind() == reflect.Map:
+		bound, err := strconv.Atoi(arg)
+		if err != nil {
+			return field.InternalError(path, fmt.Errorf("invalid %s bound %q: %w", rule, arg, err))
+		}
+		cmp = compare(v.Len(), bound)
+		if cmp < 0 && rule == "min" {
+			return field.Invalid(path, value, fmt.Sprintf("length must be at least %d", bound))
+		}
+		if cmp > 0 && rule == "max" {
+			return field.Invalid(path, value, fmt.Sprintf("length must be at most %d", bound))
+		}
+		return nil
+	default:
+		return field.InternalError(path, fmt.Errorf("%s is not supported for %s", rule, v.Type()))
+	}
+
+	if cmp < 0 && rule == "min" {
+		return field.Invalid(path, value, "must be greater than or equal to "+arg)
+	}
+	if cmp > 0 && rule == "max" {
+		return field.Invalid(path, value, "must be less than or equal to "+arg)
+	}
+	return nil
+}
+
+func compare[T int | int64 | uint64 | float64 | time.Duration](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// stringValues returns the non-empty strings held by v, a string or a slice of
+// strings.
+func stringValues(v reflect.Value) []string {
+	var values []string
+	switch {
+	case v.Kind() == reflect.String:
+		values = append(values, v.String())
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String:
+		for i := range v.Len() {
+			values = append(values, v.Index(i).String())
+		}
+	}
+
+	return slices.DeleteFunc(values, func(s string) bool { return s == "" })
+}
+
+// validateAddress checks that addr is in host:port format, where host is
+// empty, an IP address or a DNS name.
+func validateAddress(addr string) error {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("must be in host:port format")
+	}
+	if host != "" && netutils.ParseIPSloppy(host) == nil {
+		if msgs := validation.IsDNS1123Subdomain(strings.ToLower(host)); len(msgs) > 0 {
+			return fmt.Errorf("host must be an IP address or a DNS name")
+		}
+	}
+	if _, err := netutils.ParsePort(port, true); err != nil {
+		return fmt.Errorf("port %q is not a valid port number", port)
+	}
+
+	return nil
+}
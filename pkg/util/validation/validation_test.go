@@ -0,0 +1,66 @@
+package validation
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type poolOptions struct {
+	MaxOpen int           `mapstructure:"max-open-connections" validate:"min=0,max=100"`
+	Timeout time.Duration `mapstructure:"timeout" validate:"min=1s,max=1m"`
+}
+
+type testOptions struct {
+	Addr     string       `json:"addr" mapstructure:"addr" validate:"required,address"`
+	Replicas []string     `json:"replicas" validate:"address"`
+	Mode     string       `mapstructure:"mode" validate:"oneof=single cluster"`
+	CertFile string       `mapstructure:"cert-file" validate:"file"`
+	Tags     []string     `mapstructure:"tags" validate:"max=2"`
+	Pool     *poolOptions `mapstructure:"pool"`
+	Embedded poolOptions  `mapstructure:",squash"`
+	Skipped  string       `mapstructure:"-" validate:"required"`
+}
+
+func TestValidateStruct(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "cert.pem")
+	assert.NoError(t, os.WriteFile(file, nil, 0o600))
+
+	valid := &testOptions{
+		Addr:     "db.example.com:3306",
+		Replicas: []string{"10.0.0.1:3306", ":3307"},
+		CertFile: file,
+		Pool:     &poolOptions{MaxOpen: 10, Timeout: time.Second},
+		Embedded: poolOptions{Timeout: time.Minute},
+	}
+	assert.Empty(t, ValidateStruct(valid, "mysql"))
+	assert.NoError(t, Validate(valid, "mysql"))
+
+	invalid := &testOptions{
+		Replicas: []string{"db"},
+		Mode:     "sentinel",
+		CertFile: filepath.Dir(file),
+		Tags:     []string{"a", "b", "c"},
+		Pool:     &poolOptions{MaxOpen: 101, Timeout: 2 * time.Minute},
+	}
+
+	var msgs []string
+	for _, err := range ValidateStruct(invalid, "mysql") {
+		msgs = append(msgs, err.Error())
+	}
+	assert.Equal(t, []string{
+		"mysql.addr: Required value",
+		`mysql.replicas: Invalid value: "db": must be in host:port format`,
+		`mysql.mode: Unsupported value: "sentinel": supported values: "single", "cluster"`,
+		`mysql.cert-file: Invalid value: "` + filepath.Dir(file) + `": must be a regular file`,
+		`mysql.tags: Invalid value: ["a","b","c"]: length must be at most 2`,
+		"mysql.pool.max-open-connections: Invalid value: 101: must be less than or equal to 100",
+		`mysql.pool.timeout: Invalid value: "2m0s": must be less than or equal to 1m`,
+		`mysql.timeout: Invalid value: "0s": must be greater than or equal to 1s`,
+	}, msgs)
+
+	assert.Error(t, Validate(invalid, "mysql"))
+}